@@ -6,15 +6,14 @@ toolchain go1.24.0
 
 require (
 	github.com/PuerkitoBio/goquery v1.8.1
+	github.com/andybalholm/cascadia v1.3.2
 	github.com/go-shiori/go-readability v0.0.0-20231029095239-6b97d5aba789
+	github.com/gogs/chardet v0.0.0-20211120154057-b7413eaefb8f
 	github.com/gorilla/mux v1.8.1
 	github.com/joho/godotenv v1.5.1
+	github.com/ledongthuc/pdf v0.0.0-20220302134840-0c2507a12d80
+	golang.org/x/net v0.25.0
+	golang.org/x/text v0.20.0
 )
 
-require (
-	github.com/andybalholm/cascadia v1.3.2 // indirect
-	github.com/go-shiori/dom v0.0.0-20210627111528-4e4722cd0d65 // indirect
-	github.com/gogs/chardet v0.0.0-20211120154057-b7413eaefb8f // indirect
-	golang.org/x/net v0.25.0 // indirect
-	golang.org/x/text v0.20.0 // indirect
-)
+require github.com/go-shiori/dom v0.0.0-20210627111528-4e4722cd0d65 // indirect
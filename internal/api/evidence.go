@@ -0,0 +1,98 @@
+package api
+
+import (
+	"math"
+	"strings"
+	"unicode"
+
+	"open-sonar/internal/models"
+	"open-sonar/internal/search/webscrape"
+)
+
+// common words that carry no signal when measuring query coverage
+var coverageStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "can": true, "do": true, "does": true, "for": true,
+	"from": true, "how": true, "in": true, "is": true, "it": true, "of": true,
+	"on": true, "or": true, "the": true, "to": true, "was": true, "what": true,
+	"when": true, "where": true, "which": true, "who": true, "why": true,
+	"will": true, "with": true,
+}
+
+// assessEvidence summarizes how well the retrieved results support the query.
+// fallback reports whether the handler told the model to answer from training data.
+func assessEvidence(query string, results []webscrape.PageInfo, fallback bool) *models.Evidence {
+	coverage := queryCoverage(query, results)
+
+	return &models.Evidence{
+		SourcesUsed: len(results),
+		Coverage:    coverage,
+		Fallback:    fallback,
+		Confidence:  confidenceLevel(len(results), coverage, fallback),
+	}
+}
+
+// returns the fraction of significant query terms found in the retrieved text
+func queryCoverage(query string, results []webscrape.PageInfo) float64 {
+	terms := coverageTerms(query)
+	if len(terms) == 0 || len(results) == 0 {
+		return 0
+	}
+
+	// Match whole words, so that "art" isn't covered by "start"
+	words := make(map[string]bool)
+	for _, result := range results {
+		for _, text := range []string{result.Title, result.Summary, result.Content} {
+			for _, word := range splitWords(text) {
+				words[word] = true
+			}
+		}
+	}
+
+	matched := 0
+	for _, term := range terms {
+		if words[term] {
+			matched++
+		}
+	}
+
+	// Round to two decimals to keep the response stable and readable
+	return math.Round(float64(matched)/float64(len(terms))*100) / 100
+}
+
+// splits a query into distinct lowercase terms, dropping punctuation and stop words
+func coverageTerms(query string) []string {
+	fields := splitWords(query)
+
+	seen := make(map[string]bool, len(fields))
+	terms := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if coverageStopWords[field] || seen[field] {
+			continue
+		}
+		seen[field] = true
+		terms = append(terms, field)
+	}
+	return terms
+}
+
+// splits text into lowercase words of letters and digits
+func splitWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// maps source count and coverage to a coarse confidence level
+func confidenceLevel(sources int, coverage float64, fallback bool) string {
+	switch {
+	case fallback || sources == 0:
+		return models.ConfidenceLow
+	case sources >= 3 && coverage >= 0.7:
+		return models.ConfidenceHigh
+	case coverage >= 0.4:
+		return models.ConfidenceMedium
+	default:
+		return models.ConfidenceLow
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"testing"
+
+	"open-sonar/internal/models"
+	"open-sonar/internal/search/webscrape"
+)
+
+func TestAssessEvidence(t *testing.T) {
+	results := []webscrape.PageInfo{
+		{URL: "https://example.com/1", Title: "Solar power growth", Content: "Solar capacity grew in 2024."},
+		{URL: "https://example.com/2", Title: "Wind energy", Content: "Offshore wind is expanding."},
+		{URL: "https://example.com/3", Title: "Renewable energy outlook", Content: "Renewables keep growing."},
+	}
+
+	tests := []struct {
+		name               string
+		query              string
+		results            []webscrape.PageInfo
+		fallback           bool
+		expectedSources    int
+		expectedCoverage   float64
+		expectedConfidence string
+	}{
+		{
+			name:               "well covered query",
+			query:              "What is the outlook for renewable energy?",
+			results:            results,
+			expectedSources:    3,
+			expectedCoverage:   1,
+			expectedConfidence: models.ConfidenceHigh,
+		},
+		{
+			name:               "partially covered query",
+			query:              "renewable energy subsidies germany",
+			results:            results,
+			expectedSources:    3,
+			expectedCoverage:   0.5,
+			expectedConfidence: models.ConfidenceMedium,
+		},
+		{
+			name:               "unrelated results",
+			query:              "pokemon card prices",
+			results:            results,
+			expectedSources:    3,
+			expectedCoverage:   0,
+			expectedConfidence: models.ConfidenceLow,
+		},
+		{
+			name:  "terms only inside other words",
+			query: "art go",
+			results: []webscrape.PageInfo{
+				{URL: "https://example.com/4", Title: "Getting started", Content: "Start with a good project."},
+			},
+			expectedSources:    1,
+			expectedCoverage:   0,
+			expectedConfidence: models.ConfidenceLow,
+		},
+		{
+			name:               "fallback without results",
+			query:              "renewable energy",
+			results:            nil,
+			fallback:           true,
+			expectedSources:    0,
+			expectedCoverage:   0,
+			expectedConfidence: models.ConfidenceLow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evidence := assessEvidence(tt.query, tt.results, tt.fallback)
+
+			if evidence.SourcesUsed != tt.expectedSources {
+				t.Errorf("Expected %d sources, got %d", tt.expectedSources, evidence.SourcesUsed)
+			}
+			if evidence.Coverage != tt.expectedCoverage {
+				t.Errorf("Expected coverage %.2f, got %.2f", tt.expectedCoverage, evidence.Coverage)
+			}
+			if evidence.Fallback != tt.fallback {
+				t.Errorf("Expected fallback %v, got %v", tt.fallback, evidence.Fallback)
+			}
+			if evidence.Confidence != tt.expectedConfidence {
+				t.Errorf("Expected confidence %q, got %q", tt.expectedConfidence, evidence.Confidence)
+			}
+		})
+	}
+}
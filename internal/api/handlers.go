@@ -3,7 +3,7 @@ package api
 import (
 	"encoding/json"
 	"fmt"
-	"io"
+	"math"
 	"net/http"
 	"strings"
 	"time"
@@ -11,6 +11,7 @@ import (
 	"open-sonar/internal/citations"
 	"open-sonar/internal/llm"
 	"open-sonar/internal/models"
+	"open-sonar/internal/prompts"
 	"open-sonar/internal/search/webscrape"
 	"open-sonar/internal/utils"
 )
@@ -44,10 +45,8 @@ func ChatCompletionsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse request
-	bodyBytes, err := io.ReadAll(r.Body)
-	if err != nil {
-		utils.Error(fmt.Sprintf("Failed to read request body: %v", err))
-		WriteJSONError(w, http.StatusBadRequest, "Unable to read request body")
+	bodyBytes, ok := readRequestBody(w, r)
+	if !ok {
 		return
 	}
 
@@ -58,6 +57,14 @@ func ChatCompletionsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Debug traces expose prompts and raw results, so restrict them to admins
+	if chatReq.Debug && !IsAdminAPIKey(extractAPIKey(r)) {
+		WriteJSONError(w, http.StatusForbidden, "Forbidden: debug traces require an admin API key")
+		return
+	}
+	trace := newTraceRecorder(chatReq.Debug)
+	requestStart := time.Now()
+
 	// Validate request
 	if len(chatReq.Messages) == 0 {
 		utils.Error("Missing 'messages' in request")
@@ -139,12 +146,51 @@ func ChatCompletionsHandler(w http.ResponseWriter, r *http.Request) {
 		MaxRetries:          2, // Default
 		SearchDomainFilter:  chatReq.SearchDomainFilter,
 		SearchRecencyFilter: chatReq.SearchRecencyFilter,
+		SearchMode:          strings.ToLower(chatReq.SearchMode),
+	}
+
+	// Only the most relevant results reach the prompt, so fetching more
+	// pages than that per search is wasted
+	maxResults := 8
+	searchOptions.Fetch.MaxPages = maxResults
+	if searchOptions.SearchMode != "" && searchOptions.SearchMode != webscrape.SearchModeWeb && searchOptions.SearchMode != webscrape.SearchModeNews {
+		WriteJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid search_mode %q: expected \"web\" or \"news\"", chatReq.SearchMode))
+		return
+	}
+
+	// Providers requested explicitly take precedence over those configured for the model
+	if len(chatReq.SearchProviders) > 0 || chatReq.SearchProvider != "" {
+		requested := chatReq.SearchProviders
+		if len(requested) == 0 {
+			requested = []string{chatReq.SearchProvider}
+		}
+		searchOptions.Providers, err = webscrape.ParseProviderSpecs(requested)
+		if err != nil {
+			WriteJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		for _, spec := range searchOptions.Providers {
+			if !webscrape.HasSearchProvider(spec.Name) {
+				WriteJSONError(w, http.StatusBadRequest, fmt.Sprintf("Unknown search provider %q; see /search/providers", spec.Name))
+				return
+			}
+		}
+	} else if searchOptions.SearchMode != webscrape.SearchModeNews {
+		// News searches use the news providers unless some are requested
+		searchOptions.Providers, err = webscrape.ProvidersForModel(modelName)
+		if err != nil {
+			utils.Error(fmt.Sprintf("Invalid search provider configuration: %v", err))
+			WriteJSONError(w, http.StatusInternalServerError, "Search provider configuration error")
+			return
+		}
 	}
 
 	// Determine if this is a model that needs web search
 	needsSearch := strings.HasPrefix(modelName, "sonar")
 
 	var citationURLs []string
+	var evidence *models.Evidence
+	var searchInfo *models.SearchInfo
 
 	// Perform web search for sonar models
 	if needsSearch {
@@ -152,6 +198,7 @@ func ChatCompletionsHandler(w http.ResponseWriter, r *http.Request) {
 
 		// Create a search timer
 		searchTimer := utils.NewTimer("Web search")
+		searchStart := time.Now()
 
 		// Extract search queries from the user message
 		searchQueries := extractSearchQueries(userQuery)
@@ -159,25 +206,52 @@ func ChatCompletionsHandler(w http.ResponseWriter, r *http.Request) {
 		// Perform searches for each extracted query
 		var allResults []webscrape.PageInfo
 		for _, query := range searchQueries {
-			results := webscrape.ScrapeWithOptions(query, searchOptions)
-			allResults = append(allResults, results...)
+			report := webscrape.SearchWithReport(query, searchOptions)
+			trace.recordSearch(query, report)
+			searchInfo = noteBlockedProviders(searchInfo, report)
+			allResults = append(allResults, report.Results...)
 		}
+		trace.recordStage("search", searchStart)
 
 		// Score and rank results by relevance to the original query
-		rankedResults := rankResultsByRelevance(allResults, userQuery)
+		rankStart := time.Now()
+		scoredResults := scoreResults(allResults, userQuery)
+		if searchOptions.SearchMode == webscrape.SearchModeNews {
+			sortByRecencyWithinBands(scoredResults)
+		}
 
 		// Limit to most relevant results
-		maxResults := 8
-		if len(rankedResults) > maxResults {
-			rankedResults = rankedResults[:maxResults]
+		rankedResults := make([]webscrape.PageInfo, 0, maxResults)
+		for i, scored := range scoredResults {
+			if i >= maxResults {
+				break
+			}
+			rankedResults = append(rankedResults, scored.result)
 		}
+		trace.recordRanking(scoredResults, maxResults)
+		searchInfo = noteSnippetOnly(searchInfo, rankedResults)
+		trace.recordStage("ranking", rankStart)
 
 		searchTimer.Stop()
 
+		// Render the system prompt from the template set for this model or key
+		promptData := prompts.NewData(userQuery, rankedResults)
+		promptData.Locale = requestLocale(r)
+		promptData.History = conversationHistory(chatReq.Messages)
+		promptData.Mode = searchOptions.SearchMode
+		promptKind := prompts.KindSearch
+		if len(rankedResults) == 0 {
+			promptKind = prompts.KindNoResults
+		}
+		systemPrompt, err := prompts.Render(modelName, extractAPIKey(r), promptKind, promptData)
+		if err != nil {
+			utils.Error(fmt.Sprintf("Prompt rendering failed: %v", err))
+			WriteJSONError(w, http.StatusInternalServerError, "Prompt rendering error")
+			return
+		}
+		messages = append(messages, fmt.Sprintf("system: %s", systemPrompt))
+
 		if len(rankedResults) > 0 {
-			// Create system prompt with search context
-			systemPrompt := createSearchPromptTemplate(userQuery, rankedResults)
-			messages = append(messages, fmt.Sprintf("system: %s", systemPrompt))
 
 			// Extract citations
 			citationURLs = citations.ExtractCitationURLs(rankedResults)
@@ -188,19 +262,48 @@ func ChatCompletionsHandler(w http.ResponseWriter, r *http.Request) {
 			} else {
 				utils.Warn("No citations were extracted from search results")
 			}
-		} else {
-			// No results found, let LLM know
-			messages = append(messages, "system: No relevant search results were found for this query. Please respond based on your training data.")
 		}
+
+		// Report how well the sources back the answer
+		evidence = assessEvidence(userQuery, rankedResults, len(rankedResults) == 0)
 	}
 
 	// Generate response
+	trace.recordPrompt(messages)
+	trace.recordLLM(provider)
+	llmStart := time.Now()
 	response, err := provider.GenerateResponseWithOptions(messages, options)
 	if err != nil {
 		utils.Error(fmt.Sprintf("LLM call failed: %v", err))
 		WriteJSONError(w, http.StatusInternalServerError, fmt.Sprintf("LLM processing error: %v", err))
 		return
 	}
+	trace.recordStage("llm", llmStart)
+	trace.recordStage("total", requestStart)
+
+	requestID := utils.GenerateUUID()
+
+	// Stream the raw output; the streamer separates reasoning across chunk boundaries
+	if chatReq.Stream {
+		streamer, err := NewStreamingResponse(w, modelName, requestID, citationURLs)
+		if err == nil {
+			// The filter holds output back until it sees a tag, so only
+			// responses that carry one go through it
+			if llm.HasReasoning(response) {
+				streamer.EnableReasoningFilter(chatReq.ReturnReasoning)
+			}
+			streamer.SetFinalMetadata(evidence, searchInfo, trace.result())
+			StreamTokens(streamer, response, 16)
+			return
+		}
+		utils.Warn(fmt.Sprintf("Streaming unavailable, sending full response: %v", err))
+	}
+
+	// Separate <think> blocks from the answer
+	content, reasoning := llm.SplitReasoning(response)
+	if !chatReq.ReturnReasoning {
+		reasoning = ""
+	}
 
 	// Count tokens (simplified)
 	promptText := strings.Join(messages, " ")
@@ -209,7 +312,7 @@ func ChatCompletionsHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Prepare and send response
 	completionResponse := models.ChatCompletionResponse{
-		ID:        utils.GenerateUUID(),
+		ID:        requestID,
 		Model:     modelName,
 		Object:    "chat.completion",
 		Created:   time.Now().Unix(),
@@ -219,8 +322,9 @@ func ChatCompletionsHandler(w http.ResponseWriter, r *http.Request) {
 				Index:        0,
 				FinishReason: "stop",
 				Message: models.Message{
-					Role:    "assistant",
-					Content: response,
+					Role:      "assistant",
+					Content:   content,
+					Reasoning: reasoning,
 				},
 			},
 		},
@@ -229,26 +333,94 @@ func ChatCompletionsHandler(w http.ResponseWriter, r *http.Request) {
 			CompletionTokens: completionTokens,
 			TotalTokens:      promptTokens + completionTokens,
 		},
+		Evidence: evidence,
+		Search:   searchInfo,
+		Debug:    trace.result(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(completionResponse)
 }
 
+// adds the providers a search found blocked to info, creating it on the first block
+func noteBlockedProviders(info *models.SearchInfo, report webscrape.SearchReport) *models.SearchInfo {
+	if len(report.Blocked) == 0 {
+		return info
+	}
+	if info == nil {
+		info = &models.SearchInfo{}
+	}
+	for _, blocked := range report.Blocked {
+		if !containsString(info.BlockedProviders, blocked.Name) {
+			info.BlockedProviders = append(info.BlockedProviders, blocked.Name)
+		}
+	}
+	if report.FailedOver != "" {
+		info.FailoverProvider = report.Provider
+	}
+	return info
+}
+
+// records the cited results whose pages robots.txt disallowed, so clients
+// know they were cited from the search snippet alone
+func noteSnippetOnly(info *models.SearchInfo, results []webscrape.PageInfo) *models.SearchInfo {
+	for _, result := range results {
+		if !result.RobotsDisallowed {
+			continue
+		}
+		if info == nil {
+			info = &models.SearchInfo{}
+		}
+		if !containsString(info.SnippetOnly, result.URL) {
+			info.SnippetOnly = append(info.SnippetOnly, result.URL)
+		}
+	}
+	return info
+}
+
+// returns the primary locale from the Accept-Language header
+func requestLocale(r *http.Request) string {
+	header := r.Header.Get("Accept-Language")
+	if header == "" {
+		return "en-US"
+	}
+	locale := strings.Split(header, ",")[0]
+	locale = strings.TrimSpace(strings.Split(locale, ";")[0])
+	if locale == "" || locale == "*" {
+		return "en-US"
+	}
+	return locale
+}
+
+// returns the conversation before the last user message
+func conversationHistory(messages []models.Message) []prompts.Turn {
+	last := len(messages) - 1
+	for last >= 0 && messages[last].Role != "user" {
+		last--
+	}
+
+	history := make([]prompts.Turn, 0, len(messages))
+	for i := 0; i < last; i++ {
+		history = append(history, prompts.Turn{Role: messages[i].Role, Content: messages[i].Content})
+	}
+	return history
+}
+
 // breaks down a complex query into search-friendly queries
 func extractSearchQueries(query string) []string {
 	// could use NLP to extract key topics
 	return []string{query}
 }
 
-// scores and ranks results by relevance to the query
-func rankResultsByRelevance(results []webscrape.PageInfo, query string) []webscrape.PageInfo {
-	// Simple relevance scoring based on keyword presence
-	type scoredResult struct {
-		result webscrape.PageInfo
-		score  float64
-	}
+// a search result with its relevance score
+type scoredResult struct {
+	result webscrape.PageInfo
+	score  float64
+}
 
+// scores results by keyword relevance and sorts them, highest score first
+func scoreResults(results []webscrape.PageInfo, query string) []scoredResult {
+	// Simple relevance scoring based on keyword presence
 	// Create a list to hold scored results
 	scoredResults := make([]scoredResult, 0, len(results))
 
@@ -290,57 +462,37 @@ func rankResultsByRelevance(results []webscrape.PageInfo, query string) []webscr
 		})
 	}
 
-	// Sort results by score (descending)
+	// Sort results by score (descending), keeping instant answers on top
 	utils.SortScored(scoredResults, func(i, j int) bool {
+		if scoredResults[i].result.InstantAnswer != scoredResults[j].result.InstantAnswer {
+			return scoredResults[i].result.InstantAnswer
+		}
 		return scoredResults[i].score > scoredResults[j].score
 	})
 
-	// Extract just the results
-	rankedResults := make([]webscrape.PageInfo, 0, len(scoredResults))
-	for _, scored := range scoredResults {
-		rankedResults = append(rankedResults, scored.result)
-	}
-
-	return rankedResults
+	return scoredResults
 }
 
-// creates a system prompt incorporating search results
-func createSearchPromptTemplate(query string, results []webscrape.PageInfo) string {
-	promptTemplate := `I'll help answer the question based on the web search results provided below.
+// relevanceBandWidth is the score range treated as equally relevant when
+// ordering news results by recency.
+const relevanceBandWidth = 2.0
 
-USER QUERY: %s
-
-WEB SEARCH RESULTS:
-%s
-
-INSTRUCTIONS:
-1. Use ONLY the information from these search results to answer the user's query
-2. If the search results don't contain relevant information, admit that you don't have enough information
-3. Provide a comprehensive answer that synthesizes information from multiple sources
-4. Include specific facts and details from the sources
-5. Cite sources using [1], [2], etc., corresponding to the search result numbers
-6. DO NOT make up or include information not present in these search results
-7. Maintain a helpful, informative, and accurate tone
-
-Your answer should be well-structured, accurate, and directly address the user's query.`
-
-	// Format the search results
-	searchResultsText := ""
-	for i, result := range results {
-		searchResultsText += fmt.Sprintf("[%d] %s\nURL: %s\n", i+1, result.Title, result.URL)
-		if result.Summary != "" {
-			searchResultsText += fmt.Sprintf("Summary: %s\n\n", result.Summary)
-		} else if result.Content != "" {
-			// Use truncated content if summary not available
-			content := result.Content
-			if len(content) > 300 {
-				content = content[:300] + "..."
-			}
-			searchResultsText += fmt.Sprintf("Content: %s\n\n", content)
+// reorders results scored into the same relevance band newest first, so a
+// fresh story beats an older one that merely repeats a query term more often
+func sortByRecencyWithinBands(scored []scoredResult) {
+	band := func(s scoredResult) int {
+		return int(math.Floor(s.score / relevanceBandWidth))
+	}
+	for start := 0; start < len(scored); {
+		end := start + 1
+		for end < len(scored) && band(scored[end]) == band(scored[start]) && scored[end].result.InstantAnswer == scored[start].result.InstantAnswer {
+			end++
 		}
+		utils.SortByRecency(scored[start:end], func(s scoredResult) int64 {
+			return s.result.Published.Unix()
+		})
+		start = end
 	}
-
-	return fmt.Sprintf(promptTemplate, query, searchResultsText)
 }
 
 // creates a better formatted context for the LLM
@@ -373,12 +525,26 @@ func formatEnhancedSearchResults(results []webscrape.PageInfo, query string) str
 	return formattedResults
 }
 
+// lists the registered search providers, marking the default
+func SearchProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	defaultName := webscrape.DefaultProviderName()
+	list := models.SearchProviderList{Object: "list", Data: []models.SearchProviderInfo{}}
+	for _, name := range webscrape.SearchProviderNames() {
+		list.Data = append(list.Data, models.SearchProviderInfo{
+			ID:      name,
+			Object:  "search_provider",
+			Default: name == defaultName,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
 // ChatHandler handles legacy chat requests
 func ChatHandler(w http.ResponseWriter, r *http.Request) {
-	bodyBytes, err := io.ReadAll(r.Body)
-	if err != nil {
-		utils.Error(fmt.Sprintf("Failed to read request body: %v", err))
-		WriteJSONError(w, http.StatusBadRequest, "Unable to read request body")
+	bodyBytes, ok := readRequestBody(w, r)
+	if !ok {
 		return
 	}
 
@@ -423,17 +589,25 @@ func ChatHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// Format top 3 search results to pass as additional context
-		searchContext := formatSearchResults(results)
+		// Combine user's query with the top search results
+		promptData := prompts.NewData(chatReq.Query, results)
+		promptData.Locale = requestLocale(r)
+		prompt, err := prompts.Render(chatReq.Provider, extractAPIKey(r), prompts.KindChat, promptData)
+		if err != nil {
+			utils.Error(fmt.Sprintf("Prompt rendering failed: %v", err))
+			WriteJSONError(w, http.StatusInternalServerError, "Prompt rendering error")
+			return
+		}
 
-		// Combine user's query with search context
-		response, err := provider.GenerateResponse(chatReq.Query + "\n\n" + searchContext)
+		response, err := provider.GenerateResponse(prompt)
 		if err != nil {
 			utils.Error(fmt.Sprintf("LLM call failed: %v", err))
 			WriteJSONError(w, http.StatusInternalServerError, fmt.Sprintf("LLM processing error: %v", err))
 			return
 		}
 
+		response, _ = llm.SplitReasoning(response)
+
 		jsonResponse := map[string]interface{}{
 			"decision":   "search + LLM call",
 			"pages_used": len(results),
@@ -462,6 +636,8 @@ func ChatHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	response, _ = llm.SplitReasoning(response)
+
 	// Return JSON response
 	jsonResponse := map[string]interface{}{
 		"decision": "direct LLM call",
@@ -472,19 +648,6 @@ func ChatHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 
-// formats up to 3 results for LLM context.
-func formatSearchResults(results []webscrape.PageInfo) string {
-	formatted := ""
-	for i, res := range results {
-		formatted += fmt.Sprintf("- %s (%s)\n", res.Title, res.URL)
-		if i == 2 {
-			break
-		}
-	}
-	return formatted
-}
-
-
 // writes a structured JSON error to the response.
 func WriteJSONError(w http.ResponseWriter, code int, message string) {
 	w.Header().Set("Content-Type", "application/json")
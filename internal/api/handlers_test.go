@@ -7,8 +7,10 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"open-sonar/internal/models"
+	"open-sonar/internal/search/webscrape"
 )
 
 func TestMain(m *testing.M) {
@@ -168,6 +170,46 @@ func TestChatCompletionsHandler(t *testing.T) {
 			bearerToken:    "valid-token",
 			expectedStatus: http.StatusBadRequest,
 		},
+		{
+			name: "invalid search provider spec",
+			requestBody: models.ChatCompletionRequest{
+				Model:           "sonar",
+				Messages:        []models.Message{{Role: "user", Content: "Hello world"}},
+				SearchProviders: []string{"duckduckgo", "searxng:heavy"},
+			},
+			bearerToken:    "valid-token",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "unknown search provider",
+			requestBody: models.ChatCompletionRequest{
+				Model:          "sonar",
+				Messages:       []models.Message{{Role: "user", Content: "Hello world"}},
+				SearchProvider: "altavista",
+			},
+			bearerToken:    "valid-token",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "invalid search mode",
+			requestBody: models.ChatCompletionRequest{
+				Model:      "sonar",
+				Messages:   []models.Message{{Role: "user", Content: "Hello world"}},
+				SearchMode: "images",
+			},
+			bearerToken:    "valid-token",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "explicit search provider",
+			requestBody: models.ChatCompletionRequest{
+				Model:          "mock",
+				Messages:       []models.Message{{Role: "user", Content: "test query"}},
+				SearchProvider: "mock",
+			},
+			bearerToken:    "valid-token",
+			expectedStatus: http.StatusOK,
+		},
 		{
 			name:           "invalid json",
 			requestBody:    "invalid json",
@@ -256,3 +298,59 @@ func TestChatCompletionsHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestSearchProvidersHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", "/search/providers", nil)
+	rr := httptest.NewRecorder()
+	SearchProvidersHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	var list models.SearchProviderList
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Invalid JSON response: %v", err)
+	}
+
+	defaults := 0
+	ids := map[string]bool{}
+	for _, provider := range list.Data {
+		ids[provider.ID] = true
+		if provider.Default {
+			defaults++
+			if provider.ID != webscrape.DefaultProviderName() {
+				t.Errorf("Expected %s to be the default, got %s", webscrape.DefaultProviderName(), provider.ID)
+			}
+		}
+	}
+	if defaults != 1 {
+		t.Errorf("Expected exactly one default provider, got %d", defaults)
+	}
+	if !ids["duckduckgo"] || !ids["searxng"] {
+		t.Errorf("Expected built-in providers in list, got %+v", list.Data)
+	}
+}
+
+func TestSortByRecencyWithinBands(t *testing.T) {
+	now := time.Now()
+	result := func(url string, age time.Duration) webscrape.PageInfo {
+		return webscrape.PageInfo{URL: url, Published: now.Add(-age)}
+	}
+	scored := []scoredResult{
+		{result: result("old-strong", 48*time.Hour), score: 7},
+		{result: result("new-strong", time.Hour), score: 6.5},
+		{result: result("old-weak", 72*time.Hour), score: 3},
+		{result: result("new-weak", 2*time.Hour), score: 2},
+		{result: result("newest-irrelevant", 0), score: 1},
+	}
+
+	sortByRecencyWithinBands(scored)
+
+	expected := []string{"new-strong", "old-strong", "new-weak", "old-weak", "newest-irrelevant"}
+	for i, url := range expected {
+		if scored[i].result.URL != url {
+			t.Errorf("Position %d: expected %s, got %s", i, url, scored[i].result.URL)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"open-sonar/internal/utils"
+)
+
+// DefaultMaxRequestBytes caps the size of a request body.
+const DefaultMaxRequestBytes = 1024 * 1024
+
+// returns the request body size limit from MAX_REQUEST_BYTES
+func maxRequestBytes() int64 {
+	value := utils.GetEnvWithDefault("MAX_REQUEST_BYTES", "")
+	if value == "" {
+		return DefaultMaxRequestBytes
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 {
+		utils.Warn(fmt.Sprintf("Invalid MAX_REQUEST_BYTES %q, using %d", value, DefaultMaxRequestBytes))
+		return DefaultMaxRequestBytes
+	}
+	return n
+}
+
+// reads a request body of at most MAX_REQUEST_BYTES. On failure it writes
+// the error response, 413 for an oversized body, and returns false.
+func readRequestBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	limit := maxRequestBytes()
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.Error(fmt.Sprintf("Request body larger than %d bytes", limit))
+			WriteJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds %d bytes", limit))
+			return nil, false
+		}
+		utils.Error(fmt.Sprintf("Failed to read request body: %v", err))
+		WriteJSONError(w, http.StatusBadRequest, "Unable to read request body")
+		return nil, false
+	}
+	return body, true
+}
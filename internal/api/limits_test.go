@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestBodyLimit(t *testing.T) {
+	t.Setenv("MAX_REQUEST_BYTES", "64")
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+	}{
+		{"chat completions", ChatCompletionsHandler, `{"model": "sonar", "messages": [{"role": "user", "content": "` + strings.Repeat("a", 100) + `"}]}`},
+		{"legacy chat", ChatHandler, `{"query": "` + strings.Repeat("a", 100) + `"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer test-token")
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("Expected status %d, got %d: %s", http.StatusRequestEntityTooLarge, rr.Code, rr.Body.String())
+			}
+			if !strings.Contains(rr.Body.String(), "exceeds 64 bytes") {
+				t.Errorf("Expected the limit in the error, got %s", rr.Body.String())
+			}
+		})
+	}
+
+	// A body within the limit gets past the size check
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"query": ""}`))
+	rr := httptest.NewRecorder()
+	ChatHandler(rr, req)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "Query field required") {
+		t.Errorf("Expected the small body to be parsed, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -28,9 +28,15 @@ var (
 	// Request cache with 5-minute TTL
 	responseCache = cache.New()
 
+	// GET endpoints that must always be served fresh
+	uncachedPaths = map[string]bool{"/debug/vars": true}
+
 	// Set of valid API keys (for simple auth)
 	validAPIKeys     = make(map[string]bool)
 	validAPIKeyMutex sync.RWMutex
+
+	// Subset of API keys allowed to use admin-only features such as debug traces
+	adminAPIKeys = make(map[string]bool)
 )
 
 func init() {
@@ -40,6 +46,10 @@ func init() {
 		validAPIKeys[apiKey] = true
 		validAPIKeyMutex.Unlock()
 	}
+	adminKey := os.Getenv("ADMIN_AUTH_TOKEN")
+	if adminKey != "" {
+		AddAdminAPIKey(adminKey)
+	}
 }
 
 // adds a new API key at runtime
@@ -49,6 +59,22 @@ func AddAPIKey(key string) {
 	validAPIKeyMutex.Unlock()
 }
 
+// adds a new admin API key at runtime; admin keys are also valid API keys
+func AddAdminAPIKey(key string) {
+	validAPIKeyMutex.Lock()
+	validAPIKeys[key] = true
+	adminAPIKeys[key] = true
+	validAPIKeyMutex.Unlock()
+}
+
+// checks if the API key grants admin access
+func IsAdminAPIKey(key string) bool {
+	validAPIKeyMutex.RLock()
+	defer validAPIKeyMutex.RUnlock()
+
+	return key != "" && adminAPIKeys[key]
+}
+
 // checks if the API key is valid
 func IsValidAPIKey(key string) bool {
 	validAPIKeyMutex.RLock()
@@ -119,8 +145,8 @@ func AuthMiddleware(next http.Handler) http.Handler {
 // caches responses for GET requests
 func CacheMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Only cache GET requests
-		if r.Method != "GET" {
+		// Only cache GET requests, leaving out live metrics
+		if r.Method != "GET" || uncachedPaths[r.URL.Path] {
 			next.ServeHTTP(w, r)
 			return
 		}
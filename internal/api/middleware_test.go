@@ -269,6 +269,42 @@ func TestCacheMiddleware(t *testing.T) {
 	}
 }
 
+func TestDebugVarsRequiresAdmin(t *testing.T) {
+	validAPIKeyMutex.Lock()
+	originalKeys, originalAdminKeys := validAPIKeys, adminAPIKeys
+	validAPIKeys, adminAPIKeys = make(map[string]bool), make(map[string]bool)
+	validAPIKeyMutex.Unlock()
+	defer func() {
+		validAPIKeyMutex.Lock()
+		validAPIKeys, adminAPIKeys = originalKeys, originalAdminKeys
+		validAPIKeyMutex.Unlock()
+	}()
+	AddAPIKey("user-token")
+	AddAdminAPIKey("admin-token")
+
+	router := SetupRoutes()
+	get := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/debug/vars", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	if recorder := get("user-token"); recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a non-admin key, got %d", recorder.Code)
+	}
+	for i := 0; i < 2; i++ {
+		recorder := get("admin-token")
+		if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "search_blocks") {
+			t.Errorf("Expected the metrics for an admin key, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+		if recorder.Header().Get("X-Cache") == "HIT" {
+			t.Errorf("Expected metrics never served from the cache")
+		}
+	}
+}
+
 func TestExtractAPIKey(t *testing.T) {
 	tests := []struct {
 		name        string
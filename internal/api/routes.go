@@ -1,6 +1,7 @@
 package api
 
 import (
+	"expvar"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -25,6 +26,12 @@ func SetupRoutes() *mux.Router {
 	r.HandleFunc("/chat", ChatHandler).Methods("POST")
 	r.HandleFunc("/chat/completions", ChatCompletionsHandler).Methods("POST")
 
+	// Search provider listing
+	r.HandleFunc("/search/providers", SearchProvidersHandler).Methods("GET")
+
+	// Runtime metrics, including search blocks and failovers
+	r.HandleFunc("/debug/vars", DebugVarsHandler).Methods("GET")
+
 	// Add OPTIONS methods for CORS preflight requests
 	r.HandleFunc("/chat", OptionsHandler).Methods("OPTIONS")
 	r.HandleFunc("/chat/completions", OptionsHandler).Methods("OPTIONS")
@@ -32,6 +39,16 @@ func SetupRoutes() *mux.Router {
 	return r
 }
 
+// DebugVarsHandler serves the expvar metrics to admins. They include the
+// command line and memory statistics, so other keys are refused.
+func DebugVarsHandler(w http.ResponseWriter, r *http.Request) {
+	if !IsAdminAPIKey(extractAPIKey(r)) {
+		WriteJSONError(w, http.StatusForbidden, "Forbidden: metrics require an admin API key")
+		return
+	}
+	expvar.Handler().ServeHTTP(w, r)
+}
+
 // OptionsHandler handles CORS preflight requests
 func OptionsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
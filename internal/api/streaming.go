@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+	"unicode/utf8"
 
+	"open-sonar/internal/llm"
 	"open-sonar/internal/models"
 	"open-sonar/internal/utils"
 )
@@ -18,6 +20,15 @@ type StreamingResponse struct {
 	model     string
 	created   int64
 	citations []string
+
+	// Sent with the final chunk, like citations
+	evidence *models.Evidence
+	search   *models.SearchInfo
+	debug    *models.DebugTrace
+
+	// Optional reasoning separation for thinking models
+	reasoningFilter  *llm.ReasoningFilter
+	includeReasoning bool
 }
 
 // NewStreamingResponse creates a new streaming response handler
@@ -43,10 +54,44 @@ func NewStreamingResponse(w http.ResponseWriter, model string, requestID string,
 	}, nil
 }
 
+// EnableReasoningFilter strips <think> blocks from streamed content,
+// optionally forwarding them in the delta's reasoning field
+func (s *StreamingResponse) EnableReasoningFilter(includeReasoning bool) {
+	s.reasoningFilter = llm.NewReasoningFilter()
+	s.includeReasoning = includeReasoning
+}
+
+// SetFinalMetadata attaches the evidence assessment, search details and
+// debug trace to the final chunk
+func (s *StreamingResponse) SetFinalMetadata(evidence *models.Evidence, search *models.SearchInfo, debug *models.DebugTrace) {
+	s.evidence = evidence
+	s.search = search
+	s.debug = debug
+}
+
 // SendChunk sends a content chunk in the stream
 func (s *StreamingResponse) SendChunk(content string, index int, isFirst, isLast bool) error {
+	var reasoning string
+	if s.reasoningFilter != nil {
+		content, reasoning = s.reasoningFilter.Write(content)
+		if isLast {
+			flushedContent, flushedReasoning := s.reasoningFilter.Flush()
+			content += flushedContent
+			reasoning += flushedReasoning
+		}
+		if !s.includeReasoning {
+			reasoning = ""
+		}
+
+		// Nothing to emit yet, e.g. a partial tag is being held back
+		if content == "" && reasoning == "" && !isFirst && !isLast {
+			return nil
+		}
+	}
+
 	delta := models.Delta{
-		Content: content,
+		Content:   content,
+		Reasoning: reasoning,
 	}
 
 	// Add role only to first message
@@ -73,9 +118,14 @@ func (s *StreamingResponse) SendChunk(content string, index int, isFirst, isLast
 		Choices: []models.Choice{choice},
 	}
 
-	// Add citations to the final chunk only
-	if isLast && len(s.citations) > 0 {
-		response.Citations = s.citations
+	// Add citations and metadata to the final chunk only
+	if isLast {
+		if len(s.citations) > 0 {
+			response.Citations = s.citations
+		}
+		response.Evidence = s.evidence
+		response.Search = s.search
+		response.Debug = s.debug
 	}
 
 	// Serialize to JSON
@@ -107,14 +157,18 @@ func (s *StreamingResponse) SendFinal() error {
 
 // StreamTokens chunked output with artificial pauses for realistic streaming
 func StreamTokens(streamer *StreamingResponse, content string, chunkSize int) error {
-	// Split content into chunks
+	// Split content into chunks, never inside a UTF-8 sequence
 	var chunks []string
-	for i := 0; i < len(content); i += chunkSize {
+	for i := 0; i < len(content); {
 		end := i + chunkSize
 		if end > len(content) {
 			end = len(content)
 		}
+		for end < len(content) && !utf8.RuneStart(content[end]) {
+			end++
+		}
 		chunks = append(chunks, content[i:end])
+		i = end
 	}
 
 	// Stream each chunk with a small delay
@@ -1,11 +1,14 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"open-sonar/internal/models"
 )
 
 // customFlusher implements the http.Flusher interface for testing
@@ -231,3 +234,138 @@ func TestStreamTokens(t *testing.T) {
 		t.Error("Expected [DONE] message in output")
 	}
 }
+
+func TestStreamTokensWithReasoningFilter(t *testing.T) {
+	w := newCustomResponseWriter()
+
+	s, err := NewStreamingResponse(w, "test-model", "test-id", nil)
+	if err != nil {
+		t.Fatalf("Error creating streaming response: %v", err)
+	}
+	s.EnableReasoningFilter(true)
+
+	// Small chunks so the tags span chunk boundaries
+	content := "<think>Count the planets.</think>\n\nThere are eight planets."
+	if err := StreamTokens(s, content, 3); err != nil {
+		t.Fatalf("Error streaming tokens: %v", err)
+	}
+
+	var answer, reasoning string
+	for _, chunk := range extractChunks(w.Body.String()) {
+		choices, ok := chunk["choices"].([]interface{})
+		if !ok || len(choices) == 0 {
+			continue
+		}
+		delta := choices[0].(map[string]interface{})["delta"].(map[string]interface{})
+		if c, exists := delta["content"]; exists {
+			answer += c.(string)
+		}
+		if r, exists := delta["reasoning"]; exists {
+			reasoning += r.(string)
+		}
+	}
+
+	if answer != "There are eight planets." {
+		t.Errorf("Expected reasoning stripped from content, got: %q", answer)
+	}
+	if reasoning != "Count the planets." {
+		t.Errorf("Expected reasoning in separate field, got: %q", reasoning)
+	}
+}
+
+func TestStreamTokensKeepsRunesWhole(t *testing.T) {
+	w := newCustomResponseWriter()
+	s, err := NewStreamingResponse(w, "test-model", "test-id", []string{"url1"})
+	if err != nil {
+		t.Fatalf("Error creating streaming response: %v", err)
+	}
+	evidence := &models.Evidence{Confidence: models.ConfidenceHigh}
+	s.SetFinalMetadata(evidence, &models.SearchInfo{BlockedProviders: []string{"duckduckgo"}}, nil)
+
+	// Chunk sizes that would cut the three-byte characters
+	content := "東京は日本の首都です。Tokyo — café."
+	if err := StreamTokens(s, content, 4); err != nil {
+		t.Fatalf("Error streaming tokens: %v", err)
+	}
+
+	chunks := extractChunks(w.Body.String())
+	var reconstructed string
+	for i, chunk := range chunks {
+		delta := chunk["choices"].([]interface{})[0].(map[string]interface{})["delta"].(map[string]interface{})
+		if c, exists := delta["content"]; exists {
+			piece := c.(string)
+			if strings.ContainsRune(piece, '�') {
+				t.Errorf("Chunk %d cut a character: %q", i, piece)
+			}
+			reconstructed += piece
+		}
+		_, hasEvidence := chunk["evidence"]
+		_, hasSearch := chunk["search"]
+		if last := i == len(chunks)-1; hasEvidence != last || hasSearch != last {
+			t.Errorf("Chunk %d: expected evidence and search only on the final chunk, got %v", i, chunk)
+		}
+	}
+	if reconstructed != content {
+		t.Errorf("Expected %q, got %q", content, reconstructed)
+	}
+}
+
+func TestStreamTokensWithClosingTagOnly(t *testing.T) {
+	w := newCustomResponseWriter()
+	s, err := NewStreamingResponse(w, "test-model", "test-id", nil)
+	if err != nil {
+		t.Fatalf("Error creating streaming response: %v", err)
+	}
+	s.EnableReasoningFilter(false)
+
+	// The chat template opened the block in the prompt
+	if err := StreamTokens(s, "Count the planets.\n</think>\n\nThere are eight planets.", 5); err != nil {
+		t.Fatalf("Error streaming tokens: %v", err)
+	}
+
+	var answer string
+	for _, chunk := range extractChunks(w.Body.String()) {
+		delta := chunk["choices"].([]interface{})[0].(map[string]interface{})["delta"].(map[string]interface{})
+		if c, exists := delta["content"]; exists {
+			answer += c.(string)
+		}
+		if _, exists := delta["reasoning"]; exists {
+			t.Errorf("Expected no reasoning without return_reasoning, got %v", delta)
+		}
+	}
+	if answer != "There are eight planets." {
+		t.Errorf("Expected the reasoning and closing tag left out, got %q", answer)
+	}
+}
+
+func TestChatCompletionsStreamsUntaggedContent(t *testing.T) {
+	reqBody, err := json.Marshal(models.ChatCompletionRequest{
+		Model:    "mock",
+		Messages: []models.Message{{Role: "user", Content: "What is the capital of France?"}},
+		Stream:   true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal request body: %v", err)
+	}
+	req, err := http.NewRequest("POST", "/chat/completions", bytes.NewBuffer(reqBody))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer valid-token")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(ChatCompletionsHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// A response without reasoning tags streams as it goes, not all at the end
+	chunks := extractChunks(rr.Body.String())
+	if len(chunks) < 2 {
+		t.Fatalf("Expected several chunks, got %d", len(chunks))
+	}
+	delta := chunks[0]["choices"].([]interface{})[0].(map[string]interface{})["delta"].(map[string]interface{})
+	if content, _ := delta["content"].(string); content == "" {
+		t.Errorf("Expected content in the first chunk, got %v", delta)
+	}
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"open-sonar/internal/llm"
+	"open-sonar/internal/models"
+	"open-sonar/internal/proxy"
+	"open-sonar/internal/search/webscrape"
+)
+
+// traceRecorder collects a debug trace of the completion pipeline.
+// A nil recorder is valid and records nothing, so the handler can call it unconditionally.
+type traceRecorder struct {
+	trace         models.DebugTrace
+	proxiesBefore []proxy.Usage // proxy counts when the request started
+}
+
+// returns a recorder when debugging is enabled, nil otherwise
+func newTraceRecorder(enabled bool) *traceRecorder {
+	if !enabled {
+		return nil
+	}
+	return &traceRecorder{
+		trace: models.DebugTrace{
+			SearchQueries:   []string{},
+			SearchProviders: []string{},
+			Results:         []models.TraceResult{},
+			Timings:         []models.StageTiming{},
+		},
+		proxiesBefore: proxy.Snapshot(),
+	}
+}
+
+// records a search query, its provider, and every result it returned or dropped
+func (t *traceRecorder) recordSearch(query string, report webscrape.SearchReport) {
+	if t == nil {
+		return
+	}
+
+	t.trace.SearchQueries = append(t.trace.SearchQueries, query)
+	if len(report.Providers) > 0 {
+		// Fan-out search: record each provider's outcome separately
+		for _, pr := range report.Providers {
+			if !containsString(t.trace.SearchProviders, pr.Name) {
+				t.trace.SearchProviders = append(t.trace.SearchProviders, pr.Name)
+			}
+			if pr.Err != nil {
+				t.trace.SearchErrors = append(t.trace.SearchErrors, fmt.Sprintf("%s: %v", pr.Name, pr.Err))
+			}
+			t.trace.Timings = append(t.trace.Timings, models.StageTiming{
+				Stage:      "search:" + pr.Name,
+				DurationMs: float64(pr.Duration.Microseconds()) / 1000,
+			})
+		}
+	} else {
+		// Blocked providers; when the search failed, the last error is recorded below
+		for _, blocked := range report.Blocked {
+			if !containsString(t.trace.SearchProviders, blocked.Name) {
+				t.trace.SearchProviders = append(t.trace.SearchProviders, blocked.Name)
+			}
+			if blocked.Name != report.Provider || report.Err == nil {
+				t.trace.SearchErrors = append(t.trace.SearchErrors, fmt.Sprintf("%s: %v", blocked.Name, blocked.Err))
+			}
+		}
+		if !containsString(t.trace.SearchProviders, report.Provider) {
+			t.trace.SearchProviders = append(t.trace.SearchProviders, report.Provider)
+		}
+		if report.Err != nil {
+			t.trace.SearchErrors = append(t.trace.SearchErrors, fmt.Sprintf("%s: %v", report.Provider, report.Err))
+		}
+	}
+
+	if report.Fetch.Fetched+report.Fetch.Failed > 0 {
+		t.trace.Timings = append(t.trace.Timings, models.StageTiming{
+			Stage:      "fetch",
+			DurationMs: float64(report.Fetch.Duration.Microseconds()) / 1000,
+		})
+	}
+
+	for _, result := range report.Results {
+		provider := report.Provider
+		if sources := report.ResultProviders[result.URL]; len(sources) > 0 {
+			provider = strings.Join(sources, ",")
+		}
+		t.trace.Results = append(t.trace.Results, models.TraceResult{
+			URL:         result.URL,
+			Title:       result.Title,
+			Query:       query,
+			Provider:    provider,
+			SnippetOnly: result.RobotsDisallowed,
+		})
+	}
+	for _, dropped := range report.Dropped {
+		t.trace.Results = append(t.trace.Results, models.TraceResult{
+			URL:        dropped.Result.URL,
+			Title:      dropped.Result.Title,
+			Query:      query,
+			Provider:   report.Provider,
+			DropReason: dropped.Reason,
+		})
+	}
+}
+
+// records relevance scores and ranks; only the top maxResults are used
+func (t *traceRecorder) recordRanking(scored []scoredResult, maxResults int) {
+	if t == nil {
+		return
+	}
+
+	for i, s := range scored {
+		entry := t.findUnranked(s.result.URL)
+		if entry == nil {
+			continue
+		}
+		entry.Score = s.score
+		entry.Rank = i + 1
+		if i < maxResults {
+			entry.Used = true
+		} else {
+			entry.DropReason = fmt.Sprintf("ranked below top %d", maxResults)
+		}
+	}
+}
+
+// records the exact messages sent to the LLM
+func (t *traceRecorder) recordPrompt(messages []string) {
+	if t == nil {
+		return
+	}
+	t.trace.Prompt = append([]string(nil), messages...)
+}
+
+// records which provider and model answered
+func (t *traceRecorder) recordLLM(provider llm.LLMProvider) {
+	if t == nil {
+		return
+	}
+	t.trace.LLMProvider, t.trace.LLMModel = llm.DescribeProvider(provider)
+}
+
+// records the time elapsed since start for a pipeline stage
+func (t *traceRecorder) recordStage(stage string, start time.Time) {
+	if t == nil {
+		return
+	}
+	t.trace.Timings = append(t.trace.Timings, models.StageTiming{
+		Stage:      stage,
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+	})
+}
+
+// returns the collected trace, or nil when debugging is disabled
+func (t *traceRecorder) result() *models.DebugTrace {
+	if t == nil {
+		return nil
+	}
+	t.trace.Proxies = nil
+	for _, usage := range proxy.Since(t.proxiesBefore) {
+		t.trace.Proxies = append(t.trace.Proxies, models.TraceProxy{
+			Traffic:  string(usage.Traffic),
+			Proxy:    usage.Proxy,
+			Requests: usage.Requests,
+			Failures: usage.Failures,
+			Ejected:  usage.Ejected,
+		})
+	}
+	return &t.trace
+}
+
+// finds the first kept result with the given URL that has not been ranked yet
+func (t *traceRecorder) findUnranked(url string) *models.TraceResult {
+	for i := range t.trace.Results {
+		entry := &t.trace.Results[i]
+		if entry.URL == url && entry.Rank == 0 && entry.DropReason == "" {
+			return entry
+		}
+	}
+	return nil
+}
+
+// reports whether list contains value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,324 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"open-sonar/internal/llm"
+	"open-sonar/internal/models"
+	"open-sonar/internal/proxy"
+	"open-sonar/internal/search/webscrape"
+)
+
+func TestChatCompletionsDebugTrace(t *testing.T) {
+	// Use mocks for both search and LLM so the sonar pipeline runs offline
+	oldSearch := webscrape.SetGetSearchProvider(func(provider string) (webscrape.SearchProvider, error) {
+		return webscrape.NewMockSearchProvider(), nil
+	})
+	defer webscrape.SetGetSearchProvider(oldSearch)
+	oldLLM := llm.SetLLMProvider(func(provider string) (llm.LLMProvider, error) {
+		return llm.NewMockLLMProvider()
+	})
+	defer llm.SetLLMProvider(oldLLM)
+
+	// Register test keys and restore the original key sets when done
+	validAPIKeyMutex.Lock()
+	originalKeys, originalAdminKeys := validAPIKeys, adminAPIKeys
+	validAPIKeys, adminAPIKeys = make(map[string]bool), make(map[string]bool)
+	validAPIKeyMutex.Unlock()
+	defer func() {
+		validAPIKeyMutex.Lock()
+		validAPIKeys, adminAPIKeys = originalKeys, originalAdminKeys
+		validAPIKeyMutex.Unlock()
+	}()
+	AddAdminAPIKey("admin-token")
+	AddAPIKey("user-token")
+
+	request := models.ChatCompletionRequest{
+		Model: "sonar",
+		Messages: []models.Message{
+			{Role: "user", Content: "test query"},
+		},
+		SearchDomainFilter: []string{"example.com"},
+		Debug:              true,
+	}
+
+	tests := []struct {
+		name           string
+		token          string
+		expectedStatus int
+	}{
+		{name: "admin key", token: "admin-token", expectedStatus: http.StatusOK},
+		{name: "regular key", token: "user-token", expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reqBody, err := json.Marshal(request)
+			if err != nil {
+				t.Fatalf("Failed to marshal request body: %v", err)
+			}
+
+			req, err := http.NewRequest("POST", "/chat/completions", bytes.NewBuffer(reqBody))
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(ChatCompletionsHandler).ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+
+			var resp models.ChatCompletionResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Invalid JSON response: %v", err)
+			}
+			if resp.Debug == nil {
+				t.Fatal("Expected debug trace in response")
+			}
+
+			trace := resp.Debug
+			if len(trace.SearchQueries) != 1 || trace.SearchQueries[0] != "test query" {
+				t.Errorf("Unexpected search queries: %v", trace.SearchQueries)
+			}
+			if len(trace.Results) != 3 {
+				t.Errorf("Expected 3 traced results, got %d", len(trace.Results))
+			}
+			for _, result := range trace.Results {
+				if !result.Used || result.Rank == 0 || result.Score == 0 {
+					t.Errorf("Expected ranked, used result, got %+v", result)
+				}
+			}
+			if len(trace.Prompt) == 0 {
+				t.Error("Expected final prompt in trace")
+			}
+			if trace.LLMProvider != "mock" {
+				t.Errorf("Expected llm provider 'mock', got %q", trace.LLMProvider)
+			}
+			stages := map[string]bool{}
+			for _, timing := range trace.Timings {
+				stages[timing.Stage] = true
+			}
+			for _, stage := range []string{"search", "ranking", "llm", "total"} {
+				if !stages[stage] {
+					t.Errorf("Missing timing for stage %q", stage)
+				}
+			}
+		})
+	}
+}
+
+func TestChatCompletionsDebugTraceStreamed(t *testing.T) {
+	oldSearch := webscrape.SetGetSearchProvider(func(provider string) (webscrape.SearchProvider, error) {
+		return webscrape.NewMockSearchProvider(), nil
+	})
+	defer webscrape.SetGetSearchProvider(oldSearch)
+	oldLLM := llm.SetLLMProvider(func(provider string) (llm.LLMProvider, error) {
+		return llm.NewMockLLMProvider()
+	})
+	defer llm.SetLLMProvider(oldLLM)
+
+	validAPIKeyMutex.Lock()
+	originalKeys, originalAdminKeys := validAPIKeys, adminAPIKeys
+	validAPIKeys, adminAPIKeys = make(map[string]bool), make(map[string]bool)
+	validAPIKeyMutex.Unlock()
+	defer func() {
+		validAPIKeyMutex.Lock()
+		validAPIKeys, adminAPIKeys = originalKeys, originalAdminKeys
+		validAPIKeyMutex.Unlock()
+	}()
+	AddAdminAPIKey("admin-token")
+
+	reqBody, err := json.Marshal(models.ChatCompletionRequest{
+		Model:    "sonar",
+		Messages: []models.Message{{Role: "user", Content: "test query"}},
+		Stream:   true,
+		Debug:    true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal request body: %v", err)
+	}
+	req, err := http.NewRequest("POST", "/chat/completions", bytes.NewBuffer(reqBody))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer admin-token")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(ChatCompletionsHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	chunks := extractChunks(rr.Body.String())
+	if len(chunks) == 0 {
+		t.Fatalf("Expected streamed chunks, got %s", rr.Body.String())
+	}
+	for i, chunk := range chunks {
+		debug, ok := chunk["debug"].(map[string]interface{})
+		if i < len(chunks)-1 {
+			if ok {
+				t.Errorf("Expected the trace only on the final chunk, got it on chunk %d", i)
+			}
+			continue
+		}
+		if !ok || debug["llm_provider"] != "mock" {
+			t.Errorf("Expected the trace on the final chunk, got %v", chunk)
+		}
+	}
+}
+
+func TestTraceRecorderDroppedResults(t *testing.T) {
+	trace := newTraceRecorder(true)
+	trace.recordSearch("q", webscrape.SearchReport{
+		Provider: "mock",
+		Results: []webscrape.PageInfo{
+			{URL: "https://a.example/1"},
+			{URL: "https://b.example/2"},
+		},
+		Dropped: []webscrape.DroppedResult{
+			{Result: webscrape.PageInfo{URL: "https://c.example/3"}, Reason: "blocked by domain filter"},
+		},
+	})
+	trace.recordRanking([]scoredResult{
+		{result: webscrape.PageInfo{URL: "https://b.example/2"}, score: 3},
+		{result: webscrape.PageInfo{URL: "https://a.example/1"}, score: 1},
+	}, 1)
+
+	results := trace.result().Results
+	if results[1].Rank != 1 || !results[1].Used {
+		t.Errorf("Expected top result to be used with rank 1, got %+v", results[1])
+	}
+	if results[0].Used || results[0].DropReason != "ranked below top 1" {
+		t.Errorf("Expected second result dropped by ranking, got %+v", results[0])
+	}
+	if results[2].DropReason != "blocked by domain filter" {
+		t.Errorf("Expected filter drop reason, got %+v", results[2])
+	}
+
+	// A disabled recorder is nil and safe to use
+	disabled := newTraceRecorder(false)
+	disabled.recordStage("search", time.Now())
+	if disabled.result() != nil {
+		t.Error("Expected nil trace when debugging is disabled")
+	}
+}
+
+func TestTraceRecorderFanOut(t *testing.T) {
+	trace := newTraceRecorder(true)
+	trace.recordSearch("q", webscrape.SearchReport{
+		Provider: "duckduckgo+searxng",
+		Results:  []webscrape.PageInfo{{URL: "https://a.example/1"}},
+		Providers: []webscrape.ProviderReport{
+			{Name: "duckduckgo", Results: 1, Duration: 20 * time.Millisecond},
+			{Name: "searxng", Duration: 3 * time.Second, Err: errors.New("timed out after 3s")},
+		},
+		ResultProviders: map[string][]string{"https://a.example/1": {"duckduckgo"}},
+	})
+
+	result := trace.result()
+	if len(result.SearchProviders) != 2 || result.SearchProviders[1] != "searxng" {
+		t.Errorf("Expected each fan-out provider listed, got %v", result.SearchProviders)
+	}
+	if len(result.SearchErrors) != 1 || result.SearchErrors[0] != "searxng: timed out after 3s" {
+		t.Errorf("Expected the timeout to be recorded, got %v", result.SearchErrors)
+	}
+	if result.Results[0].Provider != "duckduckgo" {
+		t.Errorf("Expected result attributed to its provider, got %q", result.Results[0].Provider)
+	}
+	if len(result.Timings) != 2 || result.Timings[1].Stage != "search:searxng" || result.Timings[1].DurationMs != 3000 {
+		t.Errorf("Expected per-provider timings, got %+v", result.Timings)
+	}
+}
+
+func TestTraceRecorderFailover(t *testing.T) {
+	blockErr := &webscrape.BlockedError{Provider: "duckduckgo", StatusCode: 202, Reason: "anomaly page"}
+	report := webscrape.SearchReport{
+		Provider:   "searxng",
+		Results:    []webscrape.PageInfo{{URL: "https://a.example/1"}},
+		Blocked:    []webscrape.ProviderReport{{Name: "duckduckgo", Err: blockErr}},
+		FailedOver: "duckduckgo",
+	}
+
+	trace := newTraceRecorder(true)
+	trace.recordSearch("q", report)
+	result := trace.result()
+	if len(result.SearchProviders) != 2 || result.SearchProviders[0] != "duckduckgo" || result.SearchProviders[1] != "searxng" {
+		t.Errorf("Expected the blocked and failover providers, got %v", result.SearchProviders)
+	}
+	if len(result.SearchErrors) != 1 || result.SearchErrors[0] != "duckduckgo: duckduckgo blocked (status 202): anomaly page" {
+		t.Errorf("Expected the block to be recorded, got %v", result.SearchErrors)
+	}
+	if result.Results[0].Provider != "searxng" {
+		t.Errorf("Expected result attributed to the failover provider, got %q", result.Results[0].Provider)
+	}
+
+	info := noteBlockedProviders(nil, report)
+	info = noteBlockedProviders(info, webscrape.SearchReport{Provider: "duckduckgo"})
+	if info == nil || len(info.BlockedProviders) != 1 || info.BlockedProviders[0] != "duckduckgo" || info.FailoverProvider != "searxng" {
+		t.Errorf("Expected blocked provider in response metadata, got %+v", info)
+	}
+	if noteBlockedProviders(nil, webscrape.SearchReport{Provider: "duckduckgo"}) != nil {
+		t.Errorf("Expected no search metadata without a block")
+	}
+}
+
+func TestNoteSnippetOnly(t *testing.T) {
+	results := []webscrape.PageInfo{
+		{URL: "https://example.com/a"},
+		{URL: "https://example.com/private", RobotsDisallowed: true},
+	}
+	info := noteSnippetOnly(nil, results)
+	if info == nil || len(info.SnippetOnly) != 1 || info.SnippetOnly[0] != "https://example.com/private" {
+		t.Errorf("Expected the disallowed citation flagged, got %+v", info)
+	}
+	if data, _ := json.Marshal(info); strings.Contains(string(data), "blocked_providers") {
+		t.Errorf("Expected no blocked providers reported when none were blocked, got %s", data)
+	}
+	if noteSnippetOnly(nil, results[:1]) != nil {
+		t.Errorf("Expected no search metadata when every page was fetched")
+	}
+}
+
+func TestTraceRecorderProxies(t *testing.T) {
+	forward := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer forward.Close()
+	t.Setenv("SEARCH_PROXIES", forward.URL)
+
+	// Traffic before the request started isn't attributed to it
+	client := proxy.NewClient(proxy.Search, 5*time.Second)
+	if resp, err := client.Get("http://search.example/before"); err == nil {
+		resp.Body.Close()
+	}
+
+	trace := newTraceRecorder(true)
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get("http://search.example/q")
+		if err != nil {
+			t.Fatalf("Request through the proxy failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	proxies := trace.result().Proxies
+	if len(proxies) != 1 {
+		t.Fatalf("Expected one proxy in the trace, got %+v", proxies)
+	}
+	if proxies[0].Traffic != "search" || proxies[0].Proxy != forward.URL || proxies[0].Requests != 2 {
+		t.Errorf("Unexpected proxy usage: %+v", proxies[0])
+	}
+}
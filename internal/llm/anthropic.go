@@ -8,6 +8,7 @@ import (
 	"os"
 	"strings"
 
+	"open-sonar/internal/proxy"
 	"open-sonar/internal/utils"
 )
 
@@ -149,7 +150,7 @@ func (c *AnthropicClient) GenerateResponseWithOptions(messages []string, options
 	req.Header.Set("anthropic-version", "2023-06-01")
 
 	// Make the request.
-	client := &http.Client{}
+	client := proxy.NewClient(proxy.LLM, 0)
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("error making request: %w", err)
@@ -191,3 +192,8 @@ func (c *AnthropicClient) CountTokens(text string) (int, error) {
 	// For MVP, use a simple heuristic.
 	return utils.SimpleTokenCount(text), nil
 }
+
+// ModelName returns the Anthropic model used for completions.
+func (c *AnthropicClient) ModelName() string {
+	return c.model
+}
@@ -63,6 +63,11 @@ func (p *MockLLMProvider) CountTokens(text string) (int, error) {
 	return utils.SimpleTokenCount(text), nil
 }
 
+// ModelName returns the model name the mock pretends to be.
+func (p *MockLLMProvider) ModelName() string {
+	return "deepseek-r1:1.5b"
+}
+
 func init() {
 	// Override the provider function in test mode.
 	if os.Getenv("TEST_MODE") == "true" {
@@ -318,3 +318,8 @@ func (p *OllamaProvider) SetModel(model string) error {
 	p.model = model
 	return p.verifyModelAvailability(true)
 }
+
+// ModelName returns the Ollama model used for generation.
+func (p *OllamaProvider) ModelName() string {
+	return p.model
+}
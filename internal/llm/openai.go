@@ -5,16 +5,55 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 
+	"open-sonar/internal/proxy"
 	"open-sonar/internal/utils"
 )
 
-// OpenAIClient implements the LLMProvider interface for OpenAI models.
+// DefaultOpenAIBaseURL is the OpenAI API, used when no base URL is set.
+const DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+// OpenAIClient implements the LLMProvider interface for OpenAI models and any
+// server exposing the same chat completions API, such as vLLM, llama.cpp
+// server, LM Studio or LocalAI.
 type OpenAIClient struct {
-	apiKey string
-	model  string
+	apiKey       string
+	model        string
+	endpoint     string // the chat completions URL
+	organization string
+	project      string
+	headers      map[string]string
+	instance     string // name of a registered instance, or empty
+	client       *http.Client
+}
+
+// OpenAIConfig describes an OpenAI-compatible endpoint.
+type OpenAIConfig struct {
+	BaseURL      string // e.g. "http://localhost:8000/v1"; empty uses the OpenAI API
+	APIKey       string // required only for the OpenAI API
+	Organization string
+	Project      string
+	Headers      map[string]string // sent with every request, e.g. for a gateway
+	Models       []string          // models served; the first is the default
+	Direct       bool              // bypass LLM_PROXIES, e.g. for a server on localhost
+}
+
+var (
+	openAIDefaultsMu sync.RWMutex
+	openAIDefaults   OpenAIConfig
+)
+
+// ConfigureOpenAI sets the default endpoint's settings. Settings left empty
+// fall back to the OPENAI_* environment variables.
+func ConfigureOpenAI(config OpenAIConfig) {
+	openAIDefaultsMu.Lock()
+	defer openAIDefaultsMu.Unlock()
+	openAIDefaults = config
 }
 
 type openaiRequest struct {
@@ -53,11 +92,35 @@ func init() {
 	})
 }
 
-// NewOpenAIClient creates a new instance of OpenAIClient.
+// NewOpenAIClient creates a new instance of OpenAIClient from the settings
+// given to ConfigureOpenAI and the OPENAI_* environment variables.
+// OPENAI_BASE_URL points it at a compatible server.
 func NewOpenAIClient() (*OpenAIClient, error) {
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("OPENAI_API_KEY environment variable not set")
+	openAIDefaultsMu.RLock()
+	config := openAIDefaults
+	openAIDefaultsMu.RUnlock()
+
+	if config.BaseURL == "" {
+		config.BaseURL = os.Getenv("OPENAI_BASE_URL")
+	}
+	if config.APIKey == "" {
+		config.APIKey = os.Getenv("OPENAI_API_KEY")
+	}
+	if config.Organization == "" {
+		config.Organization = os.Getenv("OPENAI_ORGANIZATION")
+	}
+	if config.Project == "" {
+		config.Project = os.Getenv("OPENAI_PROJECT")
+	}
+	if len(config.Headers) == 0 {
+		headers, err := ParseOpenAIHeaders(os.Getenv("OPENAI_HEADERS"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid OPENAI_HEADERS: %w", err)
+		}
+		config.Headers = headers
+	}
+	if !config.Direct {
+		config.Direct, _ = strconv.ParseBool(os.Getenv("OPENAI_DIRECT"))
 	}
 
 	model := os.Getenv("OPENAI_MODEL")
@@ -65,10 +128,90 @@ func NewOpenAIClient() (*OpenAIClient, error) {
 		model = "gpt-3.5-turbo" // Default model.
 	}
 
-	return &OpenAIClient{
-		apiKey: apiKey,
-		model:  model,
-	}, nil
+	return NewOpenAIClientWithConfig(config, model)
+}
+
+// NewOpenAIClientWithConfig creates a client calling model at the configured
+// endpoint, through LLM_PROXIES unless config.Direct is set.
+func NewOpenAIClientWithConfig(config OpenAIConfig, model string) (*OpenAIClient, error) {
+	c := &OpenAIClient{
+		apiKey:       config.APIKey,
+		model:        model,
+		organization: config.Organization,
+		project:      config.Project,
+		headers:      config.Headers,
+	}
+	if config.BaseURL == "" {
+		if c.apiKey == "" {
+			return nil, fmt.Errorf("OPENAI_API_KEY environment variable not set")
+		}
+		c.endpoint = DefaultOpenAIBaseURL + "/chat/completions"
+	} else {
+		endpoint, err := openAIEndpoint(config.BaseURL)
+		if err != nil {
+			return nil, err
+		}
+		c.endpoint = endpoint
+	}
+
+	c.client = proxy.NewClient(proxy.LLM, 0)
+	if config.Direct {
+		c.client = &http.Client{}
+	}
+	return c, nil
+}
+
+// returns the chat completions URL under a base URL, which may already
+// name it
+func openAIEndpoint(baseURL string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid OpenAI base URL %q", baseURL)
+	}
+	u.Path = strings.TrimRight(u.Path, "/")
+	if !strings.HasSuffix(u.Path, "/chat/completions") {
+		u.Path += "/chat/completions"
+	}
+	return u.String(), nil
+}
+
+// ParseOpenAIHeaders parses extra request headers written as
+// "Name: value" pairs separated by semicolons, since values may hold commas.
+// A semicolon within a value, as in a Cookie header, is escaped as "\;".
+func ParseOpenAIHeaders(value string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, pair := range splitUnescaped(value, ';') {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, val, ok := strings.Cut(pair, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("header %q is not \"Name: value\"", strings.TrimSpace(pair))
+		}
+		headers[http.CanonicalHeaderKey(name)] = strings.TrimSpace(val)
+	}
+	return headers, nil
+}
+
+// splits value at each sep not escaped with a backslash, unescaping the
+// escaped ones
+func splitUnescaped(value string, sep byte) []string {
+	var parts []string
+	var current strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value) && value[i+1] == sep:
+			current.WriteByte(sep)
+			i++
+		case value[i] == sep:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(value[i])
+		}
+	}
+	return append(parts, current.String())
 }
 
 // GenerateResponse returns a response from the OpenAI model for a given query.
@@ -120,16 +263,27 @@ func (c *OpenAIClient) GenerateResponseWithOptions(messages []string, options LL
 		return "", fmt.Errorf("error marshalling request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", c.endpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", fmt.Errorf("error creating request: %w", err)
 	}
 
+	// Extra headers come first so they cannot replace the ones below
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	if c.organization != "" {
+		req.Header.Set("OpenAI-Organization", c.organization)
+	}
+	if c.project != "" {
+		req.Header.Set("OpenAI-Project", c.project)
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("error making request: %w", err)
 	}
@@ -158,3 +312,8 @@ func (c *OpenAIClient) GenerateResponseWithOptions(messages []string, options LL
 func (c *OpenAIClient) CountTokens(text string) (int, error) {
 	return utils.SimpleTokenCount(text), nil
 }
+
+// ModelName returns the OpenAI model used for completions.
+func (c *OpenAIClient) ModelName() string {
+	return c.model
+}
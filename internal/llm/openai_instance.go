@@ -0,0 +1,175 @@
+package llm
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OpenAIInstancePrefix starts the provider name of a named OpenAI-compatible
+// instance, e.g. "openai:vllm-prod".
+const OpenAIInstancePrefix = "openai:"
+
+var (
+	openAIInstancesMu sync.RWMutex
+	openAIInstances   = map[string]OpenAIConfig{}
+)
+
+// RegisterOpenAIInstance registers a named OpenAI-compatible endpoint. It is
+// then reachable as "openai:<name>" for its first model,
+// "openai:<name>/<model>" for any model in its list, or by a listed model's
+// own name.
+func RegisterOpenAIInstance(name string, config OpenAIConfig) error {
+	if err := validateOpenAIInstance(name, config); err != nil {
+		return err
+	}
+	openAIInstancesMu.Lock()
+	defer openAIInstancesMu.Unlock()
+	openAIInstances[strings.ToLower(name)] = config
+	return nil
+}
+
+// OpenAIInstanceNames returns the registered and environment-configured
+// instance names, sorted.
+func OpenAIInstanceNames() []string {
+	seen := map[string]bool{}
+	openAIInstancesMu.RLock()
+	for name := range openAIInstances {
+		seen[name] = true
+	}
+	openAIInstancesMu.RUnlock()
+	for _, name := range envOpenAIInstanceNames() {
+		seen[name] = true
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OpenAIInstanceEnvVar names the environment variable holding one setting of
+// an instance listed in OPENAI_INSTANCES, e.g. OPENAI_VLLM_PROD_BASE_URL.
+func OpenAIInstanceEnvVar(name string, setting string) string {
+	key := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+	return "OPENAI_" + key + "_" + setting
+}
+
+func validateOpenAIInstance(name string, config OpenAIConfig) error {
+	if name == "" || strings.ContainsAny(name, ":/ ") {
+		return fmt.Errorf("invalid OpenAI instance name %q", name)
+	}
+	if config.BaseURL == "" {
+		return fmt.Errorf("OpenAI instance %q has no base URL", name)
+	}
+	if _, err := openAIEndpoint(config.BaseURL); err != nil {
+		return fmt.Errorf("OpenAI instance %q: %w", name, err)
+	}
+	if len(config.Models) == 0 {
+		return fmt.Errorf("OpenAI instance %q has no models", name)
+	}
+	return nil
+}
+
+// the instance names listed in OPENAI_INSTANCES
+func envOpenAIInstanceNames() []string {
+	var names []string
+	for _, name := range strings.Split(os.Getenv("OPENAI_INSTANCES"), ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// looks up an instance, registered ones first, then OPENAI_INSTANCES
+func lookupOpenAIInstance(name string) (OpenAIConfig, bool, error) {
+	name = strings.ToLower(name)
+	openAIInstancesMu.RLock()
+	config, ok := openAIInstances[name]
+	openAIInstancesMu.RUnlock()
+	if ok {
+		return config, true, nil
+	}
+
+	for _, listed := range envOpenAIInstanceNames() {
+		if listed != name {
+			continue
+		}
+		headers, err := ParseOpenAIHeaders(os.Getenv(OpenAIInstanceEnvVar(name, "HEADERS")))
+		if err != nil {
+			return OpenAIConfig{}, true, fmt.Errorf("invalid %s: %w", OpenAIInstanceEnvVar(name, "HEADERS"), err)
+		}
+		config := OpenAIConfig{
+			BaseURL:      os.Getenv(OpenAIInstanceEnvVar(name, "BASE_URL")),
+			APIKey:       os.Getenv(OpenAIInstanceEnvVar(name, "API_KEY")),
+			Organization: os.Getenv(OpenAIInstanceEnvVar(name, "ORGANIZATION")),
+			Project:      os.Getenv(OpenAIInstanceEnvVar(name, "PROJECT")),
+			Headers:      headers,
+		}
+		config.Direct, _ = strconv.ParseBool(os.Getenv(OpenAIInstanceEnvVar(name, "DIRECT")))
+		for _, model := range strings.Split(os.Getenv(OpenAIInstanceEnvVar(name, "MODELS")), ",") {
+			if model = strings.TrimSpace(model); model != "" {
+				config.Models = append(config.Models, model)
+			}
+		}
+		return config, true, validateOpenAIInstance(name, config)
+	}
+	return OpenAIConfig{}, false, nil
+}
+
+// resolveOpenAIInstance returns a client for a provider name naming an
+// instance, "openai:<name>" or "openai:<name>/<model>", or a model listed by
+// one. ok is false when the name has nothing to do with any instance.
+func resolveOpenAIInstance(provider string) (client LLMProvider, ok bool, err error) {
+	if rest, found := strings.CutPrefix(provider, OpenAIInstancePrefix); found {
+		name, model, _ := strings.Cut(rest, "/")
+		config, known, err := lookupOpenAIInstance(name)
+		if err != nil {
+			return nil, true, err
+		}
+		if !known {
+			return nil, true, fmt.Errorf("unknown OpenAI instance %q (available: %s)", name, strings.Join(OpenAIInstanceNames(), ", "))
+		}
+		if model == "" {
+			model = config.Models[0]
+		} else if !containsModel(config.Models, model) {
+			return nil, true, fmt.Errorf("OpenAI instance %q does not serve model %q (available: %s)", name, model, strings.Join(config.Models, ", "))
+		}
+		client, err := newOpenAIInstanceClient(name, config, model)
+		return client, true, err
+	}
+
+	// A bare model name goes to the first instance, by name, serving it
+	for _, name := range OpenAIInstanceNames() {
+		config, _, err := lookupOpenAIInstance(name)
+		if err != nil || !containsModel(config.Models, provider) {
+			continue
+		}
+		client, err := newOpenAIInstanceClient(name, config, provider)
+		return client, true, err
+	}
+	return nil, false, nil
+}
+
+func newOpenAIInstanceClient(name string, config OpenAIConfig, model string) (LLMProvider, error) {
+	client, err := NewOpenAIClientWithConfig(config, model)
+	if err != nil {
+		return nil, fmt.Errorf("OpenAI instance %q: %w", name, err)
+	}
+	client.instance = strings.ToLower(name)
+	return client, nil
+}
+
+func containsModel(models []string, model string) bool {
+	for _, m := range models {
+		if m == model {
+			return true
+		}
+	}
+	return false
+}
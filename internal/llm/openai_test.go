@@ -0,0 +1,258 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newOpenAICompatibleServer answers chat completions under /v1 with the
+// requested model's name, recording each request.
+func newOpenAICompatibleServer(t *testing.T) (*httptest.Server, *[]*http.Request) {
+	t.Helper()
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		var body openaiRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, r)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id": "cmpl-1", "object": "chat.completion", "model": %q,
+			"choices": [{"message": {"role": "assistant", "content": "answered by %s"}, "finish_reason": "stop"}]}`, body.Model, body.Model)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestOpenAIClientBaseURL(t *testing.T) {
+	server, requests := newOpenAICompatibleServer(t)
+	t.Setenv("OPENAI_BASE_URL", server.URL+"/v1/")
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("OPENAI_MODEL", "Qwen/Qwen2.5-7B-Instruct")
+	t.Setenv("OPENAI_ORGANIZATION", "org-search")
+	t.Setenv("OPENAI_PROJECT", "proj_sonar")
+	t.Setenv("OPENAI_HEADERS", "X-Gateway-Team: search; accept-language: en, de")
+
+	provider, err := GetProvider("openai")
+	if err != nil {
+		t.Fatalf("Expected no API key to be needed for a custom base URL, got %v", err)
+	}
+	response, err := provider.GenerateResponse("hello")
+	if err != nil {
+		t.Fatalf("GenerateResponse failed: %v", err)
+	}
+	if response != "answered by Qwen/Qwen2.5-7B-Instruct" {
+		t.Errorf("Unexpected response %q", response)
+	}
+
+	if len(*requests) != 1 {
+		t.Fatalf("Expected 1 request, got %d", len(*requests))
+	}
+	header := (*requests)[0].Header
+	for name, want := range map[string]string{
+		"OpenAI-Organization": "org-search",
+		"OpenAI-Project":      "proj_sonar",
+		"X-Gateway-Team":      "search",
+		"Accept-Language":     "en, de",
+		"Authorization":       "",
+	} {
+		if got := header.Get(name); got != want {
+			t.Errorf("Expected %s %q, got %q", name, want, got)
+		}
+	}
+	if name, model := DescribeProvider(provider); name != "openai" || model != "Qwen/Qwen2.5-7B-Instruct" {
+		t.Errorf("Unexpected description %s %s", name, model)
+	}
+}
+
+func TestNewOpenAIClientWithConfig(t *testing.T) {
+	client, err := NewOpenAIClientWithConfig(OpenAIConfig{APIKey: "sk-test"}, "gpt-4o")
+	if err != nil || client.endpoint != "https://api.openai.com/v1/chat/completions" {
+		t.Errorf("Expected the OpenAI API by default, got %+v, %v", client, err)
+	}
+	if _, err := NewOpenAIClientWithConfig(OpenAIConfig{}, "gpt-4o"); err == nil {
+		t.Errorf("Expected the OpenAI API to require an API key")
+	}
+
+	client, err = NewOpenAIClientWithConfig(OpenAIConfig{BaseURL: "https://gateway.corp.example/openai/chat/completions"}, "gpt-4o")
+	if err != nil || client.endpoint != "https://gateway.corp.example/openai/chat/completions" {
+		t.Errorf("Expected a full chat completions URL kept as is, got %+v, %v", client, err)
+	}
+
+	for _, baseURL := range []string{"localhost:1234/v1", "ftp://models.example/v1"} {
+		if _, err := NewOpenAIClientWithConfig(OpenAIConfig{BaseURL: baseURL}, "m"); err == nil {
+			t.Errorf("Expected %q to be rejected", baseURL)
+		}
+	}
+}
+
+func TestParseOpenAIHeaders(t *testing.T) {
+	headers, err := ParseOpenAIHeaders(` x-api-version: 2024-06-01 ;; X-Tags: a, b ; Cookie: session=abc\; region=eu`)
+	if err != nil {
+		t.Fatalf("ParseOpenAIHeaders failed: %v", err)
+	}
+	if len(headers) != 3 || headers["X-Api-Version"] != "2024-06-01" || headers["X-Tags"] != "a, b" || headers["Cookie"] != "session=abc; region=eu" {
+		t.Errorf("Unexpected headers %v", headers)
+	}
+	for _, value := range []string{"no colon", "Bad Name: x"} {
+		if _, err := ParseOpenAIHeaders(value); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestConfigureOpenAI(t *testing.T) {
+	server, requests := newOpenAICompatibleServer(t)
+	t.Setenv("OPENAI_BASE_URL", "")
+	t.Setenv("OPENAI_HEADERS", "X-Ignored: env")
+	t.Setenv("OPENAI_MODEL", "local-model")
+
+	// Configured headers reach the server as given, semicolons included
+	ConfigureOpenAI(OpenAIConfig{
+		BaseURL: server.URL + "/v1",
+		Headers: map[string]string{"Cookie": "session=abc; region=eu", "X-Gateway-Team": "search"},
+	})
+	t.Cleanup(func() { ConfigureOpenAI(OpenAIConfig{}) })
+
+	provider, err := GetProvider("openai")
+	if err != nil {
+		t.Fatalf("GetProvider failed: %v", err)
+	}
+	if _, err := provider.GenerateResponse("hello"); err != nil {
+		t.Fatalf("GenerateResponse failed: %v", err)
+	}
+	if len(*requests) != 1 {
+		t.Fatalf("Expected 1 request, got %d", len(*requests))
+	}
+	header := (*requests)[0].Header
+	if header.Get("Cookie") != "session=abc; region=eu" || header.Get("X-Gateway-Team") != "search" || header.Get("X-Ignored") != "" {
+		t.Errorf("Expected the configured headers only, got %v", header)
+	}
+}
+
+func TestOpenAIClientUsesLLMProxies(t *testing.T) {
+	// An HTTP proxy receives the absolute URL and answers for the gateway
+	var proxied []string
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": "via proxy"}}]}`)
+	}))
+	defer proxyServer.Close()
+	t.Setenv("LLM_PROXIES", proxyServer.URL)
+
+	config := OpenAIConfig{BaseURL: "http://llm-gateway.corp.example/v1"}
+	client, err := NewOpenAIClientWithConfig(config, "gpt-4o")
+	if err != nil {
+		t.Fatalf("NewOpenAIClientWithConfig failed: %v", err)
+	}
+	response, err := client.GenerateResponse("hello")
+	if err != nil || response != "via proxy" {
+		t.Fatalf("Expected the response through the proxy, got %q, %v", response, err)
+	}
+	if len(proxied) != 1 || proxied[0] != "http://llm-gateway.corp.example/v1/chat/completions" {
+		t.Errorf("Expected the gateway request proxied, got %v", proxied)
+	}
+
+	config.Direct = true
+	client, err = NewOpenAIClientWithConfig(config, "gpt-4o")
+	if err != nil || client.client.Transport != nil {
+		t.Errorf("Expected a direct client when opted out, got %+v, %v", client, err)
+	}
+}
+
+func TestOpenAIInstances(t *testing.T) {
+	vllm, vllmRequests := newOpenAICompatibleServer(t)
+	lmstudio, lmstudioRequests := newOpenAICompatibleServer(t)
+
+	if err := RegisterOpenAIInstance("vllm-prod", OpenAIConfig{
+		BaseURL: vllm.URL + "/v1",
+		APIKey:  "vllm-key",
+		Models:  []string{"meta-llama/Llama-3.1-8B-Instruct", "qwen2.5-coder"},
+	}); err != nil {
+		t.Fatalf("RegisterOpenAIInstance failed: %v", err)
+	}
+	t.Cleanup(func() {
+		openAIInstancesMu.Lock()
+		delete(openAIInstances, "vllm-prod")
+		openAIInstancesMu.Unlock()
+	})
+
+	// An instance can also come from the environment
+	t.Setenv("OPENAI_INSTANCES", "lmstudio")
+	t.Setenv("OPENAI_LMSTUDIO_BASE_URL", lmstudio.URL+"/v1")
+	t.Setenv("OPENAI_LMSTUDIO_MODELS", "phi-3-mini, gemma-2-9b")
+	t.Setenv("OPENAI_LMSTUDIO_HEADERS", "X-Client: sonar")
+
+	tests := []struct {
+		provider string
+		wantName string
+		wantFrom string
+	}{
+		{"openai:vllm-prod", "openai:vllm-prod", "meta-llama/Llama-3.1-8B-Instruct"},
+		{"openai:vllm-prod/qwen2.5-coder", "openai:vllm-prod", "qwen2.5-coder"},
+		{"meta-llama/Llama-3.1-8B-Instruct", "openai:vllm-prod", "meta-llama/Llama-3.1-8B-Instruct"},
+		{"openai:lmstudio", "openai:lmstudio", "phi-3-mini"},
+		{"gemma-2-9b", "openai:lmstudio", "gemma-2-9b"},
+	}
+	for _, tt := range tests {
+		provider, err := GetProvider(tt.provider)
+		if err != nil {
+			t.Errorf("GetProvider(%q) failed: %v", tt.provider, err)
+			continue
+		}
+		name, model := DescribeProvider(provider)
+		if name != tt.wantName || model != tt.wantFrom {
+			t.Errorf("GetProvider(%q): expected %s %s, got %s %s", tt.provider, tt.wantName, tt.wantFrom, name, model)
+		}
+		response, err := provider.GenerateResponse("hi")
+		if err != nil || response != "answered by "+tt.wantFrom {
+			t.Errorf("GetProvider(%q): unexpected response %q, %v", tt.provider, response, err)
+		}
+	}
+
+	if len(*vllmRequests) != 3 || len(*lmstudioRequests) != 2 {
+		t.Fatalf("Expected 3 vLLM and 2 LM Studio requests, got %d and %d", len(*vllmRequests), len(*lmstudioRequests))
+	}
+	if got := (*vllmRequests)[0].Header.Get("Authorization"); got != "Bearer vllm-key" {
+		t.Errorf("Expected the instance's API key, got %q", got)
+	}
+	if got := (*lmstudioRequests)[0].Header.Get("X-Client"); got != "sonar" {
+		t.Errorf("Expected the instance's headers, got %q", got)
+	}
+
+	for provider, wantErr := range map[string]string{
+		"openai:vllm-prod/gpt-4o": "does not serve model",
+		"openai:missing":          "unknown OpenAI instance",
+	} {
+		if _, err := GetProvider(provider); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("GetProvider(%q): expected error containing %q, got %v", provider, wantErr, err)
+		}
+	}
+}
+
+func TestRegisterOpenAIInstanceValidation(t *testing.T) {
+	for name, config := range map[string]OpenAIConfig{
+		"":          {BaseURL: "http://localhost:8000/v1", Models: []string{"m"}},
+		"a/b":       {BaseURL: "http://localhost:8000/v1", Models: []string{"m"}},
+		"no-url":    {Models: []string{"m"}},
+		"bad-url":   {BaseURL: "localhost:8000", Models: []string{"m"}},
+		"no-models": {BaseURL: "http://localhost:8000/v1"},
+	} {
+		if err := RegisterOpenAIInstance(name, config); err == nil {
+			t.Errorf("Expected instance %q %+v to be rejected", name, config)
+		}
+	}
+	if got := OpenAIInstanceEnvVar("vllm-prod", "BASE_URL"); got != "OPENAI_VLLM_PROD_BASE_URL" {
+		t.Errorf("Unexpected variable name %s", got)
+	}
+}
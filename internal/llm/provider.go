@@ -25,3 +25,31 @@ func SetLLMProvider(fn func(provider string) (LLMProvider, error)) func(provider
 func RestoreDefaultLLMProvider() {
 	overrideProviderFunc = nil
 }
+
+// ModelNamer is implemented by providers that can report the model they call.
+type ModelNamer interface {
+	ModelName() string
+}
+
+// DescribeProvider returns the backend name and model of a provider, for tracing.
+func DescribeProvider(p LLMProvider) (provider string, model string) {
+	switch client := p.(type) {
+	case *OllamaProvider:
+		provider = "ollama"
+	case *OpenAIClient:
+		provider = "openai"
+		if client.instance != "" {
+			provider = OpenAIInstancePrefix + client.instance
+		}
+	case *AnthropicClient:
+		provider = "anthropic"
+	case *MockLLMProvider:
+		provider = "mock"
+	default:
+		provider = fmt.Sprintf("%T", p)
+	}
+	if namer, ok := p.(ModelNamer); ok {
+		model = namer.ModelName()
+	}
+	return provider, model
+}
@@ -0,0 +1,155 @@
+package llm
+
+import "strings"
+
+const (
+	reasoningOpenTag  = "<think>"
+	reasoningCloseTag = "</think>"
+)
+
+// SplitReasoning separates <think>...</think> blocks emitted by reasoning
+// models (e.g. deepseek-r1) from the answer text.
+func SplitReasoning(text string) (content string, reasoning string) {
+	filter := NewReasoningFilter()
+	c, r := filter.Write(text)
+	fc, fr := filter.Flush()
+
+	content = strings.TrimSpace(c + fc)
+	reasoning = strings.TrimSpace(r + fr)
+	return content, reasoning
+}
+
+// HasReasoning reports whether text carries a reasoning block, opened or
+// only closed.
+func HasReasoning(text string) bool {
+	return strings.Contains(text, reasoningOpenTag) || strings.Contains(text, reasoningCloseTag)
+}
+
+// ReasoningFilter incrementally separates reasoning blocks from streamed
+// output, holding back partial tags that span chunk boundaries.
+//
+// Some chat templates put the opening tag in the prompt, so the output only
+// carries the closing tag. Until the first tag is seen, output is therefore
+// held back: it is reasoning if a closing tag comes first, and content if an
+// opening tag comes first or the output ends.
+type ReasoningFilter struct {
+	undecided   bool
+	held        strings.Builder // output before the first tag
+	inReasoning bool
+	pending     string
+	trimLeading bool
+}
+
+// NewReasoningFilter creates a new streaming reasoning filter
+func NewReasoningFilter() *ReasoningFilter {
+	return &ReasoningFilter{undecided: true}
+}
+
+// Write consumes a chunk and returns the content and reasoning text that can
+// be safely emitted so far.
+func (f *ReasoningFilter) Write(chunk string) (content string, reasoning string) {
+	text := f.pending + chunk
+	f.pending = ""
+
+	var contentOut, reasoningOut strings.Builder
+	if f.undecided {
+		text = f.decide(text, &contentOut, &reasoningOut)
+	}
+	for text != "" {
+		tag := reasoningOpenTag
+		if f.inReasoning {
+			tag = reasoningCloseTag
+		}
+
+		if idx := strings.Index(text, tag); idx != -1 {
+			f.emit(text[:idx], &contentOut, &reasoningOut)
+			text = text[idx+len(tag):]
+			f.inReasoning = !f.inReasoning
+			if !f.inReasoning {
+				// Drop the blank lines models put between reasoning and answer
+				f.trimLeading = true
+			}
+			continue
+		}
+
+		// Hold back a trailing fragment that could be the start of a tag
+		keep := partialTagSuffix(text, tag)
+		f.emit(text[:len(text)-keep], &contentOut, &reasoningOut)
+		f.pending = text[len(text)-keep:]
+		break
+	}
+
+	return contentOut.String(), reasoningOut.String()
+}
+
+// holds text back until the first tag shows whether it is reasoning,
+// returning the text after that tag, or "" while still undecided
+func (f *ReasoningFilter) decide(text string, content, reasoning *strings.Builder) string {
+	open := strings.Index(text, reasoningOpenTag)
+	closeIdx := strings.Index(text, reasoningCloseTag)
+	switch {
+	case open != -1 && (closeIdx == -1 || open < closeIdx):
+		f.undecided = false
+		f.emit(f.held.String()+text[:open], content, reasoning)
+		f.inReasoning = true
+		return text[open+len(reasoningOpenTag):]
+	case closeIdx != -1:
+		f.undecided = false
+		f.inReasoning = true
+		f.emit(f.held.String()+text[:closeIdx], content, reasoning)
+		f.inReasoning = false
+		f.trimLeading = true
+		return text[closeIdx+len(reasoningCloseTag):]
+	}
+
+	keep := max(partialTagSuffix(text, reasoningOpenTag), partialTagSuffix(text, reasoningCloseTag))
+	f.held.WriteString(text[:len(text)-keep])
+	f.pending = text[len(text)-keep:]
+	return ""
+}
+
+// Flush returns any text held back waiting for a tag to complete.
+func (f *ReasoningFilter) Flush() (content string, reasoning string) {
+	var contentOut, reasoningOut strings.Builder
+	if f.undecided {
+		// No tag at all, so it was all content
+		f.undecided = false
+		f.pending = f.held.String() + f.pending
+	}
+	f.emit(f.pending, &contentOut, &reasoningOut)
+	f.pending = ""
+	return contentOut.String(), reasoningOut.String()
+}
+
+// writes text to the builder matching the current state
+func (f *ReasoningFilter) emit(text string, content, reasoning *strings.Builder) {
+	if text == "" {
+		return
+	}
+	if f.inReasoning {
+		reasoning.WriteString(text)
+		return
+	}
+	if f.trimLeading {
+		text = strings.TrimLeft(text, " \t\r\n")
+		if text == "" {
+			return
+		}
+		f.trimLeading = false
+	}
+	content.WriteString(text)
+}
+
+// returns the length of the longest suffix of text that is a proper prefix of tag
+func partialTagSuffix(text, tag string) int {
+	max := len(tag) - 1
+	if len(text) < max {
+		max = len(text)
+	}
+	for n := max; n > 0; n-- {
+		if strings.HasSuffix(text, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,107 @@
+package llm
+
+import "testing"
+
+func TestSplitReasoning(t *testing.T) {
+	tests := []struct {
+		name              string
+		text              string
+		expectedContent   string
+		expectedReasoning string
+	}{
+		{
+			name:              "no reasoning",
+			text:              "Paris is the capital of France.",
+			expectedContent:   "Paris is the capital of France.",
+			expectedReasoning: "",
+		},
+		{
+			name:              "leading think block",
+			text:              "<think>\nThe user asks about France.\n</think>\n\nParis.",
+			expectedContent:   "Paris.",
+			expectedReasoning: "The user asks about France.",
+		},
+		{
+			name:              "missing opening tag",
+			text:              "Thinking about it.\n</think>\n\n{\"answer\": 42}",
+			expectedContent:   `{"answer": 42}`,
+			expectedReasoning: "Thinking about it.",
+		},
+		{
+			name:              "unterminated block",
+			text:              "<think>still thinking",
+			expectedContent:   "",
+			expectedReasoning: "still thinking",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, reasoning := SplitReasoning(tt.text)
+			if content != tt.expectedContent {
+				t.Errorf("Expected content %q, got %q", tt.expectedContent, content)
+			}
+			if reasoning != tt.expectedReasoning {
+				t.Errorf("Expected reasoning %q, got %q", tt.expectedReasoning, reasoning)
+			}
+		})
+	}
+}
+
+func TestReasoningFilterAcrossChunks(t *testing.T) {
+	text := "<think>Step one. Step two.</think>\n\nThe answer is <b>4</b>."
+
+	// Split at every possible chunk size so tags land across boundaries
+	for size := 1; size <= len(text); size++ {
+		filter := NewReasoningFilter()
+		var content, reasoning string
+		for i := 0; i < len(text); i += size {
+			end := i + size
+			if end > len(text) {
+				end = len(text)
+			}
+			c, r := filter.Write(text[i:end])
+			content += c
+			reasoning += r
+		}
+		c, r := filter.Flush()
+		content += c
+		reasoning += r
+
+		if content != "The answer is <b>4</b>." {
+			t.Fatalf("Chunk size %d: unexpected content %q", size, content)
+		}
+		if reasoning != "Step one. Step two." {
+			t.Fatalf("Chunk size %d: unexpected reasoning %q", size, reasoning)
+		}
+	}
+}
+
+func TestReasoningFilterClosingTagOnly(t *testing.T) {
+	text := "Step one.\n</think>\n\nThe answer is 4."
+
+	for size := 1; size <= len(text); size++ {
+		filter := NewReasoningFilter()
+		var content, reasoning string
+		for i := 0; i < len(text); i += size {
+			c, r := filter.Write(text[i:min(i+size, len(text))])
+			content += c
+			reasoning += r
+		}
+		c, r := filter.Flush()
+		content += c
+		reasoning += r
+
+		if content != "The answer is 4." || reasoning != "Step one.\n" {
+			t.Fatalf("Chunk size %d: unexpected content %q and reasoning %q", size, content, reasoning)
+		}
+	}
+
+	// Without any tag, everything held back is content
+	filter := NewReasoningFilter()
+	c, _ := filter.Write("No reasoning <th")
+	fc, _ := filter.Flush()
+	if c+fc != "No reasoning <th" {
+		t.Errorf("Expected untagged output kept as content, got %q", c+fc)
+	}
+}
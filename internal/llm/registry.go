@@ -20,6 +20,11 @@ func GetProvider(provider string) (LLMProvider, error) {
 		return constructor(provider)
 	}
 
+	// Named OpenAI-compatible instances and the models they list
+	if client, ok, err := resolveOpenAIInstance(provider); ok {
+		return client, err
+	}
+
 	// Fallback
 	if strings.HasPrefix(provider, "deepseek") ||
 		strings.Contains(provider, ":") ||
@@ -13,26 +13,35 @@ type ChatCompletionRequest struct {
 	FrequencyPenalty       *float64  `json:"frequency_penalty,omitempty"`
 	SearchDomainFilter     []string  `json:"search_domain_filter,omitempty"`
 	SearchRecencyFilter    string    `json:"search_recency_filter,omitempty"`
+	SearchProvider         string    `json:"search_provider,omitempty"`
+	SearchProviders        []string  `json:"search_providers,omitempty"` // "name[:weight[:timeout]]"
+	SearchMode             string    `json:"search_mode,omitempty"`      // "web" (default) or "news"
 	ResponseFormat         *string   `json:"response_format,omitempty"`
 	ReturnImages           bool      `json:"return_images,omitempty"`
 	ReturnRelatedQuestions bool      `json:"return_related_questions,omitempty"`
+	ReturnReasoning        bool      `json:"return_reasoning,omitempty"`
+	Debug                  bool      `json:"debug,omitempty"`
 }
 
 // ChatCompletionResponse is the response object for chat completions
 type ChatCompletionResponse struct {
-	ID        string   `json:"id"`
-	Model     string   `json:"model"`
-	Object    string   `json:"object"`
-	Created   int64    `json:"created"`
-	Citations []string `json:"citations"` // Make sure this is properly tagged
-	Choices   []Choice `json:"choices"`
-	Usage     Usage    `json:"usage"`
+	ID        string      `json:"id"`
+	Model     string      `json:"model"`
+	Object    string      `json:"object"`
+	Created   int64       `json:"created"`
+	Citations []string    `json:"citations"` // Make sure this is properly tagged
+	Choices   []Choice    `json:"choices"`
+	Usage     Usage       `json:"usage"`
+	Evidence  *Evidence   `json:"evidence,omitempty"`
+	Search    *SearchInfo `json:"search,omitempty"`
+	Debug     *DebugTrace `json:"debug,omitempty"`
 }
 
 // Message represents an individual message in the conversation
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role      string `json:"role"`
+	Content   string `json:"content"`
+	Reasoning string `json:"reasoning,omitempty"`
 }
 
 // Choice represents a generation choice
@@ -45,8 +54,9 @@ type Choice struct {
 
 // Delta represents incremental content when streaming
 type Delta struct {
-	Role    string `json:"role,omitempty"`
-	Content string `json:"content,omitempty"`
+	Role      string `json:"role,omitempty"`
+	Content   string `json:"content,omitempty"`
+	Reasoning string `json:"reasoning,omitempty"`
 }
 
 // Usage contains token statistics
@@ -56,6 +66,84 @@ type Usage struct {
 	TotalTokens      int `json:"total_tokens"`
 }
 
+// Evidence describes how well the retrieved sources support an answer
+type Evidence struct {
+	SourcesUsed int     `json:"sources_used"`
+	Coverage    float64 `json:"coverage"`
+	Fallback    bool    `json:"fallback"`
+	Confidence  string  `json:"confidence"`
+}
+
+// SearchInfo reports search providers that were blocked while answering, and
+// citations whose pages robots.txt kept us from reading
+type SearchInfo struct {
+	BlockedProviders []string `json:"blocked_providers,omitempty"`
+	FailoverProvider string   `json:"failover_provider,omitempty"`
+	SnippetOnly      []string `json:"snippet_only,omitempty"` // cited from the search snippet
+}
+
+// Confidence levels reported in Evidence
+const (
+	ConfidenceHigh   = "high"
+	ConfidenceMedium = "medium"
+	ConfidenceLow    = "low"
+)
+
+// DebugTrace records how the search pipeline produced an answer
+type DebugTrace struct {
+	SearchQueries   []string      `json:"search_queries"`
+	SearchProviders []string      `json:"search_providers"`
+	SearchErrors    []string      `json:"search_errors,omitempty"`
+	Results         []TraceResult `json:"results"`
+	Prompt          []string      `json:"prompt"`
+	Timings         []StageTiming `json:"timings"`
+	LLMProvider     string        `json:"llm_provider"`
+	LLMModel        string        `json:"llm_model"`
+	Proxies         []TraceProxy  `json:"proxies,omitempty"`
+}
+
+// TraceResult is a retrieved search result and what happened to it
+type TraceResult struct {
+	URL         string  `json:"url"`
+	Title       string  `json:"title"`
+	Query       string  `json:"query"`
+	Provider    string  `json:"provider"`
+	Score       float64 `json:"score,omitempty"`
+	Rank        int     `json:"rank,omitempty"`
+	Used        bool    `json:"used"`
+	DropReason  string  `json:"drop_reason,omitempty"`
+	SnippetOnly bool    `json:"snippet_only,omitempty"` // robots.txt disallowed fetching the page
+}
+
+// TraceProxy is an outbound proxy's use while a request was answered. Counts
+// include concurrent requests, since proxies are shared between them.
+type TraceProxy struct {
+	Traffic  string `json:"traffic"` // "search", "fetch" or "llm"
+	Proxy    string `json:"proxy"`
+	Requests int    `json:"requests"`
+	Failures int    `json:"failures"`
+	Ejected  bool   `json:"ejected,omitempty"` // in its cooldown after repeated failures
+}
+
+// StageTiming is the duration of one pipeline stage
+type StageTiming struct {
+	Stage      string  `json:"stage"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// SearchProviderInfo describes a registered search provider
+type SearchProviderInfo struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Default bool   `json:"default"`
+}
+
+// SearchProviderList is the response listing available search providers
+type SearchProviderList struct {
+	Object string               `json:"object"`
+	Data   []SearchProviderInfo `json:"data"`
+}
+
 // VerifyCitations checks if citations are properly included
 func (resp *ChatCompletionResponse) VerifyCitations() bool {
 	return resp.Citations != nil && len(resp.Citations) > 0
@@ -0,0 +1,313 @@
+package prompts
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"open-sonar/internal/search/webscrape"
+	"open-sonar/internal/utils"
+)
+
+// Kind identifies which prompt a template renders.
+type Kind string
+
+const (
+	// KindSearch is the system prompt carrying search results
+	KindSearch Kind = "search"
+	// KindNoResults is the system prompt used when a search found nothing
+	KindNoResults Kind = "no_results"
+	// KindChat is the prompt for the legacy /chat endpoint
+	KindChat Kind = "chat"
+)
+
+// Kinds lists every template a set provides, each loaded from "<kind>.tmpl".
+var Kinds = []Kind{KindSearch, KindNoResults, KindChat}
+
+// DefaultSetName is the name of the built-in template set.
+const DefaultSetName = "default"
+
+// Data is the data model passed to every template.
+//
+//	.Query    the user's question
+//	.Results  search results, numbered from 1 in .Index; .InstantAnswer
+//	          marks a search engine's direct answer
+//	.Date     today's date as YYYY-MM-DD
+//	.Locale   the requested locale, e.g. "en-US"
+//	.History  earlier conversation turns, oldest first
+//	.Mode     the search mode, "news" or "web" (empty means web)
+//
+// Templates may also call {{truncate N .Text}} to shorten text to N bytes.
+type Data struct {
+	Query   string
+	Results []Result
+	Date    string
+	Locale  string
+	History []Turn
+	Mode    string
+}
+
+// Result is a search result as seen by templates.
+type Result struct {
+	Index     int
+	Title     string
+	URL       string
+	Summary   string
+	Content   string
+	Published time.Time
+	// InstantAnswer marks a direct answer such as a definition or conversion
+	InstantAnswer bool
+}
+
+// Turn is one message of the conversation history.
+type Turn struct {
+	Role    string
+	Content string
+}
+
+// NewData builds template data for a query and its search results.
+func NewData(query string, results []webscrape.PageInfo) Data {
+	data := Data{
+		Query:   query,
+		Results: make([]Result, 0, len(results)),
+		Date:    time.Now().Format("2006-01-02"),
+		Locale:  "en-US",
+	}
+	for i, result := range results {
+		data.Results = append(data.Results, Result{
+			Index:         i + 1,
+			Title:         result.Title,
+			URL:           result.URL,
+			Summary:       result.Summary,
+			Content:       result.Content,
+			Published:     result.Published,
+			InstantAnswer: result.InstantAnswer,
+		})
+	}
+	return data
+}
+
+// HasInstantAnswer reports whether any result is an instant answer.
+func (d Data) HasInstantAnswer() bool {
+	for _, result := range d.Results {
+		if result.InstantAnswer {
+			return true
+		}
+	}
+	return false
+}
+
+//go:embed templates/default/*.tmpl
+var builtinTemplates embed.FS
+
+var templateFuncs = template.FuncMap{
+	"truncate": func(n int, text string) string {
+		if len(text) <= n {
+			return text
+		}
+		return text[:n] + "..."
+	},
+}
+
+// Set is a named group of prompt templates.
+type Set struct {
+	Name      string
+	templates map[Kind]*template.Template
+}
+
+// Render executes the template of the given kind.
+func (s *Set) Render(kind Kind, data Data) (string, error) {
+	tmpl, ok := s.templates[kind]
+	if !ok {
+		return "", fmt.Errorf("template set %q has no %s template", s.Name, kind)
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("rendering %s/%s: %w", s.Name, kind, err)
+	}
+	return out.String(), nil
+}
+
+// Config selects the template directory and how sets are bound to requests.
+type Config struct {
+	// Dir holds one subdirectory per template set, each with <kind>.tmpl files.
+	// Missing files fall back to the built-in default set. A set whose name
+	// matches a model alias is used for that model automatically.
+	Dir string
+	// ModelTemplates maps model aliases to set names.
+	ModelTemplates map[string]string
+	// APIKeyTemplates maps API keys to set names; these take precedence over models.
+	APIKeyTemplates map[string]string
+}
+
+// Registry holds the loaded template sets and their bindings.
+type Registry struct {
+	sets     map[string]*Set
+	byModel  map[string]string
+	byAPIKey map[string]string
+}
+
+// NewRegistry loads the built-in templates plus any sets in cfg.Dir and
+// validates every template and binding.
+func NewRegistry(cfg Config) (*Registry, error) {
+	defaultSet, err := parseSet(DefaultSetName, builtinTemplates, "templates/default", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Registry{
+		sets:     map[string]*Set{DefaultSetName: defaultSet},
+		byModel:  map[string]string{},
+		byAPIKey: map[string]string{},
+	}
+
+	if cfg.Dir != "" {
+		entries, err := os.ReadDir(cfg.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("reading prompt template directory: %w", err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			name := entry.Name()
+			set, err := parseSet(name, os.DirFS(cfg.Dir), name, defaultSet)
+			if err != nil {
+				return nil, err
+			}
+			r.sets[name] = set
+			r.byModel[name] = name
+		}
+	}
+
+	for model, name := range cfg.ModelTemplates {
+		if _, ok := r.sets[name]; !ok {
+			return nil, fmt.Errorf("model %q is bound to unknown template set %q", model, name)
+		}
+		r.byModel[model] = name
+	}
+	for key, name := range cfg.APIKeyTemplates {
+		if _, ok := r.sets[name]; !ok {
+			return nil, fmt.Errorf("API key is bound to unknown template set %q", name)
+		}
+		r.byAPIKey[key] = name
+	}
+
+	return r, nil
+}
+
+// Select returns the set bound to the API key, then the model, then the default.
+func (r *Registry) Select(model, apiKey string) *Set {
+	if name, ok := r.byAPIKey[apiKey]; ok && apiKey != "" {
+		return r.sets[name]
+	}
+	if name, ok := r.byModel[model]; ok {
+		return r.sets[name]
+	}
+	return r.sets[DefaultSetName]
+}
+
+// SetNames returns the names of all loaded sets, sorted.
+func (r *Registry) SetNames() []string {
+	names := make([]string, 0, len(r.sets))
+	for name := range r.sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseSet loads every kind from dir; kinds missing from dir are taken from fallback.
+func parseSet(name string, fsys fs.FS, dir string, fallback *Set) (*Set, error) {
+	set := &Set{Name: name, templates: map[Kind]*template.Template{}}
+
+	for _, kind := range Kinds {
+		raw, err := fs.ReadFile(fsys, path.Join(dir, string(kind)+".tmpl"))
+		if err != nil {
+			if fallback != nil && errors.Is(err, fs.ErrNotExist) {
+				set.templates[kind] = fallback.templates[kind]
+				continue
+			}
+			return nil, fmt.Errorf("loading %s/%s template: %w", name, kind, err)
+		}
+
+		// Editors add a trailing newline that would otherwise end up in the prompt
+		text := strings.TrimSuffix(string(raw), "\n")
+		tmpl, err := template.New(string(kind)).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s/%s template: %w", name, kind, err)
+		}
+		set.templates[kind] = tmpl
+	}
+
+	// Execute against sample data so references to unknown fields fail at startup
+	if err := validateSet(set); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// renders every template in the set against representative data
+func validateSet(set *Set) error {
+	sample := Data{
+		Query: "sample query",
+		Results: []Result{
+			{Index: 1, Title: "Sample", URL: "https://example.com", Summary: "Summary", Content: "Content", Published: time.Now()},
+		},
+		Date:    time.Now().Format("2006-01-02"),
+		Locale:  "en-US",
+		History: []Turn{{Role: "user", Content: "Hello"}},
+	}
+	for _, kind := range Kinds {
+		if _, err := set.Render(kind, sample); err != nil {
+			return fmt.Errorf("invalid prompt template: %w", err)
+		}
+	}
+	return nil
+}
+
+var (
+	current   *Registry
+	currentMu sync.RWMutex
+)
+
+func init() {
+	r, err := NewRegistry(Config{})
+	if err != nil {
+		panic(fmt.Sprintf("built-in prompt templates are invalid: %v", err))
+	}
+	current = r
+}
+
+// Configure loads and validates templates, replacing the active registry.
+// On error the previous registry stays active.
+func Configure(cfg Config) error {
+	r, err := NewRegistry(cfg)
+	if err != nil {
+		return err
+	}
+
+	currentMu.Lock()
+	current = r
+	currentMu.Unlock()
+
+	utils.Info(fmt.Sprintf("Loaded prompt template sets: %s", strings.Join(r.SetNames(), ", ")))
+	return nil
+}
+
+// Render renders a prompt using the set selected for the model and API key.
+func Render(model, apiKey string, kind Kind, data Data) (string, error) {
+	currentMu.RLock()
+	set := current.Select(model, apiKey)
+	currentMu.RUnlock()
+
+	return set.Render(kind, data)
+}
@@ -0,0 +1,196 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"open-sonar/internal/search/webscrape"
+)
+
+func TestDefaultSearchTemplate(t *testing.T) {
+	results := []webscrape.PageInfo{
+		{URL: "https://example.com/a", Title: "Page A", Summary: "Summary A"},
+		{URL: "https://example.com/b", Title: "Page B", Content: strings.Repeat("x", 310)},
+	}
+
+	prompt, err := Render("sonar", "", KindSearch, NewData("what is x?", results))
+	if err != nil {
+		t.Fatalf("Failed to render search prompt: %v", err)
+	}
+
+	expectedResults := "WEB SEARCH RESULTS:\n" +
+		"[1] Page A\nURL: https://example.com/a\nSummary: Summary A\n\n" +
+		"[2] Page B\nURL: https://example.com/b\nContent: " + strings.Repeat("x", 300) + "...\n\n" +
+		"\n\nINSTRUCTIONS:"
+	if !strings.HasPrefix(prompt, "I'll help answer the question based on the web search results provided below.\n\nUSER QUERY: what is x?\n\n") {
+		t.Errorf("Unexpected prompt header:\n%s", prompt)
+	}
+	if !strings.Contains(prompt, expectedResults) {
+		t.Errorf("Unexpected results section:\n%s", prompt)
+	}
+	if !strings.HasSuffix(prompt, "directly address the user's query.") {
+		t.Errorf("Expected no trailing newline, got:\n%q", prompt[len(prompt)-20:])
+	}
+}
+
+func TestDefaultChatTemplate(t *testing.T) {
+	results := []webscrape.PageInfo{
+		{URL: "https://example.com/1", Title: "One"},
+		{URL: "https://example.com/2", Title: "Two"},
+		{URL: "https://example.com/3", Title: "Three"},
+		{URL: "https://example.com/4", Title: "Four"},
+	}
+
+	prompt, err := Render("", "", KindChat, NewData("Charmander", results))
+	if err != nil {
+		t.Fatalf("Failed to render chat prompt: %v", err)
+	}
+
+	expected := "Charmander\n\n- One (https://example.com/1)\n- Two (https://example.com/2)\n- Three (https://example.com/3)\n"
+	if prompt != expected {
+		t.Errorf("Expected %q, got %q", expected, prompt)
+	}
+}
+
+func TestRegistryFromDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "small", "search.tmpl", "Q: {{.Query}} ({{.Locale}}, {{len .Results}} results, {{len .History}} turns)")
+	writeTemplate(t, dir, "strict", "no_results.tmpl", "Say you don't know.")
+
+	registry, err := NewRegistry(Config{
+		Dir:             dir,
+		ModelTemplates:  map[string]string{"sonar-small": "small"},
+		APIKeyTemplates: map[string]string{"strict-key": "strict"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+
+	data := Data{Query: "hi", Locale: "de-DE", History: []Turn{{Role: "user", Content: "earlier"}}}
+
+	tests := []struct {
+		name     string
+		model    string
+		apiKey   string
+		kind     Kind
+		expected string
+	}{
+		{name: "bound by model alias", model: "sonar-small", kind: KindSearch, expected: "Q: hi (de-DE, 0 results, 1 turns)"},
+		{name: "bound by directory name", model: "small", kind: KindSearch, expected: "Q: hi (de-DE, 0 results, 1 turns)"},
+		{name: "bound by API key", model: "sonar-small", apiKey: "strict-key", kind: KindNoResults, expected: "Say you don't know."},
+		{name: "missing file falls back to default", model: "strict", apiKey: "strict-key", kind: KindChat, expected: "hi\n\n"},
+		{name: "unbound model uses default", model: "sonar", kind: KindNoResults, expected: "No relevant search results were found for this query. Please respond based on your training data."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt, err := registry.Select(tt.model, tt.apiKey).Render(tt.kind, data)
+			if err != nil {
+				t.Fatalf("Render failed: %v", err)
+			}
+			if prompt != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, prompt)
+			}
+		})
+	}
+}
+
+func TestRegistryValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		config   func(dir string) Config
+	}{
+		{
+			name:     "syntax error",
+			template: "{{.Query",
+			config:   func(dir string) Config { return Config{Dir: dir} },
+		},
+		{
+			name:     "unknown field",
+			template: "{{.Question}}",
+			config:   func(dir string) Config { return Config{Dir: dir} },
+		},
+		{
+			name:     "unknown set binding",
+			template: "{{.Query}}",
+			config: func(dir string) Config {
+				return Config{Dir: dir, ModelTemplates: map[string]string{"sonar": "missing"}}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTemplate(t, dir, "custom", "search.tmpl", tt.template)
+
+			if _, err := NewRegistry(tt.config(dir)); err == nil {
+				t.Error("Expected validation error, got nil")
+			}
+		})
+	}
+}
+
+func writeTemplate(t *testing.T, dir, set, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, set), 0o755); err != nil {
+		t.Fatalf("Failed to create set directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, set, name), []byte(content+"\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+}
+
+func TestDefaultSearchTemplateInstantAnswer(t *testing.T) {
+	results := []webscrape.PageInfo{
+		{URL: "https://en.wikipedia.org/wiki/Go_(programming_language)", Title: "Go (programming language)", Summary: "Go is a statically typed, compiled language.", InstantAnswer: true},
+		{URL: "https://example.com/a", Title: "Page A", Summary: "Summary A"},
+	}
+
+	prompt, err := Render("sonar", "", KindSearch, NewData("what is go?", results))
+	if err != nil {
+		t.Fatalf("Failed to render search prompt: %v", err)
+	}
+	if !strings.Contains(prompt, "[1] (INSTANT ANSWER) Go (programming language)\n") {
+		t.Errorf("Expected the instant answer to be marked:\n%s", prompt)
+	}
+	if !strings.Contains(prompt, "[2] Page A\n") {
+		t.Errorf("Expected other results to be unmarked:\n%s", prompt)
+	}
+	if !strings.Contains(prompt, "8. Results marked (INSTANT ANSWER)") {
+		t.Errorf("Expected an instruction to prefer the instant answer:\n%s", prompt)
+	}
+
+	prompt, err = Render("sonar", "", KindSearch, NewData("what is go?", results[1:]))
+	if err != nil {
+		t.Fatalf("Failed to render search prompt: %v", err)
+	}
+	if strings.Contains(prompt, "INSTANT ANSWER") {
+		t.Errorf("Expected no instant answer instruction without one:\n%s", prompt)
+	}
+}
+
+func TestDefaultSearchTemplateNewsDates(t *testing.T) {
+	published := time.Date(2025, 10, 14, 8, 30, 0, 0, time.UTC)
+	results := []webscrape.PageInfo{{URL: "https://wire.example.com/a", Title: "Story", Summary: "Summary", Published: published}}
+
+	data := NewData("what happened today?", results)
+	data.Mode = "news"
+	prompt, err := Render("sonar", "", KindSearch, data)
+	if err != nil {
+		t.Fatalf("Failed to render search prompt: %v", err)
+	}
+	if !strings.Contains(prompt, "URL: https://wire.example.com/a\nPublished: 2025-10-14 08:30 UTC\nSummary: Summary") {
+		t.Errorf("Expected the publication date in news mode:\n%s", prompt)
+	}
+
+	data.Mode = ""
+	prompt, _ = Render("sonar", "", KindSearch, data)
+	if strings.Contains(prompt, "Published:") {
+		t.Errorf("Expected no dates outside news mode:\n%s", prompt)
+	}
+}
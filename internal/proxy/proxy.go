@@ -0,0 +1,283 @@
+// Package proxy sends outbound traffic through rotating pools of HTTP, HTTPS
+// or SOCKS5 proxies, ejecting proxies that keep failing for a cooldown.
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"open-sonar/internal/utils"
+)
+
+// Traffic names a kind of outbound traffic with its own proxy pool.
+type Traffic string
+
+const (
+	Search Traffic = "search" // search provider requests
+	Fetch  Traffic = "fetch"  // result page fetches
+	LLM    Traffic = "llm"    // hosted LLM APIs
+)
+
+// Traffics lists every kind of traffic, in the order pools are reported.
+var Traffics = []Traffic{Search, Fetch, LLM}
+
+// Defaults for ejecting unhealthy proxies.
+const (
+	DefaultMaxFailures = 3
+	DefaultCooldown    = time.Minute
+)
+
+// EnvVar returns the setting listing a traffic's proxies, e.g. SEARCH_PROXIES.
+func EnvVar(traffic Traffic) string {
+	return strings.ToUpper(string(traffic)) + "_PROXIES"
+}
+
+// Pool rotates requests across proxies. A proxy failing MaxFailures requests
+// in a row is ejected for Cooldown; when every proxy is ejected, the one
+// whose cooldown ends first is still used rather than failing outright.
+type Pool struct {
+	Traffic     Traffic
+	MaxFailures int
+	Cooldown    time.Duration
+
+	mu        sync.Mutex
+	proxies   []*proxyState
+	next      int
+	transport *http.Transport
+	now       func() time.Time
+}
+
+type proxyState struct {
+	url          *url.URL
+	requests     int
+	failures     int // in total
+	consecutive  int // since the last success
+	ejectedUntil time.Time
+}
+
+// NewPool returns a pool of the given proxy URLs. Supported schemes are http,
+// https, socks5 and socks5h; user info is used for proxy authentication.
+func NewPool(traffic Traffic, rawURLs []string) (*Pool, error) {
+	p := &Pool{Traffic: traffic, MaxFailures: DefaultMaxFailures, Cooldown: DefaultCooldown, now: time.Now}
+	for _, raw := range rawURLs {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL %q: %w", raw, err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q in %s", u.Scheme, u.Redacted())
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("proxy URL %s has no host", u.Redacted())
+		}
+		p.proxies = append(p.proxies, &proxyState{url: u})
+	}
+	if len(p.proxies) == 0 {
+		return nil, fmt.Errorf("no proxies given")
+	}
+
+	p.transport = http.DefaultTransport.(*http.Transport).Clone()
+	p.transport.Proxy = proxyFromContext
+	return p, nil
+}
+
+var (
+	pools   = map[string]*Pool{}
+	poolsMu sync.Mutex
+)
+
+// ForTraffic returns the pool configured for a kind of traffic by its
+// *_PROXIES setting (a comma-separated list of proxy URLs), shared between
+// requests so that proxy health is tracked across them. It returns nil when
+// the setting is empty or invalid.
+func ForTraffic(traffic Traffic) *Pool {
+	value := os.Getenv(EnvVar(traffic))
+	if value == "" {
+		return nil
+	}
+
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+	key := string(traffic) + "|" + value
+	if pool, ok := pools[key]; ok {
+		return pool
+	}
+	pool, err := NewPool(traffic, strings.Split(value, ","))
+	if err != nil {
+		utils.Warn(fmt.Sprintf("Ignoring %s: %v", EnvVar(traffic), err))
+		return nil
+	}
+	pools[key] = pool
+	return pool
+}
+
+// NewClient returns an HTTP client for a kind of traffic, sending requests
+// through its pool when one is configured and connecting directly otherwise.
+func NewClient(traffic Traffic, timeout time.Duration) *http.Client {
+	client := &http.Client{Timeout: timeout}
+	if pool := ForTraffic(traffic); pool != nil {
+		client.Transport = pool.RoundTripper()
+	}
+	return client
+}
+
+type proxyKey struct{}
+
+// RoundTripper returns a transport sending each request through the pool's
+// next proxy and reporting the outcome.
+func (p *Pool) RoundTripper() http.RoundTripper {
+	return &roundTripper{pool: p, base: p.transport}
+}
+
+// routes a request via the proxy its RoundTripper chose
+func proxyFromContext(req *http.Request) (*url.URL, error) {
+	if u, ok := req.Context().Value(proxyKey{}).(*url.URL); ok {
+		return u, nil
+	}
+	return nil, nil
+}
+
+type roundTripper struct {
+	pool *Pool
+	base *http.Transport
+}
+
+func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	proxyURL := t.pool.pick()
+	resp, err := t.base.RoundTrip(req.WithContext(context.WithValue(req.Context(), proxyKey{}, proxyURL)))
+	switch {
+	case req.Context().Err() != nil:
+		// The caller gave up; that says nothing about the proxy
+	case err != nil:
+		t.pool.report(proxyURL, false)
+	case resp.StatusCode == http.StatusProxyAuthRequired:
+		t.pool.report(proxyURL, false)
+	default:
+		t.pool.report(proxyURL, true)
+	}
+	return resp, err
+}
+
+// returns the next healthy proxy in turn
+func (p *Pool) pick() *url.URL {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+
+	for i := 0; i < len(p.proxies); i++ {
+		state := p.proxies[(p.next+i)%len(p.proxies)]
+		if !now.Before(state.ejectedUntil) {
+			p.next = (p.next + i + 1) % len(p.proxies)
+			state.requests++
+			return state.url
+		}
+	}
+
+	// Every proxy is ejected: try the one that comes back first
+	soonest := p.proxies[0]
+	for _, state := range p.proxies[1:] {
+		if state.ejectedUntil.Before(soonest.ejectedUntil) {
+			soonest = state
+		}
+	}
+	soonest.requests++
+	return soonest.url
+}
+
+// records a request's outcome, ejecting a proxy that failed too often
+func (p *Pool) report(proxyURL *url.URL, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, state := range p.proxies {
+		if state.url != proxyURL {
+			continue
+		}
+		if ok {
+			state.consecutive = 0
+			state.ejectedUntil = time.Time{}
+			return
+		}
+		state.failures++
+		state.consecutive++
+		if state.consecutive >= p.MaxFailures {
+			state.ejectedUntil = p.now().Add(p.Cooldown)
+			state.consecutive = 0
+			utils.Warn(fmt.Sprintf("Ejecting %s proxy %s for %s after %d failures", p.Traffic, state.url.Redacted(), p.Cooldown, p.MaxFailures))
+		}
+		return
+	}
+}
+
+// Usage is a proxy's request counts and health.
+type Usage struct {
+	Traffic  Traffic
+	Proxy    string // URL with any password redacted
+	Requests int
+	Failures int
+	Ejected  bool
+}
+
+// Usage returns the counts of each of the pool's proxies.
+func (p *Pool) Usage() []Usage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	usage := make([]Usage, len(p.proxies))
+	for i, state := range p.proxies {
+		usage[i] = Usage{
+			Traffic:  p.Traffic,
+			Proxy:    state.url.Redacted(),
+			Requests: state.requests,
+			Failures: state.failures,
+			Ejected:  now.Before(state.ejectedUntil),
+		}
+	}
+	return usage
+}
+
+// Snapshot returns the usage of every configured proxy, by traffic.
+func Snapshot() []Usage {
+	var usage []Usage
+	for _, traffic := range Traffics {
+		if pool := ForTraffic(traffic); pool != nil {
+			usage = append(usage, pool.Usage()...)
+		}
+	}
+	return usage
+}
+
+// Since returns the requests sent through each proxy since an earlier
+// snapshot, leaving out proxies that weren't used and are healthy.
+func Since(before []Usage) []Usage {
+	type key struct {
+		traffic Traffic
+		proxy   string
+	}
+	start := map[key]Usage{}
+	for _, u := range before {
+		start[key{u.Traffic, u.Proxy}] = u
+	}
+
+	var delta []Usage
+	for _, u := range Snapshot() {
+		prev := start[key{u.Traffic, u.Proxy}]
+		u.Requests -= prev.Requests
+		u.Failures -= prev.Failures
+		if u.Requests > 0 || u.Ejected {
+			delta = append(delta, u)
+		}
+	}
+	return delta
+}
@@ -0,0 +1,127 @@
+package webscrape
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"open-sonar/internal/utils"
+)
+
+// DefaultBlockCooldown is how long a blocked provider is skipped, configurable
+// through SEARCH_BLOCK_COOLDOWN.
+const DefaultBlockCooldown = 5 * time.Minute
+
+// maxBackoff caps the delay between retries.
+const maxBackoff = 30 * time.Second
+
+// Search metrics, published through expvar (served at /debug/vars).
+var (
+	searchBlocks        = expvar.NewMap("search_blocks")         // provider -> blocks detected
+	searchCooldownSkips = expvar.NewMap("search_cooldown_skips") // provider -> searches skipped while cooling down
+	searchFailovers     = expvar.NewMap("search_failovers")      // provider -> searches handed to the failover provider
+)
+
+var (
+	cooldowns  = map[string]time.Time{}
+	cooldownMu sync.Mutex
+)
+
+// FailoverProviderName returns the provider used when the requested one is
+// blocked, configurable through SEARCH_FAILOVER_PROVIDER. Empty disables failover.
+func FailoverProviderName() string {
+	return strings.ToLower(utils.GetEnvWithDefault("SEARCH_FAILOVER_PROVIDER", ""))
+}
+
+// ProviderCooldown returns how long a blocked provider will still be skipped.
+func ProviderCooldown(name string) time.Duration {
+	cooldownMu.Lock()
+	defer cooldownMu.Unlock()
+	until, ok := cooldowns[strings.ToLower(name)]
+	if !ok {
+		return 0
+	}
+	remaining := time.Until(until)
+	if remaining <= 0 {
+		delete(cooldowns, strings.ToLower(name))
+		return 0
+	}
+	return remaining
+}
+
+// starts or extends a provider's cooldown
+func startCooldown(name string, d time.Duration) {
+	cooldownMu.Lock()
+	defer cooldownMu.Unlock()
+	until := time.Now().Add(d)
+	if until.After(cooldowns[strings.ToLower(name)]) {
+		cooldowns[strings.ToLower(name)] = until
+	}
+}
+
+// clears all cooldowns
+func resetCooldowns() {
+	cooldownMu.Lock()
+	defer cooldownMu.Unlock()
+	cooldowns = map[string]time.Time{}
+}
+
+// returns the configured cooldown after a block
+func blockCooldown() time.Duration {
+	if value := utils.GetEnvWithDefault("SEARCH_BLOCK_COOLDOWN", ""); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+			return d
+		}
+		utils.Warn(fmt.Sprintf("Invalid SEARCH_BLOCK_COOLDOWN %q, using %s", value, DefaultBlockCooldown))
+	}
+	return DefaultBlockCooldown
+}
+
+// backoffDelay returns the delay before retry attempt (1-based): base doubled
+// for each earlier attempt, capped at maxBackoff, with up to ±50% jitter so
+// concurrent searches don't retry in lockstep.
+func backoffDelay(base time.Duration, attempt int) time.Duration {
+	if attempt < 1 {
+		return 0
+	}
+	delay := base
+	for i := 1; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay)+1)) - delay/2
+	return delay + jitter
+}
+
+// runProvider runs one provider, skipping it while it cools down after a
+// block and starting a cooldown when it reports one.
+func runProvider(query string, name string, options SearchOptions) ([]PageInfo, error) {
+	if remaining := ProviderCooldown(name); remaining > 0 {
+		searchCooldownSkips.Add(name, 1)
+		return nil, &BlockedError{Provider: name, Reason: "cooling down after a block", RetryAfter: remaining}
+	}
+
+	provider, err := GetSearchProvider(name)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := provider.Search(query, options)
+	var blocked *BlockedError
+	if errors.As(err, &blocked) {
+		cooldown := blockCooldown()
+		if blocked.RetryAfter > cooldown {
+			cooldown = blocked.RetryAfter
+		}
+		startCooldown(name, cooldown)
+		searchBlocks.Add(name, 1)
+		utils.Warn(fmt.Sprintf("Search provider %s blocked, skipping it for %s: %v", name, cooldown, err))
+	}
+	return results, err
+}
@@ -0,0 +1,128 @@
+package webscrape
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func useStubProviders(t *testing.T, providers map[string]SearchProvider) {
+	t.Helper()
+	old := SetGetSearchProvider(func(name string) (SearchProvider, error) {
+		if provider, ok := providers[name]; ok {
+			return provider, nil
+		}
+		return nil, fmt.Errorf("%w %q", ErrUnknownSearchProvider, name)
+	})
+	t.Cleanup(func() {
+		SetGetSearchProvider(old)
+		resetCooldowns()
+	})
+	resetCooldowns()
+}
+
+func TestBackoffDelay(t *testing.T) {
+	base := 100 * time.Millisecond
+	for attempt := 1; attempt <= 4; attempt++ {
+		expected := base << (attempt - 1)
+		for i := 0; i < 20; i++ {
+			delay := backoffDelay(base, attempt)
+			if delay < expected/2 || delay > expected*3/2 {
+				t.Fatalf("Attempt %d: delay %s outside %s ±50%%", attempt, delay, expected)
+			}
+		}
+	}
+	if delay := backoffDelay(time.Second, 20); delay > maxBackoff*3/2 {
+		t.Errorf("Expected delay capped near %s, got %s", maxBackoff, delay)
+	}
+	if delay := backoffDelay(time.Second, 0); delay != 0 {
+		t.Errorf("Expected no delay before the first attempt, got %s", delay)
+	}
+}
+
+func TestBlockedProviderFailover(t *testing.T) {
+	blocked := &stubProvider{err: &BlockedError{Provider: "ddg", StatusCode: 202, Reason: "anomaly page"}}
+	backup := &stubProvider{results: pages("https://backup.example")}
+	useStubProviders(t, map[string]SearchProvider{"ddg": blocked, "backup": backup})
+	t.Setenv("SEARCH_FAILOVER_PROVIDER", "backup")
+	t.Setenv("SEARCH_BLOCK_COOLDOWN", "1m")
+
+	blocksBefore := expvarInt(searchBlocks, "ddg")
+	failoversBefore := expvarInt(searchFailovers, "ddg")
+
+	report := SearchWithReport("query", SearchOptions{Providers: []ProviderSpec{{Name: "ddg", Weight: 1}}})
+	if report.Err != nil {
+		t.Fatalf("Expected failover to succeed, got %v", report.Err)
+	}
+	if report.Provider != "backup" || report.FailedOver != "ddg" {
+		t.Errorf("Expected backup to stand in for ddg, got provider %q failed over from %q", report.Provider, report.FailedOver)
+	}
+	if len(report.Results) != 1 || report.Results[0].URL != "https://backup.example" {
+		t.Errorf("Expected backup results, got %+v", report.Results)
+	}
+	if len(report.Blocked) != 1 || report.Blocked[0].Name != "ddg" || !errors.Is(report.Blocked[0].Err, ErrBlocked) {
+		t.Errorf("Expected ddg to be reported as blocked, got %+v", report.Blocked)
+	}
+	if remaining := ProviderCooldown("ddg"); remaining <= 0 || remaining > time.Minute {
+		t.Errorf("Expected a one minute cooldown, got %s", remaining)
+	}
+
+	// While cooling down the blocked provider is not queried again
+	report = SearchWithReport("query", SearchOptions{Providers: []ProviderSpec{{Name: "ddg", Weight: 1}}})
+	if blocked.calls.Load() != 1 {
+		t.Errorf("Expected the blocked provider to be skipped during cooldown, got %d calls", blocked.calls.Load())
+	}
+	if report.Provider != "backup" || len(report.Blocked) != 1 {
+		t.Errorf("Expected cooldown to fail over too, got %+v", report)
+	}
+
+	if got := expvarInt(searchBlocks, "ddg") - blocksBefore; got != 1 {
+		t.Errorf("Expected 1 block counted, got %d", got)
+	}
+	if got := expvarInt(searchFailovers, "ddg") - failoversBefore; got != 2 {
+		t.Errorf("Expected 2 failovers counted, got %d", got)
+	}
+}
+
+func TestBlockedProviderWithoutFailover(t *testing.T) {
+	useStubProviders(t, map[string]SearchProvider{
+		"ddg": &stubProvider{err: &BlockedError{Provider: "ddg", StatusCode: 403}},
+	})
+	t.Setenv("SEARCH_FAILOVER_PROVIDER", "")
+
+	report := SearchWithReport("query", SearchOptions{Providers: []ProviderSpec{{Name: "ddg", Weight: 1}}})
+	if !errors.Is(report.Err, ErrBlocked) {
+		t.Errorf("Expected a blocked error, got %v", report.Err)
+	}
+	if report.FailedOver != "" || len(report.Blocked) != 1 {
+		t.Errorf("Expected the block to be reported without failover, got %+v", report)
+	}
+}
+
+func TestFanOutReportsBlockedProviders(t *testing.T) {
+	useStubProviders(t, map[string]SearchProvider{
+		"ddg":  &stubProvider{err: &BlockedError{Provider: "ddg", StatusCode: 202}},
+		"good": &stubProvider{results: pages("https://good.example")},
+	})
+
+	report := SearchWithReport("query", SearchOptions{Providers: []ProviderSpec{{Name: "ddg", Weight: 1}, {Name: "good", Weight: 1}}})
+	if report.Err != nil || len(report.Results) != 1 {
+		t.Fatalf("Expected results from the unblocked provider, got %+v", report)
+	}
+	if len(report.Blocked) != 1 || report.Blocked[0].Name != "ddg" {
+		t.Errorf("Expected ddg to be reported as blocked, got %+v", report.Blocked)
+	}
+	if ProviderCooldown("ddg") <= 0 {
+		t.Errorf("Expected ddg to be cooling down")
+	}
+}
+
+// returns a counter from an expvar map, zero when unset
+func expvarInt(m *expvar.Map, key string) int64 {
+	if v, ok := m.Get(key).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
@@ -0,0 +1,252 @@
+package webscrape
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"open-sonar/internal/proxy"
+	"open-sonar/internal/utils"
+)
+
+// BraveSearchURL is the Brave Search web search endpoint.
+const BraveSearchURL = "https://api.search.brave.com/res/v1/web/search"
+
+// Brave returns at most 20 results per request and accepts offsets up to 9.
+const (
+	braveResultsPerPage = 20
+	braveMaxOffset      = 9
+)
+
+// BraveSearchProvider queries the Brave Search API.
+type BraveSearchProvider struct {
+	APIKey   string
+	BaseURL  string
+	Country  string
+	Language string
+	Client   *http.Client
+}
+
+type braveResponse struct {
+	Query struct {
+		MoreResultsAvailable bool `json:"more_results_available"`
+	} `json:"query"`
+	Web struct {
+		Results []braveResult `json:"results"`
+	} `json:"web"`
+}
+
+type braveResult struct {
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	Description string `json:"description"`
+	Age         string `json:"age"`
+	PageAge     string `json:"page_age"`
+}
+
+type braveErrorResponse struct {
+	Error struct {
+		Code   string `json:"code"`
+		Detail string `json:"detail"`
+	} `json:"error"`
+}
+
+// Brave highlights query terms in descriptions with <strong> tags
+var htmlTagPattern = regexp.MustCompile(`<[^>]+>`)
+
+func init() {
+	RegisterSearchProvider("brave", func(config ProviderConfig) (SearchProvider, error) {
+		provider, err := NewBraveSearchProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	})
+}
+
+// NewBraveSearchProvider creates a Brave provider from BRAVE_* settings.
+func NewBraveSearchProvider(config ProviderConfig) (*BraveSearchProvider, error) {
+	apiKey := config.Get("BRAVE_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("BRAVE_API_KEY not set")
+	}
+	return &BraveSearchProvider{
+		APIKey:   apiKey,
+		BaseURL:  BraveSearchURL,
+		Country:  config.Get("BRAVE_COUNTRY"),
+		Language: config.Get("BRAVE_SEARCH_LANG"),
+		Client:   proxy.NewClient(proxy.Search, 30*time.Second),
+	}, nil
+}
+
+// Search fetches up to options.MaxPages pages of web results.
+func (p *BraveSearchProvider) Search(query string, options SearchOptions) ([]PageInfo, error) {
+	if options.MaxPages <= 0 {
+		options.MaxPages = 1
+	}
+
+	timer := utils.NewTimer("Brave search")
+	defer timer.Stop()
+
+	var results []PageInfo
+	seen := make(map[string]bool)
+
+	for offset := 0; offset < options.MaxPages && offset <= braveMaxOffset; offset++ {
+		pageResults, more, err := p.fetchPage(query, offset, options)
+		if err != nil {
+			// Keep what earlier pages returned
+			if offset > 0 {
+				utils.Warn(fmt.Sprintf("Brave page %d failed: %v", offset+1, err))
+				break
+			}
+			return nil, err
+		}
+
+		for _, result := range pageResults {
+			if result.URL == "" || seen[result.URL] {
+				continue
+			}
+			seen[result.URL] = true
+			results = append(results, result)
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	return results, nil
+}
+
+// fetches one page; more reports whether Brave has further results
+func (p *BraveSearchProvider) fetchPage(query string, offset int, options SearchOptions) ([]PageInfo, bool, error) {
+	req, err := http.NewRequestWithContext(options.requestContext(), "GET", p.searchURL(query, offset, options), nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Subscription-Token", p.APIKey)
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, braveStatusError(resp)
+	}
+
+	var decoded braveResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return nil, false, fmt.Errorf("error parsing response: %w", err)
+	}
+
+	results := make([]PageInfo, 0, len(decoded.Web.Results))
+	for _, r := range decoded.Web.Results {
+		description := strings.TrimSpace(html.UnescapeString(htmlTagPattern.ReplaceAllString(r.Description, "")))
+		results = append(results, PageInfo{
+			URL:       strings.TrimSpace(r.URL),
+			Title:     strings.TrimSpace(html.UnescapeString(r.Title)),
+			Content:   description,
+			Summary:   description,
+			Published: parseBraveDate(r.PageAge),
+		})
+	}
+
+	more := decoded.Query.MoreResultsAvailable && len(decoded.Web.Results) > 0
+	return results, more, nil
+}
+
+// builds the request URL for the page at offset
+func (p *BraveSearchProvider) searchURL(query string, offset int, options SearchOptions) string {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("count", strconv.Itoa(braveResultsPerPage))
+	params.Set("offset", strconv.Itoa(offset))
+	if p.Country != "" {
+		params.Set("country", p.Country)
+	}
+	if p.Language != "" {
+		params.Set("search_lang", p.Language)
+	}
+	if freshness := braveFreshness(options.SearchRecencyFilter); freshness != "" {
+		params.Set("freshness", freshness)
+	}
+
+	baseURL := p.BaseURL
+	if baseURL == "" {
+		baseURL = BraveSearchURL
+	}
+	return baseURL + "?" + params.Encode()
+}
+
+// converts a non-200 response into an error, typing quota and rate limit failures
+func braveStatusError(resp *http.Response) error {
+	var errResp braveErrorResponse
+	json.NewDecoder(resp.Body).Decode(&errResp)
+
+	code := errResp.Error.Code
+	if resp.StatusCode == http.StatusTooManyRequests || code == "RATE_LIMITED" || code == "QUOTA_LIMITED" {
+		return &QuotaError{
+			Provider:   "brave",
+			StatusCode: resp.StatusCode,
+			Code:       code,
+			Message:    errResp.Error.Detail,
+			RetryAfter: braveRetryAfter(resp.Header),
+		}
+	}
+
+	if errResp.Error.Detail != "" {
+		return fmt.Errorf("Brave API error: %s: %s", resp.Status, errResp.Error.Detail)
+	}
+	return fmt.Errorf("Brave API error: %s", resp.Status)
+}
+
+// reads the wait time from Retry-After or the per-second X-RateLimit-Reset window
+func braveRetryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		// X-RateLimit-Reset lists seconds until each limit window resets, shortest first
+		value = strings.TrimSpace(strings.Split(header.Get("X-RateLimit-Reset"), ",")[0])
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
+}
+
+// maps a recency filter, as accepted by RecencyToTime, to Brave's freshness
+// parameter, which has no hour granularity
+func braveFreshness(recency string) string {
+	switch strings.ToLower(recency) {
+	case "hour", "day":
+		return "pd"
+	case "week":
+		return "pw"
+	case "month":
+		return "pm"
+	default:
+		return ""
+	}
+}
+
+// parses page_age, returning the zero time when absent or unparseable
+func parseBraveDate(value string) time.Time {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,132 @@
+package webscrape
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestBraveSearchProvider(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/brave_search.json")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	var queries []map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Subscription-Token") != "test-key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		q := r.URL.Query()
+		queries = append(queries, map[string]string{
+			"offset":      q.Get("offset"),
+			"freshness":   q.Get("freshness"),
+			"country":     q.Get("country"),
+			"search_lang": q.Get("search_lang"),
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		if q.Get("offset") == "0" {
+			w.Write(fixture)
+			return
+		}
+		fmt.Fprint(w, `{"query": {"more_results_available": false}, "web": {"results": [
+			{"title": "Vacuum internals", "url": "https://example.org/vacuum", "description": "Deep dive."}
+		]}}`)
+	}))
+	defer server.Close()
+
+	provider := &BraveSearchProvider{
+		APIKey:   "test-key",
+		BaseURL:  server.URL,
+		Country:  "us",
+		Language: "en",
+		Client:   server.Client(),
+	}
+
+	results, err := provider.Search("postgres vacuum", SearchOptions{
+		MaxPages:            5,
+		SearchRecencyFilter: "month",
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	// The second page reports no more results, so pagination stops there
+	if len(queries) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(queries))
+	}
+	if queries[0]["offset"] != "0" || queries[1]["offset"] != "1" {
+		t.Errorf("Expected offsets 0 and 1, got %v", queries)
+	}
+	if queries[0]["freshness"] != "pm" || queries[0]["country"] != "us" || queries[0]["search_lang"] != "en" {
+		t.Errorf("Unexpected request parameters: %v", queries[0])
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	if results[0].Content != "PostgreSQL databases require periodic maintenance known as vacuuming." {
+		t.Errorf("Expected highlight tags stripped, got %q", results[0].Content)
+	}
+	if !results[0].Published.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected published date: %v", results[0].Published)
+	}
+	if results[1].Title != "Understanding autovacuum & bloat" {
+		t.Errorf("Expected unescaped title, got %q", results[1].Title)
+	}
+}
+
+func TestBraveFreshness(t *testing.T) {
+	for recency, want := range map[string]string{
+		"hour": "pd", "day": "pd", "week": "pw", "month": "pm", "": "", "year": "",
+	} {
+		if got := braveFreshness(recency); got != want {
+			t.Errorf("braveFreshness(%q): expected %q, got %q", recency, want, got)
+		}
+	}
+}
+
+func TestBraveSearchProviderQuotaError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Reset", "1, 1419704")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"type": "ErrorResponse", "error": {"code": "RATE_LIMITED", "detail": "Request rate limit exceeded for plan.", "status": 429}}`)
+	}))
+	defer server.Close()
+
+	provider := &BraveSearchProvider{APIKey: "test-key", BaseURL: server.URL, Client: server.Client()}
+	_, err := provider.Search("query", SearchOptions{})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Expected quota error, got %v", err)
+	}
+
+	var quotaErr *QuotaError
+	if !errors.As(err, &quotaErr) {
+		t.Fatalf("Expected *QuotaError, got %T", err)
+	}
+	if quotaErr.Code != "RATE_LIMITED" || quotaErr.RetryAfter != time.Second {
+		t.Errorf("Unexpected quota error details: %+v", quotaErr)
+	}
+}
+
+func TestGetBraveProvider(t *testing.T) {
+	t.Setenv("BRAVE_API_KEY", "")
+	if _, err := DefaultGetSearchProvider("brave"); err == nil {
+		t.Error("Expected error when BRAVE_API_KEY is not set")
+	}
+
+	t.Setenv("BRAVE_API_KEY", "key")
+	provider, err := DefaultGetSearchProvider("brave")
+	if err != nil {
+		t.Fatalf("Failed to create Brave provider: %v", err)
+	}
+	if _, ok := provider.(*BraveSearchProvider); !ok {
+		t.Errorf("Expected *BraveSearchProvider, got %T", provider)
+	}
+}
@@ -0,0 +1,108 @@
+package webscrape
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"open-sonar/internal/utils"
+
+	"github.com/gogs/chardet"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// how much of a document is searched for a declared charset, as in the HTML
+// encoding sniffing algorithm
+const charsetPrescanBytes = 1024
+
+// minSniffConfidence is the lowest chardet confidence (1-100) that is trusted.
+const minSniffConfidence = 30
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+var (
+	metaCharsetExpr = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([a-z0-9_.:-]+)`)
+	xmlEncodingExpr = regexp.MustCompile(`(?i)^\s*<\?xml[^>]+encoding\s*=\s*["']([a-z0-9_.:-]+)["']`)
+)
+
+// decodeText converts a text response to UTF-8. The charset is taken, in
+// order, from a byte order mark, the Content-Type header, an HTML <meta> tag
+// or XML declaration, and finally sniffed from the bytes. It returns the
+// decoded text and the name of the charset used.
+func decodeText(raw []byte, contentType string) ([]byte, string) {
+	enc, name := detectCharset(raw, contentType)
+	if enc == nil {
+		return bytes.TrimPrefix(raw, utf8BOM), name
+	}
+	decoded, err := enc.NewDecoder().Bytes(raw)
+	if err != nil {
+		utils.Debug(fmt.Sprintf("Decoding %s failed, keeping the raw bytes: %v", name, err))
+		return raw, name
+	}
+	return decoded, name
+}
+
+// returns the encoding of a text document, or nil when it is UTF-8
+func detectCharset(raw []byte, contentType string) (encoding.Encoding, string) {
+	switch {
+	case bytes.HasPrefix(raw, utf8BOM):
+		return nil, "utf-8"
+	case bytes.HasPrefix(raw, []byte{0xFE, 0xFF}):
+		return unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), "utf-16be"
+	case bytes.HasPrefix(raw, []byte{0xFF, 0xFE}):
+		return unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), "utf-16le"
+	}
+
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		if enc, name := lookupCharset(params["charset"]); enc != nil || name == "utf-8" {
+			return enc, name
+		}
+	}
+
+	head := raw
+	if len(head) > charsetPrescanBytes {
+		head = head[:charsetPrescanBytes]
+	}
+	for _, expr := range []*regexp.Regexp{xmlEncodingExpr, metaCharsetExpr} {
+		if match := expr.FindSubmatch(head); match != nil {
+			if enc, name := lookupCharset(string(match[1])); enc != nil || name == "utf-8" {
+				return enc, name
+			}
+		}
+	}
+
+	if utf8.Valid(raw) {
+		return nil, "utf-8"
+	}
+	if result, err := chardet.NewTextDetector().DetectBest(raw); err == nil && result.Confidence >= minSniffConfidence {
+		if enc, name := lookupCharset(result.Charset); enc != nil || name == "utf-8" {
+			return enc, name
+		}
+	}
+	// The web's default for undeclared legacy pages
+	return lookupCharset("windows-1252")
+}
+
+// resolves a charset label such as "Shift_JIS" or "GB18030". It returns a nil
+// encoding for UTF-8 (which needs no decoding) and for unknown labels, whose
+// name is then empty.
+func lookupCharset(label string) (encoding.Encoding, string) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return nil, ""
+	}
+	enc, err := htmlindex.Get(label)
+	if err != nil {
+		return nil, ""
+	}
+	name, _ := htmlindex.Name(enc)
+	if name == "utf-8" {
+		return nil, name
+	}
+	return enc, name
+}
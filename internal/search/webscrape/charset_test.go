@@ -0,0 +1,107 @@
+package webscrape
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func encodeText(t *testing.T, enc encoding.Encoding, text string) []byte {
+	t.Helper()
+	encoded, err := enc.NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		t.Fatalf("Failed to encode fixture: %v", err)
+	}
+	return encoded
+}
+
+func TestDecodeText(t *testing.T) {
+	japaneseText := "東京都は日本の首都であり、世界最大級の都市圏を形成している。人口は約千四百万人で、政治と経済の中心地である。"
+	russianText := "Москва является столицей России и крупнейшим городом страны."
+	chineseText := "北京是中华人民共和国的首都，也是全国的政治和文化中心。"
+
+	tests := []struct {
+		name        string
+		raw         []byte
+		contentType string
+		expected    string
+		charset     string
+	}{
+		{
+			name:        "header",
+			raw:         encodeText(t, japanese.ShiftJIS, japaneseText),
+			contentType: "text/plain; charset=Shift_JIS",
+			expected:    japaneseText,
+			charset:     "shift_jis",
+		},
+		{
+			name:        "meta charset",
+			raw:         encodeText(t, charmap.Windows1251, `<html><head><meta charset="windows-1251"></head><body>`+russianText+`</body></html>`),
+			contentType: "text/html",
+			expected:    russianText,
+			charset:     "windows-1251",
+		},
+		{
+			name:        "meta http-equiv",
+			raw:         encodeText(t, simplifiedchinese.GBK, `<meta http-equiv="Content-Type" content="text/html; charset=gbk"><p>`+chineseText+`</p>`),
+			contentType: "text/html",
+			expected:    chineseText,
+			charset:     "gbk",
+		},
+		{
+			name:        "xml declaration",
+			raw:         encodeText(t, charmap.Windows1251, `<?xml version="1.0" encoding="windows-1251"?><rss><channel><title>`+russianText+`</title></channel></rss>`),
+			contentType: "application/rss+xml",
+			expected:    russianText,
+			charset:     "windows-1251",
+		},
+		{
+			name:        "header wins over meta",
+			raw:         encodeText(t, charmap.Windows1251, `<meta charset="iso-8859-2"><p>`+russianText+`</p>`),
+			contentType: "text/html; charset=windows-1251",
+			expected:    russianText,
+			charset:     "windows-1251",
+		},
+		{
+			name:        "byte order mark",
+			raw:         encodeText(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), russianText),
+			contentType: "text/plain; charset=iso-8859-1",
+			expected:    russianText,
+			charset:     "utf-16le",
+		},
+		{
+			name:        "sniffed",
+			raw:         encodeText(t, japanese.ShiftJIS, `<p>`+japaneseText+japaneseText+`</p>`),
+			contentType: "text/html",
+			expected:    japaneseText,
+			charset:     "shift_jis",
+		},
+		{
+			name:        "undeclared utf-8",
+			raw:         []byte("\xEF\xBB\xBF" + russianText),
+			contentType: "text/plain",
+			expected:    russianText,
+			charset:     "utf-8",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, charset := decodeText(tt.raw, tt.contentType)
+			if charset != tt.charset {
+				t.Errorf("Expected charset %s, got %s", tt.charset, charset)
+			}
+			if !strings.Contains(string(decoded), tt.expected) {
+				t.Errorf("Expected decoded text to contain %q, got %q", tt.expected, decoded)
+			}
+			if strings.HasPrefix(string(decoded), "\uFEFF") {
+				t.Errorf("Expected the byte order mark removed")
+			}
+		})
+	}
+}
@@ -0,0 +1,356 @@
+package webscrape
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Passages quoted from a fetched page's Markdown.
+const (
+	maxChunkPassages = 3
+	chunkLength      = 500
+)
+
+// markdownChunk is a run of a Markdown document's blocks under one heading.
+type markdownChunk struct {
+	Headings []string // the enclosing headings, outermost first
+	Text     string
+}
+
+// a block of a Markdown document: a heading, a paragraph, a list, a table or
+// a code block
+type markdownBlockText struct {
+	text  string
+	level int // heading level, or 0
+	kind  byte
+}
+
+// Block kinds, which decide how an oversized block is split
+const (
+	paragraphBlock = 'p'
+	listBlock      = 'l'
+	tableBlock     = 't'
+	codeBlockKind  = 'c'
+)
+
+// splits Markdown into blocks. A code block runs to its closing fence, even
+// across blank lines, and a table or list to its last row or item.
+func splitMarkdownBlocks(text string) []markdownBlockText {
+	var blocks []markdownBlockText
+	var current []string
+	var kind byte
+	flush := func() {
+		if len(current) > 0 {
+			blocks = append(blocks, markdownBlockText{text: strings.Join(current, "\n"), kind: kind})
+		}
+		current, kind = nil, 0
+	}
+
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			// A blank line between list items doesn't end the list
+			if kind == listBlock && i+1 < len(lines) && (isListItem(lines[i+1]) || strings.HasPrefix(lines[i+1], "  ")) {
+				continue
+			}
+			flush()
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			flush()
+			fence := fenceRun(trimmed)
+			current, kind = []string{line}, codeBlockKind
+			for i+1 < len(lines) {
+				i++
+				current = append(current, lines[i])
+				if closesFence(lines[i], fence) {
+					break
+				}
+			}
+			flush()
+		case headingLevel(trimmed) > 0:
+			flush()
+			blocks = append(blocks, markdownBlockText{text: trimmed, level: headingLevel(trimmed)})
+		case strings.HasPrefix(trimmed, "|"):
+			if kind != tableBlock {
+				flush()
+				kind = tableBlock
+			}
+			current = append(current, line)
+		case isListItem(line) && kind != listBlock:
+			flush()
+			current, kind = []string{line}, listBlock
+		default:
+			if kind == tableBlock || kind == codeBlockKind {
+				flush()
+			}
+			if kind == 0 {
+				kind = paragraphBlock
+			}
+			current = append(current, line)
+		}
+	}
+	flush()
+	return blocks
+}
+
+// returns the run of fence characters opening a code block, e.g. "````"
+func fenceRun(line string) string {
+	n := 0
+	for n < len(line) && line[n] == line[0] {
+		n++
+	}
+	return line[:n]
+}
+
+// reports whether line closes a code block opened by fence: a line of at
+// least as many of the same fence characters and nothing else
+func closesFence(line, fence string) bool {
+	trimmed := strings.TrimSpace(line)
+	run := fenceRun(trimmed)
+	return run != "" && run[0] == fence[0] && len(run) >= len(fence) && len(run) == len(trimmed)
+}
+
+// returns the level of a Markdown heading line, or 0
+func headingLevel(line string) int {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || level == len(line) || line[level] != ' ' {
+		return 0
+	}
+	return level
+}
+
+// reports whether a line starts a list item: "- ", "* ", "+ " or "1. "
+func isListItem(line string) bool {
+	line = strings.TrimLeft(line, " ")
+	if len(line) >= 2 && strings.ContainsRune("-*+", rune(line[0])) && line[1] == ' ' {
+		return true
+	}
+	digits := 0
+	for digits < len(line) && line[digits] >= '0' && line[digits] <= '9' {
+		digits++
+	}
+	return digits > 0 && digits+1 < len(line) && (line[digits] == '.' || line[digits] == ')') && line[digits+1] == ' '
+}
+
+// chunkMarkdown splits Markdown into chunks of at most about maxLen bytes
+// along its structure. A heading starts a new chunk and blocks are never
+// split unless one alone is longer than maxLen: then a table is split
+// between rows, each piece keeping its header; a code block between lines,
+// each piece fenced; a list between items; and a paragraph between
+// sentences.
+func chunkMarkdown(text string, maxLen int) []markdownChunk {
+	var chunks []markdownChunk
+	var headings []string
+	var current []string
+	length := 0
+	flush := func() {
+		if len(current) > 0 {
+			chunks = append(chunks, markdownChunk{Headings: append([]string(nil), headings...), Text: strings.Join(current, "\n\n")})
+		}
+		current, length = nil, 0
+	}
+	add := func(text string) {
+		if length > 0 && length+2+len(text) > maxLen {
+			flush()
+		}
+		current = append(current, text)
+		length += len(text) + 2
+	}
+
+	for _, block := range splitMarkdownBlocks(text) {
+		if block.level > 0 {
+			flush()
+			if block.level <= len(headings) {
+				headings = headings[:block.level-1]
+			}
+			for len(headings) < block.level-1 {
+				headings = append(headings, "")
+			}
+			headings = append(headings, strings.TrimSpace(block.text[block.level:]))
+			continue
+		}
+		if len(block.text) <= maxLen {
+			add(block.text)
+			continue
+		}
+		for _, piece := range splitBlock(block, maxLen) {
+			add(piece)
+		}
+	}
+	flush()
+	return chunks
+}
+
+// splits a block longer than maxLen into pieces that fit where possible
+func splitBlock(block markdownBlockText, maxLen int) []string {
+	lines := strings.Split(block.text, "\n")
+	switch block.kind {
+	case tableBlock:
+		if len(lines) > 2 {
+			header := lines[:2]
+			return packLines(lines[2:], maxLen, strings.Join(header, "\n")+"\n", "")
+		}
+	case codeBlockKind:
+		if len(lines) > 2 {
+			open, end := lines[0], lines[len(lines)-1]
+			body := lines[1 : len(lines)-1]
+			fence := fenceRun(strings.TrimSpace(open))
+			if !closesFence(end, fence) {
+				end, body = fence, lines[1:]
+			}
+			return packLines(body, maxLen, open+"\n", "\n"+end)
+		}
+	case listBlock:
+		var items []string
+		for _, line := range lines {
+			if isListItem(line) && !strings.HasPrefix(line, " ") || len(items) == 0 {
+				items = append(items, line)
+			} else {
+				items[len(items)-1] += "\n" + line
+			}
+		}
+		return packLines(items, maxLen, "", "")
+	}
+
+	var sentences []string
+	start := 0
+	for _, loc := range sentenceEnd.FindAllStringIndex(block.text, -1) {
+		sentences = append(sentences, strings.TrimSpace(block.text[start:loc[1]]))
+		start = loc[1]
+	}
+	if rest := strings.TrimSpace(block.text[start:]); rest != "" {
+		sentences = append(sentences, rest)
+	}
+	pieces := packLines(sentences, maxLen, "", "")
+	for i, piece := range pieces {
+		pieces[i] = strings.ReplaceAll(piece, "\n", " ")
+	}
+	return pieces
+}
+
+// packs parts into pieces of at most maxLen bytes, each wrapped in prefix and
+// suffix; a part longer than that is wrapped at spaces
+func packLines(parts []string, maxLen int, prefix, suffix string) []string {
+	room := max(maxLen-len(prefix)-len(suffix), 1)
+	var pieces []string
+	var current []string
+	length := 0
+	flush := func() {
+		if len(current) > 0 {
+			pieces = append(pieces, prefix+strings.Join(current, "\n")+suffix)
+		}
+		current, length = nil, 0
+	}
+	for _, part := range parts {
+		for _, line := range wrapText(part, room) {
+			if length > 0 && length+1+len(line) > room {
+				flush()
+			}
+			current = append(current, line)
+			length += len(line) + 1
+		}
+	}
+	flush()
+	return pieces
+}
+
+// splits text into lines of at most width bytes, at the last space that
+// fits or else mid-word, never inside a UTF-8 sequence
+func wrapText(text string, width int) []string {
+	var lines []string
+	for len(text) > width {
+		cut := strings.LastIndexByte(text[:width+1], ' ')
+		if cut <= 0 {
+			cut = width
+			for cut > 1 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+		}
+		lines = append(lines, strings.TrimRight(text[:cut], " "))
+		text = strings.TrimLeft(text[cut:], " ")
+	}
+	return append(lines, text)
+}
+
+// withChunkPassages quotes the sections of a Markdown page that best match
+// the query, in the order they appear, each labelled with its headings.
+// Pages without a match keep their summary.
+func withChunkPassages(result PageInfo, query string) PageInfo {
+	if !result.Markdown || len(result.Pages) > 0 || result.Content == "" {
+		return result
+	}
+	terms := uniqueTerms(tokenize(query))
+	if len(terms) == 0 {
+		return result
+	}
+	chunks := chunkMarkdown(result.Content, chunkLength)
+
+	// Chunks matching more distinct query terms, in the text or its
+	// headings, are chosen first, then those mentioning them more often
+	type scoredChunk struct {
+		index    int
+		distinct int
+		hits     int
+	}
+	var scored []scoredChunk
+	for i, chunk := range chunks {
+		s := scoredChunk{index: i}
+		text := strings.Join(chunk.Headings, " ") + " " + chunk.Text
+		for _, term := range terms {
+			if n := termHits(text, []string{term}); n > 0 {
+				s.distinct++
+				s.hits += n
+			}
+		}
+		if s.hits > 0 {
+			scored = append(scored, s)
+		}
+	}
+	if len(scored) == 0 {
+		return result
+	}
+	sort.SliceStable(scored, func(i, j int) bool {
+		if scored[i].distinct != scored[j].distinct {
+			return scored[i].distinct > scored[j].distinct
+		}
+		return scored[i].hits > scored[j].hits
+	})
+	if len(scored) > maxChunkPassages {
+		scored = scored[:maxChunkPassages]
+	}
+	chosen := map[int]bool{}
+	for _, s := range scored {
+		chosen[s.index] = true
+	}
+
+	var passages []string
+	for i, chunk := range chunks {
+		if !chosen[i] {
+			continue
+		}
+		passage := chunk.Text
+		if label := sectionLabel(chunk.Headings); label != "" {
+			passage = "[" + label + "]\n" + passage
+		}
+		passages = append(passages, passage)
+	}
+	result.Summary = strings.Join(passages, "\n\n")
+	return result
+}
+
+// joins the non-empty headings of a chunk, e.g. "Install > On Linux"
+func sectionLabel(headings []string) string {
+	var named []string
+	for _, heading := range headings {
+		if heading != "" {
+			named = append(named, heading)
+		}
+	}
+	return strings.Join(named, " > ")
+}
@@ -0,0 +1,153 @@
+package webscrape
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestChunkMarkdown(t *testing.T) {
+	markdown := strings.Join([]string{
+		"Intro paragraph before any heading.",
+		"# Guide",
+		"## Install",
+		"Download the archive.",
+		"- step one\n\n- step two\n  continued",
+		"```sh\n./configure\n\nmake install\n```",
+		"### On Linux",
+		"| Distro | Package |\n| --- | --- |\n| Debian | sonar |",
+		"## Usage",
+		"Run it.",
+	}, "\n\n")
+
+	chunks := chunkMarkdown(markdown, 500)
+	want := []struct {
+		headings string
+		text     string
+	}{
+		{"", "Intro paragraph before any heading."},
+		{"Guide > Install", "Download the archive.\n\n- step one\n- step two\n  continued\n\n```sh\n./configure\n\nmake install\n```"},
+		{"Guide > Install > On Linux", "| Distro | Package |\n| --- | --- |\n| Debian | sonar |"},
+		{"Guide > Usage", "Run it."},
+	}
+	if len(chunks) != len(want) {
+		t.Fatalf("Expected %d chunks, got %d: %+v", len(want), len(chunks), chunks)
+	}
+	for i, chunk := range chunks {
+		if got := sectionLabel(chunk.Headings); got != want[i].headings {
+			t.Errorf("Chunk %d: expected headings %q, got %q", i, want[i].headings, got)
+		}
+		if chunk.Text != want[i].text {
+			t.Errorf("Chunk %d: expected text:\n%s\ngot:\n%s", i, want[i].text, chunk.Text)
+		}
+	}
+}
+
+func TestChunkMarkdownSplitsLongBlocks(t *testing.T) {
+	var rows []string
+	for i := 0; i < 20; i++ {
+		rows = append(rows, fmt.Sprintf("| v1.%d | 2024-%02d-01 |", i, i%12+1))
+	}
+	table := "| Version | Released |\n| --- | --- |\n" + strings.Join(rows, "\n")
+	var lines []string
+	for i := 0; i < 20; i++ {
+		lines = append(lines, fmt.Sprintf("echo step %d", i))
+	}
+	code := "```sh\n" + strings.Join(lines, "\n") + "\n```"
+	paragraph := strings.Repeat("A sentence about releases. ", 12)
+
+	chunks := chunkMarkdown(table+"\n\n"+code+"\n\n"+paragraph, 150)
+
+	var tables, codes, prose int
+	for _, chunk := range chunks {
+		if len(chunk.Text) > 150 {
+			t.Errorf("Expected chunks of at most 150 bytes, got %d:\n%s", len(chunk.Text), chunk.Text)
+		}
+		for _, piece := range strings.Split(chunk.Text, "\n\n") {
+			switch {
+			case strings.HasPrefix(piece, "|"):
+				tables++
+				if !strings.HasPrefix(piece, "| Version | Released |\n| --- | --- |\n| v1.") {
+					t.Errorf("Expected each table piece to keep the header, got:\n%s", piece)
+				}
+			case strings.HasPrefix(piece, "```"):
+				codes++
+				if !strings.HasPrefix(piece, "```sh\necho step") || !strings.HasSuffix(piece, "\n```") {
+					t.Errorf("Expected each code piece fenced, got:\n%s", piece)
+				}
+			default:
+				prose++
+				if !strings.HasPrefix(piece, "A sentence") || !strings.HasSuffix(piece, "releases.") {
+					t.Errorf("Expected prose split between sentences, got:\n%s", piece)
+				}
+			}
+		}
+	}
+	if tables < 2 || codes < 2 || prose < 2 {
+		t.Errorf("Expected each long block split, got %d table, %d code and %d prose pieces", tables, codes, prose)
+	}
+}
+
+func TestChunkMarkdownLongerFence(t *testing.T) {
+	// A code block quoting Markdown needs a longer fence
+	code := "````md\nExample:\n\n```go\nfmt.Println(1)\n```\n\nDone.\n````"
+	chunks := chunkMarkdown("# Fences\n\n"+code+"\n\nAfter the block.", 500)
+	if len(chunks) != 1 || chunks[0].Text != code+"\n\nAfter the block." {
+		t.Fatalf("Expected the code block kept whole, got %+v", chunks)
+	}
+
+	// Split pieces reopen and close with the same longer fence
+	var lines []string
+	for i := 0; i < 12; i++ {
+		lines = append(lines, "```", fmt.Sprintf("step %d", i))
+	}
+	long := "````\n" + strings.Join(lines, "\n") + "\n````"
+	pieces := chunkMarkdown(long, 60)
+	if len(pieces) < 2 {
+		t.Fatalf("Expected the long block split, got %+v", pieces)
+	}
+	for _, piece := range pieces {
+		if !strings.HasPrefix(piece.Text, "````\n") || !strings.HasSuffix(piece.Text, "\n````") {
+			t.Errorf("Expected each piece fenced with ````, got:\n%s", piece.Text)
+		}
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	got := wrapText("aaaa bbbb ccccccccccc 東京", 6)
+	want := []string{"aaaa", "bbbb", "cccccc", "ccccc", "東京"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestWithChunkPassages(t *testing.T) {
+	content := strings.Join([]string{
+		"# Raspberry Pi 5",
+		"The fifth generation board is faster.",
+		"## Hardware",
+		"| Component | Pi 5 |\n| --- | --- |\n| CPU | Cortex-A76 @ 2.4 GHz |\n| RAM | 4 or 8 GB |",
+		"## Accessories",
+		"Cases, fans and power supplies are sold separately.",
+		"## Software",
+		"Raspberry Pi OS Bookworm is required.",
+	}, "\n\n")
+	result := PageInfo{URL: "https://boards.example.com/pi5", Content: content, Summary: "generated", Markdown: true}
+
+	got := withChunkPassages(result, "pi 5 ram cpu").Summary
+	want := "[Raspberry Pi 5 > Hardware]\n| Component | Pi 5 |\n| --- | --- |\n| CPU | Cortex-A76 @ 2.4 GHz |\n| RAM | 4 or 8 GB |"
+	if !strings.HasPrefix(got, "[Raspberry Pi 5]\nThe fifth generation") || !strings.Contains(got, want) {
+		t.Errorf("Expected the spec table quoted whole under its headings, got:\n%s", got)
+	}
+	if strings.Contains(got, "Accessories") {
+		t.Errorf("Expected sections without query terms to be left out, got:\n%s", got)
+	}
+
+	if got := withChunkPassages(result, "kubernetes").Summary; got != "generated" {
+		t.Errorf("Expected the summary kept when nothing matches, got %q", got)
+	}
+	result.Markdown = false
+	if got := withChunkPassages(result, "ram").Summary; got != "generated" {
+		t.Errorf("Expected a snippet-only result to keep its summary, got %q", got)
+	}
+}
@@ -0,0 +1,214 @@
+package webscrape
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"open-sonar/internal/utils"
+)
+
+// contentExtractor sets a result's text from a response body already
+// decoded to UTF-8.
+type contentExtractor func(result PageInfo, body []byte) (PageInfo, error)
+
+// contentExtractors maps media types to their extractor. Types ending in
+// "+json" or "+xml" that aren't listed use the JSON or XML extractor.
+var contentExtractors = map[string]contentExtractor{
+	"text/html":             readHTML,
+	"application/xhtml+xml": readHTML,
+	"text/plain":            readPlainText,
+	"text/markdown":         readPlainText,
+	"text/x-markdown":       readPlainText,
+	"application/json":      readJSON,
+	"application/xml":       readXML,
+	"text/xml":              readXML,
+	"application/rss+xml":   readXML,
+	"application/atom+xml":  readXML,
+}
+
+// returns the extractor for a Content-Type, or nil when it isn't supported
+func extractorFor(contentType string) contentExtractor {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	if extract, ok := contentExtractors[mediaType]; ok {
+		return extract
+	}
+	switch {
+	case strings.HasSuffix(mediaType, "+json"):
+		return readJSON
+	case strings.HasSuffix(mediaType, "+xml"):
+		return readXML
+	}
+	return nil
+}
+
+// reads up to maxBytes of a text response, decodes it to UTF-8 and extracts
+// it according to its Content-Type
+func readContent(result PageInfo, body io.Reader, contentType string, maxBytes int64) (PageInfo, error) {
+	extract := extractorFor(contentType)
+	if extract == nil {
+		return result, fmt.Errorf("unsupported content type %q", contentType)
+	}
+	raw, err := io.ReadAll(io.LimitReader(body, maxBytes))
+	if err != nil {
+		return result, fmt.Errorf("reading body: %w", err)
+	}
+	text, charset := decodeText(raw, contentType)
+	if charset != "utf-8" {
+		utils.Debug(fmt.Sprintf("Decoded %s from %s", result.URL, charset))
+	}
+	return extract(result, text)
+}
+
+// extracts the readable text of an HTML page using the extraction rules for
+// its site
+func readHTML(result PageInfo, body []byte) (PageInfo, error) {
+	return DefaultExtractionRules().Extract(result, body)
+}
+
+// keeps plain text and Markdown as they are, titled by a Markdown heading
+func readPlainText(result PageInfo, body []byte) (PageInfo, error) {
+	text := compactMarkdown(string(body))
+	if title := markdownTitle(text); title != "" {
+		result.Title = title
+	}
+	result.Markdown = true
+	return withText(result, text)
+}
+
+// extracts the string values of a JSON document in document order, labelled
+// with their keys; a "title" or "name" value becomes the title
+func readJSON(result PageInfo, body []byte) (PageInfo, error) {
+	if !json.Valid(body) {
+		return result, fmt.Errorf("invalid JSON")
+	}
+
+	// Walk the tokens rather than unmarshaling, which would lose key order
+	type frame struct {
+		object    bool
+		expectKey bool
+	}
+	var stack []*frame
+	var key, title string
+	var lines []string
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, fmt.Errorf("parsing JSON: %w", err)
+		}
+
+		var top *frame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		switch value := token.(type) {
+		case json.Delim:
+			switch value {
+			case '{', '[':
+				stack = append(stack, &frame{object: value == '{', expectKey: value == '{'})
+				continue
+			default:
+				stack = stack[:len(stack)-1]
+				if len(stack) > 0 {
+					top = stack[len(stack)-1]
+				} else {
+					top = nil
+				}
+			}
+		case string:
+			if top != nil && top.object && top.expectKey {
+				key = value
+				top.expectKey = false
+				continue
+			}
+			value = strings.Join(strings.Fields(value), " ")
+			if value == "" {
+				break
+			}
+			if title == "" && (key == "title" || key == "name") {
+				title = value
+			}
+			if top != nil && top.object {
+				lines = append(lines, key+": "+value)
+			} else {
+				lines = append(lines, value)
+			}
+		}
+		// A value was consumed, so an object expects its next key
+		if top != nil && top.object {
+			top.expectKey = true
+		}
+	}
+
+	if title != "" {
+		result.Title = title
+	}
+	return withText(result, strings.Join(lines, "\n"))
+}
+
+// extracts the items of an RSS or Atom feed, or the character data of any
+// other XML document
+func readXML(result PageInfo, body []byte) (PageInfo, error) {
+	var feed feedDocument
+	if err := newUTF8XMLDecoder(body).Decode(&feed); err == nil {
+		if lines := feed.textLines(); len(lines) > 0 {
+			if title := feedText(feed.ChannelTitle + feed.Title); title != "" {
+				result.Title = title
+			}
+			return withText(result, strings.Join(lines, "\n"))
+		}
+	}
+
+	var parts []string
+	dec := newUTF8XMLDecoder(body)
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, fmt.Errorf("parsing XML: %w", err)
+		}
+		if data, ok := token.(xml.CharData); ok {
+			if text := strings.Join(strings.Fields(string(data)), " "); text != "" {
+				parts = append(parts, text)
+			}
+		}
+	}
+	return withText(result, strings.Join(parts, "\n"))
+}
+
+// returns an XML decoder for a document already decoded to UTF-8, so its
+// encoding declaration is ignored
+func newUTF8XMLDecoder(body []byte) *xml.Decoder {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return dec
+}
+
+// sets a result's content and summary from extracted text
+func withText(result PageInfo, content string) (PageInfo, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return result, fmt.Errorf("no readable content")
+	}
+	result.Content = content
+	if summary := generateSummary(plainText(content)); summary != "" {
+		result.Summary = summary
+	}
+	return result, nil
+}
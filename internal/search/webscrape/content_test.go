@@ -0,0 +1,127 @@
+package webscrape
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+func TestExtractorFor(t *testing.T) {
+	tests := map[string]bool{
+		"text/html; charset=utf-8":         true,
+		"TEXT/HTML":                        true,
+		"text/markdown":                    true,
+		"application/ld+json":              true,
+		"application/vnd.custom+xml":       true,
+		"application/rss+xml; charset=gbk": true,
+		"image/png":                        false,
+		"application/zip":                  false,
+	}
+	for contentType, supported := range tests {
+		if got := extractorFor(contentType) != nil; got != supported {
+			t.Errorf("extractorFor(%q) supported = %v, expected %v", contentType, got, supported)
+		}
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	body := `{"id": 7, "title": "Release notes", "sections": [{"heading": "Fixes", "body": "Fixed a crash on startup."}, "Plain entry"], "url": ""}`
+	result, err := readJSON(PageInfo{Title: "snippet"}, []byte(body))
+	if err != nil {
+		t.Fatalf("Extraction failed: %v", err)
+	}
+	if result.Title != "Release notes" {
+		t.Errorf("Expected the title value as title, got %q", result.Title)
+	}
+	expected := "title: Release notes\nheading: Fixes\nbody: Fixed a crash on startup.\nPlain entry"
+	if result.Content != expected {
+		t.Errorf("Expected content in document order:\n%s\ngot:\n%s", expected, result.Content)
+	}
+
+	if _, err := readJSON(PageInfo{}, []byte(`{"broken":`)); err == nil {
+		t.Error("Expected an error for invalid JSON")
+	}
+}
+
+func TestReadXML(t *testing.T) {
+	feed, err := os.ReadFile("testdata/feed_rss.xml")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	result, err := readXML(PageInfo{}, feed)
+	if err != nil {
+		t.Fatalf("Extraction failed: %v", err)
+	}
+	if result.Title == "" || !strings.Contains(result.Content, ": ") {
+		t.Errorf("Expected feed items as lines, got %+v", result)
+	}
+
+	result, err = readXML(PageInfo{}, []byte(`<?xml version="1.0"?><catalog><book><title>Dune</title><author>Frank Herbert</author></book></catalog>`))
+	if err != nil {
+		t.Fatalf("Extraction failed: %v", err)
+	}
+	if result.Content != "Dune\nFrank Herbert" {
+		t.Errorf("Expected the character data, got %q", result.Content)
+	}
+}
+
+func TestFetchPagesContentTypes(t *testing.T) {
+	japaneseText := "東京都は日本の首都であり、世界最大級の都市圏を形成している。人口は約千四百万人で、政治と経済の中心地である。"
+	shiftJIS, err := japanese.ShiftJIS.NewEncoder().String(`<html><head><meta charset="Shift_JIS"><title>東京</title></head><body><article><p>` + japaneseText + `</p></article></body></html>`)
+	if err != nil {
+		t.Fatalf("Failed to encode fixture: %v", err)
+	}
+
+	responses := map[string]struct {
+		contentType string
+		body        string
+	}{
+		"/tokyo":     {"text/html", shiftJIS},
+		"/notes.md":  {"text/markdown; charset=utf-8", "# Install guide\n\nRun the installer and restart the service afterwards.\n"},
+		"/notes.txt": {"text/plain", "The service restarts nightly at two in the morning.\n"},
+		"/api":       {"application/json", `{"name": "Widget", "description": "A widget for testing content types."}`},
+		"/feed":      {"application/atom+xml", `<feed xmlns="http://www.w3.org/2005/Atom"><title>Changelog</title><entry><title>v2 released</title><summary>Version two adds charset detection.</summary></entry></feed>`},
+		"/untyped":   {"", `<!DOCTYPE html><html><head><title>Untyped</title></head><body><p>Served without a content type header at all.</p></body></html>`},
+		"/logo.png":  {"image/png", "\x89PNG"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		// An empty Content-Type header stops net/http from sniffing one
+		w.Header()["Content-Type"] = []string{response.contentType}
+		w.Write([]byte(response.body))
+	}))
+	defer server.Close()
+
+	paths := []string{"/tokyo", "/notes.md", "/notes.txt", "/api", "/feed", "/untyped", "/logo.png"}
+	fetched, report := FetchPages(context.Background(), snippets(server.URL, paths...), FetchOptions{Client: server.Client(), HostDelay: -1})
+
+	expected := []struct {
+		title   string
+		content string
+	}{
+		{"東京", japaneseText},
+		{"Install guide", "Run the installer"},
+		{"Snippet /notes.txt", "restarts nightly"},
+		{"Widget", "description: A widget for testing"},
+		{"Changelog", "v2 released: Version two adds charset detection."},
+		{"Untyped", "without a content type"},
+		{"Snippet /logo.png", "snippet"},
+	}
+	for i, want := range expected {
+		if fetched[i].Title != want.title || !strings.Contains(fetched[i].Content, want.content) {
+			t.Errorf("%s: expected title %q and content containing %q, got %q / %q", paths[i], want.title, want.content, fetched[i].Title, fetched[i].Content)
+		}
+	}
+	if report.Fetched != 6 || report.Failed != 1 {
+		t.Errorf("Expected 6 fetched and 1 failed, got %+v", report)
+	}
+}
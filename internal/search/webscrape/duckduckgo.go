@@ -1,20 +1,18 @@
 package webscrape
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"io"
 	"math/rand"
 	"net/http"
 	"net/url"
-	"regexp"
 	"strings"
 	"time"
 
+	"open-sonar/internal/proxy"
 	"open-sonar/internal/utils"
 
 	"github.com/PuerkitoBio/goquery"
-	"github.com/go-shiori/go-readability"
 )
 
 func randomUserAgent() string {
@@ -28,7 +26,61 @@ func randomUserAgent() string {
 	return userAgents[rand.Intn(len(userAgents))]
 }
 
-type DuckDuckGoSearchProvider struct{}
+// DuckDuckGo endpoints. The HTML layout is tried first; the lite layout is
+// used when the HTML result selectors stop matching.
+const (
+	DuckDuckGoHTMLURL = "https://html.duckduckgo.com/html/"
+	DuckDuckGoLiteURL = "https://lite.duckduckgo.com/lite/"
+)
+
+// ddgLayout identifies which DuckDuckGo markup a page uses.
+type ddgLayout int
+
+const (
+	ddgHTMLLayout ddgLayout = iota
+	ddgLiteLayout
+)
+
+func (l ddgLayout) String() string {
+	if l == ddgLiteLayout {
+		return "lite"
+	}
+	return "html"
+}
+
+// DuckDuckGoSearchProvider scrapes DuckDuckGo's no-JavaScript result pages.
+// The zero value uses the public endpoints.
+type DuckDuckGoSearchProvider struct {
+	HTMLURL string
+	LiteURL string
+	Client  *http.Client
+	// PageDelay is the pause between page requests; zero uses 200ms
+	PageDelay time.Duration
+	// RetryBackoff is the base delay between retries, doubled on each
+	// attempt with jitter; zero uses 1s
+	RetryBackoff time.Duration
+	// InstantAnswers also queries the Instant Answer API and puts its answer
+	// first; InstantAnswerURL overrides the endpoint
+	InstantAnswers   bool
+	InstantAnswerURL string
+	// SiteURL and NewsURL override the endpoints used in news mode
+	SiteURL string
+	NewsURL string
+}
+
+// ddgForm is a form submission: DuckDuckGo paginates by POSTing a form
+// carrying hidden state fields such as s (offset), dc and vqd.
+type ddgForm struct {
+	Action string
+	Values url.Values
+}
+
+// ddgPage is one parsed result page.
+type ddgPage struct {
+	Results   []PageInfo
+	Next      *ddgForm // nil on the last page
+	NoResults bool     // DuckDuckGo explicitly reported no results
+}
 
 func (p *DuckDuckGoSearchProvider) Search(query string, options SearchOptions) ([]PageInfo, error) {
 	var results []PageInfo
@@ -43,70 +95,233 @@ func (p *DuckDuckGoSearchProvider) Search(query string, options SearchOptions) (
 	searchTimer := utils.NewTimer("DuckDuckGo search")
 	defer searchTimer.Stop()
 
-	baseURL := "https://html.duckduckgo.com/html/"
-	encodedQuery := url.QueryEscape(query)
-	searchURL := fmt.Sprintf("%s?q=%s", baseURL, encodedQuery)
+	if options.SearchMode == SearchModeNews {
+		return p.searchNews(query, options)
+	}
+	ctx := options.requestContext()
+
+	// The instant answer is fetched alongside the result pages
+	var instant chan *PageInfo
+	if p.InstantAnswers {
+		instant = make(chan *PageInfo, 1)
+		go func() {
+			answer, err := p.instantAnswer(ctx, query)
+			if err != nil {
+				utils.Warn(fmt.Sprintf("DuckDuckGo instant answer failed: %v", err))
+			}
+			instant <- answer
+		}()
+	}
+
+	layout := ddgHTMLLayout
+	form := ddgForm{Action: p.endpoint(layout), Values: url.Values{"q": {query}}}
 
 	resultsMap := make(map[string]bool)
 
 	for page := 0; page < options.MaxPages; page++ {
-		pageResults, nextURL, err := p.scrapePage(searchURL, options.MaxRetries)
+		parsed, err := p.scrapePage(ctx, form, layout, options.MaxRetries)
 		if err != nil {
+			// Without a first page there is nothing to return, so report why
+			// instead of answering as if the search found nothing
+			if page == 0 {
+				return nil, err
+			}
 			utils.Warn(fmt.Sprintf("Error scraping page %d: %v", page+1, err))
 			break
 		}
 
-		for _, result := range pageResults {
+		// An empty first page that isn't an explicit "no results" page means
+		// the selectors no longer match, so retry with the lite layout
+		if page == 0 && layout == ddgHTMLLayout && len(parsed.Results) == 0 && !parsed.NoResults {
+			utils.Warn("DuckDuckGo HTML layout returned no parsable results, falling back to lite layout")
+			layout = ddgLiteLayout
+			form = ddgForm{Action: p.endpoint(layout), Values: url.Values{"q": {query}}}
+			parsed, err = p.scrapePage(ctx, form, layout, options.MaxRetries)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for _, result := range parsed.Results {
 			if !resultsMap[result.URL] {
 				results = append(results, result)
 				resultsMap[result.URL] = true
-				go p.enrichResultContent(&result)
 			}
 		}
 
-		if nextURL == "" {
+		if parsed.Next == nil {
 			break
 		}
 
-		searchURL = nextURL
-		time.Sleep(200 * time.Millisecond)
+		form = *parsed.Next
+		if sleepContext(ctx, p.pageDelay()) != nil {
+			break
+		}
 	}
 
+	if instant != nil {
+		results = withInstantAnswer(<-instant, results)
+	}
 	return results, nil
 }
 
-func (p *DuckDuckGoSearchProvider) scrapePage(url string, maxRetries int) ([]PageInfo, string, error) {
-	var results []PageInfo
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+// puts an instant answer first, dropping a scraped result for the same URL
+func withInstantAnswer(answer *PageInfo, results []PageInfo) []PageInfo {
+	if answer == nil {
+		return results
+	}
+	merged := make([]PageInfo, 0, len(results)+1)
+	merged = append(merged, *answer)
+	for _, result := range results {
+		if fusionKey(result.URL) != fusionKey(answer.URL) {
+			merged = append(merged, result)
+		}
+	}
+	return merged
+}
+
+// returns the endpoint URL for a layout
+func (p *DuckDuckGoSearchProvider) endpoint(layout ddgLayout) string {
+	if layout == ddgLiteLayout {
+		if p.LiteURL != "" {
+			return p.LiteURL
+		}
+		return DuckDuckGoLiteURL
+	}
+	if p.HTMLURL != "" {
+		return p.HTMLURL
 	}
-	var resp *http.Response
-	var err error
+	return DuckDuckGoHTMLURL
+}
+
+func (p *DuckDuckGoSearchProvider) pageDelay() time.Duration {
+	if p.PageDelay > 0 {
+		return p.PageDelay
+	}
+	return 200 * time.Millisecond
+}
+
+func (p *DuckDuckGoSearchProvider) retryBackoff() time.Duration {
+	if p.RetryBackoff > 0 {
+		return p.RetryBackoff
+	}
+	return time.Second
+}
+
+// submits a search form and parses the resulting page
+func (p *DuckDuckGoSearchProvider) scrapePage(ctx context.Context, form ddgForm, layout ddgLayout, maxRetries int) (ddgPage, error) {
+	doc, err := p.submit(ctx, form, maxRetries)
+	if err != nil {
+		return ddgPage{}, err
+	}
+
+	base, err := url.Parse(form.Action)
+	if err != nil {
+		return ddgPage{}, fmt.Errorf("invalid form action %q: %w", form.Action, err)
+	}
+
+	var page ddgPage
+	if layout == ddgLiteLayout {
+		page.Results = p.parseLiteResults(doc)
+	} else {
+		page.Results = p.parseHTMLResults(doc)
+		page.NoResults = doc.Find(".no-results").Length() > 0
+	}
+	page.Next = nextPageForm(doc, base)
+	return page, nil
+}
+
+// POSTs a form, retrying failed requests and block pages with exponential backoff
+func (p *DuckDuckGoSearchProvider) submit(ctx context.Context, form ddgForm, maxRetries int) (*goquery.Document, error) {
+	client := p.Client
+	if client == nil {
+		client = proxy.NewClient(proxy.Search, 30*time.Second)
+	}
+
+	var lastErr error
 	for i := 0; i < maxRetries; i++ {
-		req, err := http.NewRequest("GET", url, nil)
+		if i > 0 {
+			if err := sleepContext(ctx, backoffDelay(p.retryBackoff(), i)); err != nil {
+				return nil, fmt.Errorf("failed to fetch search results: %w", err)
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "POST", form.Action, strings.NewReader(form.Values.Encode()))
 		if err != nil {
-			continue
+			return nil, fmt.Errorf("error creating request: %w", err)
 		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		req.Header.Set("User-Agent", randomUserAgent())
 		req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
 		req.Header.Set("Accept-Language", "en-US,en;q=0.5")
-		resp, err = client.Do(req)
-		if err == nil && resp.StatusCode == 200 {
-			break
+
+		resp, err := client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("failed to fetch search results: %w", err)
+			}
+			lastErr = err
+			continue
 		}
-		time.Sleep(time.Duration(i+1) * time.Second)
+
+		// DuckDuckGo answers rate-limited clients with 202 or 403 and an
+		// anomaly page instead of results
+		if resp.StatusCode == http.StatusAccepted || resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
+			resp.Body.Close()
+			lastErr = &BlockedError{Provider: "duckduckgo", StatusCode: resp.StatusCode, Reason: "rate limited"}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			lastErr = fmt.Errorf("unexpected status %s", resp.Status)
+			continue
+		}
+
+		doc, err := goquery.NewDocumentFromReader(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if reason := ddgBlockReason(doc); reason != "" {
+			lastErr = &BlockedError{Provider: "duckduckgo", StatusCode: resp.StatusCode, Reason: reason}
+			continue
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("failed to fetch search results: %w", lastErr)
+}
+
+// ddgBlockMarkers are phrases from DuckDuckGo's anomaly page.
+var ddgBlockMarkers = []string{
+	"bots use duckduckgo too",
+	"complete the following challenge",
+	"unusual traffic",
+}
+
+// returns why a page is a block page rather than results, or "" if it isn't
+func ddgBlockReason(doc *goquery.Document) string {
+	if doc.Find(".anomaly-modal, #challenge-form, form[action*='anomaly']").Length() > 0 {
+		return "anomaly page"
 	}
-	if err != nil || resp == nil || resp.StatusCode != 200 {
-		return nil, "", errors.New("failed to fetch search results")
+	// Result snippets may mention the marker phrases themselves
+	if doc.Find(".result, .web-result").Not(".result--ad").Length() > 0 || doc.Find("a.result-link").Length() > 0 {
+		return ""
 	}
-	defer resp.Body.Close()
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return nil, "", err
+	text := strings.ToLower(doc.Find("body").Text())
+	for _, marker := range ddgBlockMarkers {
+		if strings.Contains(text, marker) {
+			return "CAPTCHA challenge"
+		}
 	}
-	doc.Find(".result, .web-result").Each(func(i int, s *goquery.Selection) {
+	return ""
+}
+
+// parses results from the html.duckduckgo.com layout, skipping ads
+func (p *DuckDuckGoSearchProvider) parseHTMLResults(doc *goquery.Document) []PageInfo {
+	var results []PageInfo
+	doc.Find(".result, .web-result").Not(".result--ad").Each(func(i int, s *goquery.Selection) {
 		titleSel := s.Find(".result__title, .result__a")
-		title := titleSel.Text()
+		title := titleSel.First().Text()
 		snippet := s.Find(".result__snippet").Text()
 		href, exists := s.Find("a.result__url").Attr("href")
 		if !exists {
@@ -134,16 +349,70 @@ func (p *DuckDuckGoSearchProvider) scrapePage(url string, maxRetries int) ([]Pag
 			})
 		}
 	})
-	nextURL := ""
-	doc.Find(".nav-link").Each(func(i int, s *goquery.Selection) {
-		if strings.Contains(strings.ToLower(s.Text()), "next") {
-			nextURL, _ = s.Attr("href")
-			if !strings.HasPrefix(nextURL, "http") {
-				nextURL = "https://html.duckduckgo.com" + nextURL
+	return results
+}
+
+// parses results from the lite.duckduckgo.com layout, where each result is a
+// run of table rows: link, snippet, then display URL and date
+func (p *DuckDuckGoSearchProvider) parseLiteResults(doc *goquery.Document) []PageInfo {
+	var results []PageInfo
+	doc.Find("a.result-link").Each(func(i int, s *goquery.Selection) {
+		row := s.Closest("tr")
+		if row.HasClass("result-sponsored") {
+			return
+		}
+		href, _ := s.Attr("href")
+		if href = strings.TrimSpace(href); href == "" {
+			return
+		}
+
+		snippet := strings.TrimSpace(row.Next().Find(".result-snippet").Text())
+		pubDate := time.Now()
+		if dateStr := strings.TrimSpace(row.Next().Next().Find(".timestamp").Text()); dateStr != "" {
+			if parsed, err := time.Parse("2006-01-02", dateStr); err == nil {
+				pubDate = parsed
+			}
+		}
+
+		results = append(results, PageInfo{
+			URL:       p.cleanUrl(href),
+			Title:     strings.TrimSpace(s.Text()),
+			Content:   snippet,
+			Summary:   snippet,
+			Published: pubDate,
+		})
+	})
+	return results
+}
+
+// finds the form whose submit button reads "Next" and returns its action
+// (resolved against base) and fields, or nil on the last page
+func nextPageForm(doc *goquery.Document, base *url.URL) *ddgForm {
+	var next *ddgForm
+	doc.Find("form").EachWithBreak(func(i int, form *goquery.Selection) bool {
+		submit := form.Find("input[type=submit]")
+		value, _ := submit.Attr("value")
+		if !strings.Contains(strings.ToLower(value), "next") {
+			return true
+		}
+
+		values := url.Values{}
+		form.Find("input[type=hidden]").Each(func(i int, input *goquery.Selection) {
+			if name, ok := input.Attr("name"); ok && name != "" {
+				value, _ := input.Attr("value")
+				values.Add(name, value)
 			}
+		})
+
+		action, _ := form.Attr("action")
+		target, err := base.Parse(action)
+		if err != nil {
+			return true
 		}
+		next = &ddgForm{Action: target.String(), Values: values}
+		return false
 	})
-	return results, nextURL, nil
+	return next
 }
 
 func (p *DuckDuckGoSearchProvider) cleanUrl(href string) string {
@@ -162,117 +431,3 @@ func (p *DuckDuckGoSearchProvider) cleanUrl(href string) string {
 	}
 	return href
 }
-
-func (p *DuckDuckGoSearchProvider) enrichResultContent(result *PageInfo) {
-	if strings.HasSuffix(result.URL, ".pdf") || strings.HasSuffix(result.URL, ".doc") ||
-		strings.HasSuffix(result.URL, ".docx") || strings.HasSuffix(result.URL, ".xlsx") {
-		return
-	}
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-	req, err := http.NewRequest("GET", result.URL, nil)
-	if err != nil {
-		return
-	}
-	req.Header.Set("User-Agent", randomUserAgent())
-	resp, err := client.Do(req)
-	if err != nil || resp.StatusCode != 200 {
-		return
-	}
-	defer resp.Body.Close()
-	contentType := resp.Header.Get("Content-Type")
-	if !strings.Contains(contentType, "text/html") {
-		return
-	}
-	baseURL, _ := url.Parse(result.URL)
-	limitedReader := io.LimitReader(resp.Body, 1024*1024)
-	article, err := readability.FromReader(limitedReader, baseURL)
-	if err != nil {
-		return
-	}
-	if article.Title != "" {
-		result.Title = article.Title
-	}
-	content := p.cleanText(article.TextContent)
-	result.Content = content
-	if len(content) > 0 {
-		result.Summary = p.generateSummary(content)
-	}
-	if result.Published.IsZero() || result.Published.Equal(time.Now()) {
-		if lastMod := resp.Header.Get("Last-Modified"); lastMod != "" {
-			if pubTime, err := time.Parse(time.RFC1123, lastMod); err == nil {
-				result.Published = pubTime
-			}
-		} else {
-			result.Published = time.Now()
-		}
-	}
-}
-
-func (p *DuckDuckGoSearchProvider) generateSummary(content string) string {
-	sentences := splitToSentences(content)
-	if len(sentences) == 0 {
-		return ""
-	}
-	var summary strings.Builder
-	totalLength := 0
-	maxLength := 300
-	for i, sentence := range sentences {
-		if i >= 3 || totalLength+len(sentence) > maxLength {
-			break
-		}
-		if summary.Len() > 0 {
-			summary.WriteString(" ")
-		}
-		summary.WriteString(sentence)
-		totalLength += len(sentence)
-	}
-	return summary.String()
-}
-
-func splitToSentences(text string) []string {
-	sentenceEnders := regexp.MustCompile(`[.!?]`)
-	parts := sentenceEnders.Split(text, -1)
-	var sentences []string
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if len(part) > 10 {
-			sentences = append(sentences, part+".")
-		}
-	}
-	return sentences
-}
-
-func (p *DuckDuckGoSearchProvider) cleanText(text string) string {
-	text = strings.ReplaceAll(text, "\n", " ")
-	text = strings.ReplaceAll(text, "\t", " ")
-	text = strings.ReplaceAll(text, "\r", " ")
-	multipleSpaces := regexp.MustCompile(`\s+`)
-	text = multipleSpaces.ReplaceAllString(text, " ")
-	unwantedPatterns := []string{
-		`(?i)related articles:.*`,
-		`(?i)see also:.*`,
-		`(?i)references\s*\d*.*`,
-		`(?i)external links:.*`,
-		`(?i)share this article.*`,
-		`(?i)you might also like.*`,
-		`(?i)advertisement.*`,
-		`(?i)subscribe for more.*`,
-		`(?i)trending now:.*`,
-		`(?i)follow us on.*`,
-		`(?i)comments.*`,
-		`(?i)leave a reply.*`,
-		`(?i)watch now:.*`,
-		`(?i)click here.*`,
-		`(?i)continue reading.*`,
-		`(?i)skip to main content.*`,
-		`(?i)privacy policy.*`,
-		`(?i)terms of service.*`,
-	}
-	for _, pattern := range unwantedPatterns {
-		re := regexp.MustCompile(pattern)
-		text = re.ReplaceAllString(text, "")
-	}
-	return strings.TrimSpace(text)
-}
@@ -0,0 +1,117 @@
+package webscrape
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"open-sonar/internal/proxy"
+)
+
+// DuckDuckGoInstantAnswerURL is the Instant Answer API endpoint.
+const DuckDuckGoInstantAnswerURL = "https://api.duckduckgo.com/"
+
+// ddgInstantAnswer is the subset of the Instant Answer API response we use.
+type ddgInstantAnswer struct {
+	Heading          string `json:"Heading"`
+	AbstractText     string `json:"AbstractText"`
+	AbstractSource   string `json:"AbstractSource"`
+	AbstractURL      string `json:"AbstractURL"`
+	Answer           string `json:"Answer"`
+	AnswerType       string `json:"AnswerType"`
+	Definition       string `json:"Definition"`
+	DefinitionSource string `json:"DefinitionSource"`
+	DefinitionURL    string `json:"DefinitionURL"`
+}
+
+// fetches the zero-click answer for a query; it returns nil without error
+// when DuckDuckGo has none
+func (p *DuckDuckGoSearchProvider) instantAnswer(ctx context.Context, query string) (*PageInfo, error) {
+	endpoint := p.InstantAnswerURL
+	if endpoint == "" {
+		endpoint = DuckDuckGoInstantAnswerURL
+	}
+	params := url.Values{
+		"q":             {query},
+		"format":        {"json"},
+		"no_html":       {"1"},
+		"no_redirect":   {"1"},
+		"skip_disambig": {"1"},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", randomUserAgent())
+
+	client := p.Client
+	if client == nil {
+		client = proxy.NewClient(proxy.Search, 10*time.Second)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DuckDuckGo Instant Answer API error: %s", resp.Status)
+	}
+
+	var answer ddgInstantAnswer
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return nil, fmt.Errorf("error parsing response: %w", err)
+	}
+	return answer.pageInfo(query), nil
+}
+
+// turns an instant answer into a result, preferring a direct answer, then the
+// abstract, then a definition. Any other parts are kept in Content.
+func (a ddgInstantAnswer) pageInfo(query string) *PageInfo {
+	answer := strings.TrimSpace(a.Answer)
+	abstract := strings.TrimSpace(a.AbstractText)
+	definition := strings.TrimSpace(a.Definition)
+	if answer == "" && abstract == "" && definition == "" {
+		return nil
+	}
+
+	result := &PageInfo{
+		Title:         strings.TrimSpace(a.Heading),
+		InstantAnswer: true,
+		Published:     time.Now(),
+	}
+
+	var parts []string
+	for _, part := range []string{answer, abstract, definition} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	result.Summary = parts[0]
+	result.Content = strings.Join(parts, "\n\n")
+
+	// Cite the page the leading part came from; computed answers such as
+	// conversions have none, so they cite the DuckDuckGo search itself
+	if answer != "" || abstract != "" {
+		result.URL = a.AbstractURL
+	} else {
+		result.URL = a.DefinitionURL
+	}
+	if result.URL == "" {
+		result.URL = "https://duckduckgo.com/?" + url.Values{"q": {query}}.Encode()
+	}
+
+	if result.Title == "" {
+		result.Title = "DuckDuckGo Instant Answer"
+		if a.AnswerType != "" {
+			result.Title += " (" + a.AnswerType + ")"
+		}
+	}
+	return result
+}
@@ -0,0 +1,149 @@
+package webscrape
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDuckDuckGoInstantAnswer(t *testing.T) {
+	var iaQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fixture := "ddg_html_page2.html"
+		if r.URL.Path == "/ia/" {
+			iaQuery = r.URL.Query().Get("q")
+			if r.URL.Query().Get("format") != "json" {
+				t.Errorf("Expected format=json, got %s", r.URL.RawQuery)
+			}
+			fixture = "ddg_instant_answer.json"
+		}
+		body, err := os.ReadFile("testdata/" + fixture)
+		if err != nil {
+			t.Errorf("Failed to read fixture: %v", err)
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	provider := &DuckDuckGoSearchProvider{
+		HTMLURL:          server.URL + "/html/",
+		InstantAnswerURL: server.URL + "/ia/",
+		Client:           server.Client(),
+		InstantAnswers:   true,
+		PageDelay:        time.Millisecond,
+	}
+	results, err := provider.Search("golang", SearchOptions{MaxPages: 1, MaxRetries: 1})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if iaQuery != "golang" {
+		t.Errorf("Expected the Instant Answer API to be queried, got %q", iaQuery)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected the instant answer plus 2 results, got %d: %+v", len(results), results)
+	}
+
+	answer := results[0]
+	if !answer.InstantAnswer {
+		t.Errorf("Expected the first result to be the instant answer, got %+v", answer)
+	}
+	if answer.URL != "https://en.wikipedia.org/wiki/Go_(programming_language)" || answer.Title != "Go (programming language)" {
+		t.Errorf("Expected the abstract's source, got %s %q", answer.URL, answer.Title)
+	}
+	if answer.Summary == "" || answer.Summary != answer.Content {
+		t.Errorf("Expected the abstract as summary and content, got %q / %q", answer.Summary, answer.Content)
+	}
+	if results[1].InstantAnswer {
+		t.Errorf("Expected scraped results not to be marked")
+	}
+}
+
+func TestDuckDuckGoInstantAnswerFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ia/" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := os.ReadFile("testdata/ddg_html_page2.html")
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	provider := &DuckDuckGoSearchProvider{
+		HTMLURL:          server.URL + "/html/",
+		InstantAnswerURL: server.URL + "/ia/",
+		Client:           server.Client(),
+		InstantAnswers:   true,
+	}
+	results, err := provider.Search("golang", SearchOptions{MaxPages: 1, MaxRetries: 1})
+	if err != nil || len(results) != 2 || results[0].InstantAnswer {
+		t.Errorf("Expected scraped results despite the failed instant answer, got %+v (%v)", results, err)
+	}
+}
+
+func TestInstantAnswerPageInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		answer  ddgInstantAnswer
+		url     string
+		title   string
+		summary string
+	}{
+		{
+			name:   "empty",
+			answer: ddgInstantAnswer{Heading: "Nothing"},
+		},
+		{
+			name:    "computed answer",
+			answer:  ddgInstantAnswer{Answer: "10 miles = 16.09 kilometers", AnswerType: "conversions"},
+			url:     "https://duckduckgo.com/?q=10+miles+in+km",
+			title:   "DuckDuckGo Instant Answer (conversions)",
+			summary: "10 miles = 16.09 kilometers",
+		},
+		{
+			name:    "definition",
+			answer:  ddgInstantAnswer{Heading: "Ephemeral", Definition: "ephemeral definition: lasting a very short time.", DefinitionURL: "https://www.merriam-webster.com/dictionary/ephemeral"},
+			url:     "https://www.merriam-webster.com/dictionary/ephemeral",
+			title:   "Ephemeral",
+			summary: "ephemeral definition: lasting a very short time.",
+		},
+		{
+			name:    "abstract before definition",
+			answer:  ddgInstantAnswer{Heading: "Go", AbstractText: "Go is a language.", AbstractURL: "https://en.wikipedia.org/wiki/Go", Definition: "go: to move.", DefinitionURL: "https://example.com/go"},
+			url:     "https://en.wikipedia.org/wiki/Go",
+			title:   "Go",
+			summary: "Go is a language.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.answer.pageInfo("10 miles in km")
+			if tt.url == "" {
+				if result != nil {
+					t.Errorf("Expected no result, got %+v", result)
+				}
+				return
+			}
+			if result == nil {
+				t.Fatal("Expected a result")
+			}
+			if result.URL != tt.url || result.Title != tt.title || result.Summary != tt.summary || !result.InstantAnswer {
+				t.Errorf("Unexpected result %+v", result)
+			}
+		})
+	}
+}
+
+func TestWithInstantAnswer(t *testing.T) {
+	answer := &PageInfo{URL: "https://en.wikipedia.org/wiki/Go", InstantAnswer: true}
+	results := withInstantAnswer(answer, pages("https://go.dev/", "https://en.wikipedia.org/wiki/Go/"))
+	if len(results) != 2 || !results[0].InstantAnswer || results[1].URL != "https://go.dev/" {
+		t.Errorf("Expected the instant answer first without its duplicate, got %+v", results)
+	}
+	if results := withInstantAnswer(nil, pages("https://go.dev/")); len(results) != 1 {
+		t.Errorf("Expected results unchanged without an answer, got %+v", results)
+	}
+}
@@ -0,0 +1,149 @@
+package webscrape
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"open-sonar/internal/proxy"
+)
+
+// DuckDuckGo news endpoints: the site page hands out the vqd token that the
+// news vertical's JSON endpoint requires.
+const (
+	DuckDuckGoSiteURL = "https://duckduckgo.com/"
+	DuckDuckGoNewsURL = "https://duckduckgo.com/news.js"
+)
+
+var vqdPattern = regexp.MustCompile(`vqd=["']?([0-9-]+)`)
+
+// maxNewsResponseBytes caps how much of a site page or news response is read.
+const maxNewsResponseBytes = maxPageBytes
+
+type ddgNewsResponse struct {
+	Results []ddgNewsResult `json:"results"`
+}
+
+type ddgNewsResult struct {
+	Date    int64  `json:"date"` // Unix seconds
+	Excerpt string `json:"excerpt"`
+	Source  string `json:"source"`
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+}
+
+// ddgNewsRecency maps recency filters to the news vertical's df parameter.
+var ddgNewsRecency = map[string]string{
+	"hour":  "d",
+	"day":   "d",
+	"week":  "w",
+	"month": "m",
+}
+
+// searches DuckDuckGo's news vertical
+func (p *DuckDuckGoSearchProvider) searchNews(query string, options SearchOptions) ([]PageInfo, error) {
+	ctx := options.requestContext()
+	vqd, err := p.newsToken(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{
+		"q":     {query},
+		"vqd":   {vqd},
+		"l":     {"us-en"},
+		"o":     {"json"},
+		"noamp": {"1"},
+	}
+	if df, ok := ddgNewsRecency[strings.ToLower(options.SearchRecencyFilter)]; ok {
+		params.Set("df", df)
+	}
+
+	endpoint := p.NewsURL
+	if endpoint == "" {
+		endpoint = DuckDuckGoNewsURL
+	}
+	body, err := p.get(ctx, endpoint+"?"+params.Encode(), "application/json")
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded ddgNewsResponse
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return nil, fmt.Errorf("error parsing news response: %w", err)
+	}
+
+	results := make([]PageInfo, 0, len(decoded.Results))
+	for _, item := range decoded.Results {
+		if item.URL == "" || item.Date == 0 {
+			// Without a date a story can't be placed in a briefing
+			continue
+		}
+		excerpt := strings.TrimSpace(html.UnescapeString(htmlTagPattern.ReplaceAllString(item.Excerpt, "")))
+		title := strings.TrimSpace(html.UnescapeString(item.Title))
+		if item.Source != "" {
+			title += " - " + item.Source
+		}
+		results = append(results, PageInfo{
+			URL:       item.URL,
+			Title:     title,
+			Content:   excerpt,
+			Summary:   excerpt,
+			Published: time.Unix(item.Date, 0).UTC(),
+		})
+	}
+	return results, nil
+}
+
+// fetches the vqd token for a query from the DuckDuckGo site page
+func (p *DuckDuckGoSearchProvider) newsToken(ctx context.Context, query string) (string, error) {
+	endpoint := p.SiteURL
+	if endpoint == "" {
+		endpoint = DuckDuckGoSiteURL
+	}
+	body, err := p.get(ctx, endpoint+"?"+url.Values{"q": {query}, "ia": {"news"}}.Encode(), "text/html")
+	if err != nil {
+		return "", err
+	}
+	match := vqdPattern.FindSubmatch(body)
+	if match == nil {
+		return "", &BlockedError{Provider: "duckduckgo", Reason: "no vqd token in search page"}
+	}
+	return string(match[1]), nil
+}
+
+// GETs a URL, treating DuckDuckGo's rate-limit statuses as blocks
+func (p *DuckDuckGoSearchProvider) get(ctx context.Context, target string, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", randomUserAgent())
+	req.Header.Set("Accept", accept)
+
+	client := p.Client
+	if client == nil {
+		client = proxy.NewClient(proxy.Search, 30*time.Second)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusAccepted, http.StatusForbidden, http.StatusTooManyRequests:
+		return nil, &BlockedError{Provider: "duckduckgo", StatusCode: resp.StatusCode, Reason: "rate limited"}
+	default:
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxNewsResponseBytes))
+}
@@ -0,0 +1,291 @@
+package webscrape
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// ddgTestServer serves recorded DuckDuckGo pages and records submitted forms
+type ddgTestServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []string
+	forms    []url.Values
+}
+
+func newDDGTestServer(t *testing.T, routes map[string]func(form url.Values) string) *ddgTestServer {
+	t.Helper()
+	s := &ddgTestServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "expected POST", http.StatusMethodNotAllowed)
+			return
+		}
+		r.ParseForm()
+		s.mu.Lock()
+		s.requests = append(s.requests, r.URL.Path)
+		s.forms = append(s.forms, r.PostForm)
+		s.mu.Unlock()
+
+		route, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fixture := route(r.PostForm)
+		body, err := os.ReadFile("testdata/" + fixture)
+		if err != nil {
+			t.Errorf("Failed to read fixture %s: %v", fixture, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		w.Write(body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *ddgTestServer) provider() *DuckDuckGoSearchProvider {
+	return &DuckDuckGoSearchProvider{
+		HTMLURL:   s.URL + "/html/",
+		LiteURL:   s.URL + "/lite/",
+		Client:    s.Client(),
+		PageDelay: time.Millisecond,
+	}
+}
+
+func TestDuckDuckGoFormPagination(t *testing.T) {
+	server := newDDGTestServer(t, map[string]func(url.Values) string{
+		"/html/": func(form url.Values) string {
+			if form.Get("s") == "10" && form.Get("dc") == "11" && form.Get("vqd") != "" {
+				return "ddg_html_page2.html"
+			}
+			return "ddg_html_page1.html"
+		},
+	})
+
+	results, err := server.provider().Search("golang generics", SearchOptions{MaxPages: 3, MaxRetries: 1})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	// Page 2 has only a "Previous" form, so pagination stops there
+	if len(server.requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d: %v", len(server.requests), server.requests)
+	}
+	if server.forms[0].Get("q") != "golang generics" {
+		t.Errorf("Expected query in first form, got %v", server.forms[0])
+	}
+	next := server.forms[1]
+	if next.Get("q") != "golang generics" || next.Get("vqd") != "4-213981178512350931706316374557411442551" || next.Get("kl") != "wt-wt" {
+		t.Errorf("Expected hidden fields to be carried to page 2, got %v", next)
+	}
+
+	expected := []string{
+		"https://go.dev/doc/tutorial/generics",
+		"https://go.dev/blog/intro-generics",
+		"https://gobyexample.com/generics",
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results without ads or duplicates, got %d: %+v", len(expected), len(results), results)
+	}
+	for i, u := range expected {
+		if results[i].URL != u {
+			t.Errorf("Result %d: expected %s, got %s", i, u, results[i].URL)
+		}
+	}
+	if results[0].Title != "Tutorial: Getting started with generics - The Go Programming Language" {
+		t.Errorf("Unexpected title %q", results[0].Title)
+	}
+	if results[1].Content != "The Go 1.18 release adds support for generics." {
+		t.Errorf("Unexpected snippet %q", results[1].Content)
+	}
+}
+
+func TestDuckDuckGoLiteFallback(t *testing.T) {
+	server := newDDGTestServer(t, map[string]func(url.Values) string{
+		// A page whose markup no longer matches the HTML selectors
+		"/html/": func(url.Values) string { return "ddg_lite_page1.html" },
+		"/lite/": func(url.Values) string { return "ddg_lite_page1.html" },
+	})
+
+	results, err := server.provider().Search("golang generics", SearchOptions{MaxPages: 1, MaxRetries: 1})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	if len(server.requests) != 2 || server.requests[1] != "/lite/" {
+		t.Fatalf("Expected a fallback request to /lite/, got %v", server.requests)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results without the sponsored link, got %d: %+v", len(results), results)
+	}
+	if results[0].URL != "https://go.dev/doc/tutorial/generics" {
+		t.Errorf("Expected redirect URL to be unwrapped, got %s", results[0].URL)
+	}
+	if results[0].Summary != "This tutorial introduces the basics of generics in Go." {
+		t.Errorf("Unexpected snippet %q", results[0].Summary)
+	}
+	if !results[1].Published.Equal(time.Date(2022, 3, 22, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected lite timestamp to be parsed, got %v", results[1].Published)
+	}
+}
+
+func TestDuckDuckGoNoResults(t *testing.T) {
+	server := newDDGTestServer(t, map[string]func(url.Values) string{
+		"/html/": func(url.Values) string { return "ddg_html_no_results.html" },
+		"/lite/": func(url.Values) string { return "ddg_lite_page1.html" },
+	})
+
+	results, err := server.provider().Search("qwzxqwzxqwzx", SearchOptions{MaxPages: 2, MaxRetries: 1})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no results, got %d", len(results))
+	}
+	if len(server.requests) != 1 {
+		t.Errorf("Expected no lite fallback for an explicit no-results page, got %v", server.requests)
+	}
+}
+
+func TestDuckDuckGoBlockDetection(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+	}{
+		{name: "anomaly page with 202", status: http.StatusAccepted},
+		{name: "forbidden", status: http.StatusForbidden},
+		{name: "anomaly page with 200", status: http.StatusOK},
+	}
+
+	anomaly, err := os.ReadFile("testdata/ddg_anomaly.html")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.WriteHeader(tt.status)
+				w.Write(anomaly)
+			}))
+			defer server.Close()
+
+			provider := &DuckDuckGoSearchProvider{
+				HTMLURL:      server.URL + "/html/",
+				LiteURL:      server.URL + "/lite/",
+				Client:       server.Client(),
+				RetryBackoff: time.Millisecond,
+			}
+			results, err := provider.Search("golang generics", SearchOptions{MaxPages: 2, MaxRetries: 3})
+			if !errors.Is(err, ErrBlocked) {
+				t.Fatalf("Expected a blocked error, got %v", err)
+			}
+			var blocked *BlockedError
+			if !errors.As(err, &blocked) || blocked.StatusCode != tt.status {
+				t.Errorf("Expected status %d in %v", tt.status, err)
+			}
+			if len(results) != 0 {
+				t.Errorf("Expected no results, got %d", len(results))
+			}
+			// Retried with backoff, and no fallback to the lite layout
+			if requests != 3 {
+				t.Errorf("Expected 3 attempts, got %d", requests)
+			}
+		})
+	}
+}
+
+func TestDuckDuckGoBlockMarkersInResults(t *testing.T) {
+	page, err := os.ReadFile("testdata/ddg_html_page1.html")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	// A snippet about bot detection quoting the anomaly page's wording
+	html := strings.Replace(string(page), "This tutorial introduces the basics", "Sites flag unusual traffic when bots use DuckDuckGo too. This tutorial introduces the basics", 1)
+
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"results mentioning the markers", html, ""},
+		{"challenge text without results", "<html><body><p>Unfortunately, bots use DuckDuckGo too.</p></body></html>", "CAPTCHA challenge"},
+	}
+	for _, tt := range tests {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(tt.html))
+		if err != nil {
+			t.Fatalf("Failed to parse page: %v", err)
+		}
+		if got := ddgBlockReason(doc); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestDuckDuckGoNews(t *testing.T) {
+	var newsQuery url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fixture := "ddg_news_token.html"
+		if r.URL.Path == "/news.js" {
+			newsQuery = r.URL.Query()
+			fixture = "ddg_news.json"
+		}
+		body, err := os.ReadFile("testdata/" + fixture)
+		if err != nil {
+			t.Errorf("Failed to read fixture: %v", err)
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	provider := &DuckDuckGoSearchProvider{
+		SiteURL: server.URL + "/",
+		NewsURL: server.URL + "/news.js",
+		Client:  server.Client(),
+	}
+	results, err := provider.Search("interest rates", SearchOptions{SearchMode: SearchModeNews, SearchRecencyFilter: "week"})
+	if err != nil {
+		t.Fatalf("News search failed: %v", err)
+	}
+
+	if newsQuery.Get("vqd") != "4-1234567890123456789012345678901234567" || newsQuery.Get("df") != "w" || newsQuery.Get("q") != "interest rates" {
+		t.Errorf("Expected the vqd token and recency in the news request, got %v", newsQuery)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 dated stories, got %d: %+v", len(results), results)
+	}
+	if results[0].Title != "Central bank holds interest rates steady - Example Wire" {
+		t.Errorf("Unexpected title %q", results[0].Title)
+	}
+	if results[0].Summary != "The central bank kept its benchmark interest rate unchanged on Tuesday & signalled caution." {
+		t.Errorf("Expected markup stripped from the excerpt, got %q", results[0].Summary)
+	}
+	if !results[0].Published.Equal(time.Unix(1760431800, 0)) {
+		t.Errorf("Expected the story date, got %v", results[0].Published)
+	}
+}
+
+func TestDuckDuckGoNewsWithoutToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>No token here</body></html>"))
+	}))
+	defer server.Close()
+
+	provider := &DuckDuckGoSearchProvider{SiteURL: server.URL + "/", NewsURL: server.URL + "/news.js", Client: server.Client()}
+	if _, err := provider.Search("interest rates", SearchOptions{SearchMode: SearchModeNews}); !errors.Is(err, ErrBlocked) {
+		t.Errorf("Expected a missing token to be treated as a block, got %v", err)
+	}
+}
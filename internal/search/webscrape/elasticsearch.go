@@ -0,0 +1,372 @@
+package webscrape
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"open-sonar/internal/utils"
+)
+
+// DefaultElasticsearchQueryTemplate runs a multi_match query across the
+// configured fields, filtered by the date field when a recency filter is set.
+const DefaultElasticsearchQueryTemplate = `{
+  "from": {{.From}},
+  "size": {{.Size}},
+  "query": {
+    "bool": {
+      "must": {
+        "multi_match": {
+          "query": {{json .Query}},
+          "fields": {{json .Fields}},
+          "type": "best_fields"
+        }
+      }{{if .Since}},
+      "filter": {
+        "range": { {{json .DateField}}: { "gte": {{json .Since}} } }
+      }{{end}}
+    }
+  }
+}`
+
+// elasticsearchPageSize is the number of hits requested per page.
+const elasticsearchPageSize = 10
+
+// ElasticsearchSearchProvider queries an Elasticsearch or OpenSearch
+// _search endpoint and maps hits into PageInfo using configured field names.
+// Field names may be dotted paths into _source, e.g. "meta.url".
+type ElasticsearchSearchProvider struct {
+	BaseURL  string
+	Index    string
+	Username string
+	Password string
+	APIKey   string
+
+	// Fields are the multi_match fields, optionally boosted ("title^3")
+	Fields     []string
+	TitleField string
+	URLField   string
+	BodyField  string
+	DateField  string
+
+	// QueryTemplate is a text/template producing the JSON request body. It
+	// receives .Query, .Fields, .From, .Size, .DateField and .Since (RFC 3339,
+	// empty without a recency filter) and may call {{json .X}} to encode values.
+	QueryTemplate string
+
+	Client *http.Client
+
+	initOnce sync.Once
+	initErr  error
+	tmpl     *template.Template
+}
+
+// ElasticsearchQuery is the data passed to the query template.
+type ElasticsearchQuery struct {
+	Query     string
+	Fields    []string
+	From      int
+	Size      int
+	DateField string
+	Since     string
+}
+
+type elasticsearchResponse struct {
+	Hits struct {
+		Hits []struct {
+			ID     string                 `json:"_id"`
+			Source map[string]interface{} `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+var elasticsearchTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func init() {
+	RegisterSearchProvider("elasticsearch", func(config ProviderConfig) (SearchProvider, error) {
+		provider, err := NewElasticsearchSearchProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	})
+	RegisterSearchProvider("opensearch", func(config ProviderConfig) (SearchProvider, error) {
+		provider, err := NewElasticsearchSearchProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	})
+}
+
+// NewElasticsearchSearchProvider creates a provider from ELASTICSEARCH_* settings.
+func NewElasticsearchSearchProvider(config ProviderConfig) (*ElasticsearchSearchProvider, error) {
+	baseURL := config.Get("ELASTICSEARCH_URL")
+	if baseURL == "" {
+		return nil, fmt.Errorf("ELASTICSEARCH_URL not set")
+	}
+
+	p := &ElasticsearchSearchProvider{
+		BaseURL:       strings.TrimRight(baseURL, "/"),
+		Index:         config.Get("ELASTICSEARCH_INDEX"),
+		Username:      config.Get("ELASTICSEARCH_USERNAME"),
+		Password:      config.Get("ELASTICSEARCH_PASSWORD"),
+		APIKey:        config.Get("ELASTICSEARCH_API_KEY"),
+		Fields:        splitList(config.Get("ELASTICSEARCH_FIELDS")),
+		TitleField:    config.Get("ELASTICSEARCH_TITLE_FIELD"),
+		URLField:      config.Get("ELASTICSEARCH_URL_FIELD"),
+		BodyField:     config.Get("ELASTICSEARCH_BODY_FIELD"),
+		DateField:     config.Get("ELASTICSEARCH_DATE_FIELD"),
+		QueryTemplate: config.Get("ELASTICSEARCH_QUERY_TEMPLATE"),
+		Client:        &http.Client{Timeout: 30 * time.Second},
+	}
+
+	// A template may also be given as a file path
+	if path := config.Get("ELASTICSEARCH_QUERY_TEMPLATE_FILE"); path != "" && p.QueryTemplate == "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading Elasticsearch query template: %w", err)
+		}
+		p.QueryTemplate = string(raw)
+	}
+
+	if err := p.prepare(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// prepare runs init once, so providers built as struct literals work too
+func (p *ElasticsearchSearchProvider) prepare() error {
+	p.initOnce.Do(func() { p.initErr = p.init() })
+	return p.initErr
+}
+
+// fills in default field names and parses the query template
+func (p *ElasticsearchSearchProvider) init() error {
+	if p.TitleField == "" {
+		p.TitleField = "title"
+	}
+	if p.URLField == "" {
+		p.URLField = "url"
+	}
+	if p.BodyField == "" {
+		p.BodyField = "body"
+	}
+	if p.DateField == "" {
+		p.DateField = "date"
+	}
+	if len(p.Fields) == 0 {
+		p.Fields = []string{p.TitleField + "^3", p.BodyField}
+	}
+	if p.QueryTemplate == "" {
+		p.QueryTemplate = DefaultElasticsearchQueryTemplate
+	}
+
+	tmpl, err := template.New("elasticsearch").Funcs(elasticsearchTemplateFuncs).Option("missingkey=error").Parse(p.QueryTemplate)
+	if err != nil {
+		return fmt.Errorf("parsing Elasticsearch query template: %w", err)
+	}
+	p.tmpl = tmpl
+
+	// Render once so a template producing invalid JSON fails at startup
+	if _, err := p.queryBody(ElasticsearchQuery{Query: "sample", Fields: p.Fields, Size: elasticsearchPageSize, DateField: p.DateField, Since: time.Now().Format(time.RFC3339)}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Search fetches up to options.MaxPages pages of hits.
+func (p *ElasticsearchSearchProvider) Search(query string, options SearchOptions) ([]PageInfo, error) {
+	if err := p.prepare(); err != nil {
+		return nil, err
+	}
+	if options.MaxPages <= 0 {
+		options.MaxPages = 1
+	}
+
+	timer := utils.NewTimer("Elasticsearch search")
+	defer timer.Stop()
+
+	var since string
+	if options.SearchRecencyFilter != "" {
+		if minTime, err := RecencyToTime(options.SearchRecencyFilter); err == nil && !minTime.IsZero() {
+			since = minTime.UTC().Format(time.RFC3339)
+		}
+	}
+
+	var results []PageInfo
+	seen := make(map[string]bool)
+	terms := uniqueTerms(tokenize(query))
+
+	for page := 0; page < options.MaxPages; page++ {
+		body, err := p.queryBody(ElasticsearchQuery{
+			Query:     query,
+			Fields:    p.Fields,
+			From:      page * elasticsearchPageSize,
+			Size:      elasticsearchPageSize,
+			DateField: p.DateField,
+			Since:     since,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		pageResults, hits, err := p.fetchPage(options.requestContext(), body, terms)
+		if err != nil {
+			// Keep what earlier pages returned
+			if page > 0 {
+				utils.Warn(fmt.Sprintf("Elasticsearch page %d failed: %v", page+1, err))
+				break
+			}
+			return nil, err
+		}
+
+		for _, result := range pageResults {
+			if seen[result.URL] {
+				continue
+			}
+			seen[result.URL] = true
+			results = append(results, result)
+		}
+
+		if hits < elasticsearchPageSize {
+			break
+		}
+	}
+
+	return results, nil
+}
+
+// renders the query template and checks the result is valid JSON
+func (p *ElasticsearchSearchProvider) queryBody(q ElasticsearchQuery) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := p.tmpl.Execute(&buf, q); err != nil {
+		return nil, fmt.Errorf("rendering Elasticsearch query template: %w", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("Elasticsearch query template did not produce valid JSON")
+	}
+	return buf.Bytes(), nil
+}
+
+// runs one search request, summarizing each hit by its paragraph matching
+// the most query terms; hits is the number of raw hits returned
+func (p *ElasticsearchSearchProvider) fetchPage(ctx context.Context, body []byte, terms []string) ([]PageInfo, int, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", p.searchURL(), bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+p.APIKey)
+	} else if p.Username != "" {
+		req.SetBasicAuth(p.Username, p.Password)
+	}
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("Elasticsearch API error: %s", resp.Status)
+	}
+
+	var decoded elasticsearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return nil, 0, fmt.Errorf("error parsing response: %w", err)
+	}
+
+	results := make([]PageInfo, 0, len(decoded.Hits.Hits))
+	for _, hit := range decoded.Hits.Hits {
+		pageURL := sourceString(hit.Source, p.URLField)
+		if pageURL == "" {
+			// Without a URL the hit cannot be cited
+			utils.Debug(fmt.Sprintf("Skipping Elasticsearch hit %s without %s field", hit.ID, p.URLField))
+			continue
+		}
+		content := sourceString(hit.Source, p.BodyField)
+		results = append(results, PageInfo{
+			URL:       pageURL,
+			Title:     sourceString(hit.Source, p.TitleField),
+			Content:   content,
+			Summary:   bestSnippet(content, terms, 300), // bodies can be whole documents
+			Published: parseElasticsearchDate(sourceValue(hit.Source, p.DateField)),
+		})
+	}
+	return results, len(decoded.Hits.Hits), nil
+}
+
+// returns <base>/<index>/_search, or <base>/_search when no index is set
+func (p *ElasticsearchSearchProvider) searchURL() string {
+	if p.Index == "" {
+		return p.BaseURL + "/_search"
+	}
+	return p.BaseURL + "/" + p.Index + "/_search"
+}
+
+// looks up a dotted field path in a _source document
+func sourceValue(source map[string]interface{}, field string) interface{} {
+	var value interface{} = source
+	for _, part := range strings.Split(field, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[part]
+	}
+	return value
+}
+
+// returns a _source field as text, joining multi-valued fields
+func sourceString(source map[string]interface{}, field string) string {
+	switch v := sourceValue(source, field).(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case []interface{}:
+		// e.g. a body stored as a list of paragraphs
+		var parts []string
+		for _, item := range v {
+			if s, ok := item.(string); ok && strings.TrimSpace(s) != "" {
+				parts = append(parts, strings.TrimSpace(s))
+			}
+		}
+		return strings.Join(parts, "\n")
+	default:
+		return ""
+	}
+}
+
+// parses a date field stored as a date string or epoch milliseconds
+func parseElasticsearchDate(value interface{}) time.Time {
+	switch v := value.(type) {
+	case string:
+		for _, layout := range searxngDateLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+				return t
+			}
+		}
+	case float64:
+		return time.UnixMilli(int64(v)).UTC()
+	}
+	return time.Time{}
+}
@@ -0,0 +1,148 @@
+package webscrape
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestElasticsearchSearchProvider(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/elasticsearch_search.json")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	var body map[string]interface{}
+	var path, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		auth = r.Header.Get("Authorization")
+		raw, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(raw, &body); err != nil {
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(fixture)
+	}))
+	defer server.Close()
+
+	provider := &ElasticsearchSearchProvider{
+		BaseURL:    server.URL,
+		Index:      "docs",
+		APIKey:     "secret",
+		Fields:     []string{"doc_title^2", "text"},
+		TitleField: "doc_title",
+		URLField:   "meta.link",
+		BodyField:  "text",
+		DateField:  "updated_at",
+		Client:     server.Client(),
+	}
+
+	results, err := provider.Search("how do I roll back a deploy", SearchOptions{MaxPages: 3, SearchRecencyFilter: "month"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	if path != "/docs/_search" {
+		t.Errorf("Expected /docs/_search, got %s", path)
+	}
+	if auth != "ApiKey secret" {
+		t.Errorf("Expected API key auth header, got %q", auth)
+	}
+
+	boolQuery := body["query"].(map[string]interface{})["bool"].(map[string]interface{})
+	match := boolQuery["must"].(map[string]interface{})["multi_match"].(map[string]interface{})
+	if match["query"] != "how do I roll back a deploy" {
+		t.Errorf("Unexpected multi_match query: %v", match["query"])
+	}
+	if fields := match["fields"].([]interface{}); len(fields) != 2 || fields[0] != "doc_title^2" {
+		t.Errorf("Unexpected multi_match fields: %v", fields)
+	}
+	if _, ok := boolQuery["filter"].(map[string]interface{})["range"].(map[string]interface{})["updated_at"]; !ok {
+		t.Errorf("Expected range filter on updated_at, got %v", boolQuery["filter"])
+	}
+
+	// The hit without a URL is skipped; fewer hits than a page stops pagination
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].URL != "https://wiki.internal/ops/deploy-runbook" || results[0].Title != "Deployment runbook" {
+		t.Errorf("Unexpected first result: %+v", results[0])
+	}
+	if results[0].Content != "Deploys go out through the release pipeline.\nRoll back with the previous tag." {
+		t.Errorf("Expected joined body, got %q", results[0].Content)
+	}
+	if !results[0].Published.Equal(time.Date(2024, 5, 2, 8, 15, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected published date: %v", results[0].Published)
+	}
+	if !results[1].Published.Equal(time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected epoch millis date, got %v", results[1].Published)
+	}
+}
+
+func TestElasticsearchQueryTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		wantErr  bool
+	}{
+		{name: "default template", template: ""},
+		{name: "custom template", template: `{"size": {{.Size}}, "query": {"match": {"body": {{json .Query}}}}}`},
+		{name: "invalid JSON", template: `{"query": {{.Query}}}`, wantErr: true},
+		{name: "unknown field", template: `{"query": {{json .Question}}}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ELASTICSEARCH_URL", "http://localhost:9200")
+			t.Setenv("ELASTICSEARCH_QUERY_TEMPLATE", tt.template)
+
+			_, err := DefaultGetSearchProvider("opensearch")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	t.Setenv("ELASTICSEARCH_URL", "")
+	if _, err := DefaultGetSearchProvider("elasticsearch"); err == nil {
+		t.Error("Expected error when ELASTICSEARCH_URL is not set")
+	}
+}
+
+func TestElasticsearchSummaryIsBounded(t *testing.T) {
+	// A whole document as the body: unrelated paragraphs around the answer
+	filler := strings.Repeat("Quarterly planning notes for the platform team. ", 40)
+	document := filler + "\n\nTo rotate the signing key, run keyctl rotate and restart the gateway.\n\n" + filler
+	response, err := json.Marshal(map[string]interface{}{
+		"hits": map[string]interface{}{"hits": []map[string]interface{}{
+			{"_id": "1", "_source": map[string]interface{}{"title": "Handbook", "url": "https://wiki.internal/handbook", "body": document}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to build response: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(response)
+	}))
+	defer server.Close()
+
+	provider := &ElasticsearchSearchProvider{BaseURL: server.URL, TitleField: "title", URLField: "url", BodyField: "body", Client: server.Client()}
+	results, err := provider.Search("rotate signing key", SearchOptions{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || len(results[0].Content) < len(filler) {
+		t.Fatalf("Expected the whole body as content, got %+v", results)
+	}
+	if got := results[0].Summary; got != "To rotate the signing key, run keyctl rotate and restart the gateway." {
+		t.Errorf("Expected the matching paragraph as the summary, got %q", got)
+	}
+}
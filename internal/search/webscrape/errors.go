@@ -0,0 +1,71 @@
+package webscrape
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrQuotaExceeded is matched by QuotaError, so callers can use errors.Is.
+var ErrQuotaExceeded = errors.New("search quota exceeded")
+
+// QuotaError reports that a search API rejected a request because of rate
+// limiting or an exhausted subscription quota.
+type QuotaError struct {
+	Provider   string
+	StatusCode int
+	Code       string
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *QuotaError) Error() string {
+	msg := fmt.Sprintf("%s quota exceeded (status %d", e.Provider, e.StatusCode)
+	if e.Code != "" {
+		msg += ", " + e.Code
+	}
+	msg += ")"
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.RetryAfter > 0 {
+		msg += fmt.Sprintf(", retry after %s", e.RetryAfter)
+	}
+	return msg
+}
+
+// Is makes errors.Is(err, ErrQuotaExceeded) true for any QuotaError.
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// ErrBlocked is matched by BlockedError, so callers can use errors.Is.
+var ErrBlocked = errors.New("search provider blocked")
+
+// BlockedError reports that a provider refused to serve results, e.g. with
+// an anomaly or CAPTCHA page, or that it is cooling down after such a block.
+type BlockedError struct {
+	Provider   string
+	StatusCode int
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *BlockedError) Error() string {
+	msg := fmt.Sprintf("%s blocked", e.Provider)
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(" (status %d)", e.StatusCode)
+	}
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	if e.RetryAfter > 0 {
+		msg += fmt.Sprintf(", retry after %s", e.RetryAfter.Round(time.Second))
+	}
+	return msg
+}
+
+// Is makes errors.Is(err, ErrBlocked) true for any BlockedError.
+func (e *BlockedError) Is(target error) bool {
+	return target == ErrBlocked
+}
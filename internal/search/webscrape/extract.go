@@ -0,0 +1,317 @@
+package webscrape
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"open-sonar/internal/utils"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/go-shiori/go-readability"
+	"golang.org/x/net/html"
+)
+
+//go:embed extraction_rules.json
+var builtinExtractionRules []byte
+
+// ExtractionRule tells how to read the pages of some sites. Its selectors
+// are CSS selector groups applied with goquery. Drop selectors are removed
+// first. When Content matches, its Markdown (every match, in document order) is
+// the page's content; otherwise, and when Content is empty, readability
+// extracts the content from what is left. Title and Date override what
+// readability finds.
+type ExtractionRule struct {
+	Name    string   `json:"name"`
+	Hosts   []string `json:"hosts"` // host patterns: "docs.python.org", "*.stackexchange.com", "discuss.*", or "*" for every site
+	Content string   `json:"content"`
+	Title   string   `json:"title"`
+	Date    string   `json:"date"` // read from a datetime, data-time or content attribute, else the text
+	Drop    []string `json:"drop"`
+}
+
+// ExtractionRules is a rules file. For each page the first rule naming its
+// host is used, while the Drop selectors of "*" rules apply to every page.
+type ExtractionRules struct {
+	Rules []ExtractionRule `json:"rules"`
+}
+
+// how a rule date may be written
+var ruleDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123,
+	time.RFC1123Z,
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"2 Jan 2006",
+}
+
+// ParseExtractionRules parses a rules file and checks its selectors.
+func ParseExtractionRules(raw []byte) (*ExtractionRules, error) {
+	var rules ExtractionRules
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return nil, fmt.Errorf("parsing extraction rules: %w", err)
+	}
+	for i, rule := range rules.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if len(rule.Hosts) == 0 {
+			return nil, fmt.Errorf("extraction rule %s has no hosts", name)
+		}
+		for _, pattern := range rule.Hosts {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("extraction rule %s: invalid host pattern %q", name, pattern)
+			}
+		}
+		selectors := append([]string{rule.Content, rule.Title, rule.Date}, rule.Drop...)
+		for _, selector := range selectors {
+			if selector == "" {
+				continue
+			}
+			if _, err := cascadia.ParseGroup(selector); err != nil {
+				return nil, fmt.Errorf("extraction rule %s: invalid selector %q: %w", name, selector, err)
+			}
+		}
+	}
+	return &rules, nil
+}
+
+// LoadExtractionRules reads a rules file. Its rules take precedence over the
+// built-in ones, which follow them.
+func LoadExtractionRules(path string) (*ExtractionRules, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading extraction rules: %w", err)
+	}
+	rules, err := ParseExtractionRules(raw)
+	if err != nil {
+		return nil, err
+	}
+	rules.Rules = append(rules.Rules, BuiltinExtractionRules().Rules...)
+	return rules, nil
+}
+
+var (
+	builtinRules     *ExtractionRules
+	builtinRulesOnce sync.Once
+	loadedRules      = map[string]*ExtractionRules{}
+	loadedRulesMu    sync.Mutex
+)
+
+// BuiltinExtractionRules returns the rules shipped with the fetcher.
+func BuiltinExtractionRules() *ExtractionRules {
+	builtinRulesOnce.Do(func() {
+		rules, err := ParseExtractionRules(builtinExtractionRules)
+		if err != nil {
+			panic(err)
+		}
+		builtinRules = rules
+	})
+	return builtinRules
+}
+
+// DefaultExtractionRules returns the rules file named by EXTRACT_RULES_FILE
+// followed by the built-in rules, or only the built-in rules when it is
+// unset or can't be loaded.
+func DefaultExtractionRules() *ExtractionRules {
+	file := os.Getenv("EXTRACT_RULES_FILE")
+	if file == "" {
+		return BuiltinExtractionRules()
+	}
+
+	loadedRulesMu.Lock()
+	defer loadedRulesMu.Unlock()
+	if rules, ok := loadedRules[file]; ok {
+		return rules
+	}
+	rules, err := LoadExtractionRules(file)
+	if err != nil {
+		utils.Warn(fmt.Sprintf("Using the built-in extraction rules: %v", err))
+		rules = BuiltinExtractionRules()
+	}
+	loadedRules[file] = rules
+	return rules
+}
+
+// returns the first rule naming host, ignoring "*" rules, or nil
+func (r *ExtractionRules) ruleFor(host string) *ExtractionRule {
+	for i, rule := range r.Rules {
+		for _, pattern := range rule.Hosts {
+			if pattern == "*" {
+				continue
+			}
+			if ok, _ := path.Match(pattern, host); ok {
+				return &r.Rules[i]
+			}
+		}
+	}
+	return nil
+}
+
+// returns the selectors to drop from a page: the rule's and every "*" rule's
+func (r *ExtractionRules) dropFor(rule *ExtractionRule) []string {
+	var drop []string
+	if rule != nil {
+		drop = append(drop, rule.Drop...)
+	}
+	for _, candidate := range r.Rules {
+		for _, pattern := range candidate.Hosts {
+			if pattern == "*" {
+				drop = append(drop, candidate.Drop...)
+				break
+			}
+		}
+	}
+	return drop
+}
+
+// Extract sets a result's title, content and date from an HTML page
+// according to the rules for its host. The content is compact Markdown.
+func (r *ExtractionRules) Extract(result PageInfo, body []byte) (PageInfo, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return result, fmt.Errorf("parsing HTML: %w", err)
+	}
+	rule := r.ruleFor(pageHost(result.URL))
+	for _, selector := range r.dropFor(rule) {
+		doc.Find(selector).Remove()
+	}
+
+	ruleTitle := false
+	if rule != nil {
+		if title := selectionText(doc.Find(rule.Title).First()); title != "" {
+			result.Title = title
+			ruleTitle = true
+		}
+		if published := selectionDate(doc.Find(rule.Date).First()); !published.IsZero() {
+			result.Published = published
+		}
+		if rule.Content != "" {
+			if content := selectionMarkdown(doc.Find(rule.Content)); content != "" {
+				if title := selectionText(doc.Find("title").First()); title != "" && !ruleTitle {
+					result.Title = title
+				}
+				result.Markdown = true
+				return withText(result, content)
+			}
+			utils.Debug(fmt.Sprintf("Extraction rule %s matched no content on %s, using readability", rule.Name, result.URL))
+		}
+	}
+
+	// Readability removes every aside, but those the drop rules left are
+	// callouts and notes within the text
+	doc.Find("aside").Each(func(i int, s *goquery.Selection) {
+		s.Nodes[0].Data = "div"
+	})
+
+	cleaned, err := doc.Html()
+	if err != nil {
+		return result, fmt.Errorf("rendering HTML: %w", err)
+	}
+	baseURL, _ := url.Parse(result.URL)
+	// Classes are kept so that code blocks keep their language
+	parser := readability.NewParser()
+	parser.KeepClasses = true
+	article, err := parser.Parse(strings.NewReader(cleaned), baseURL)
+	if err != nil {
+		return result, fmt.Errorf("extracting content: %w", err)
+	}
+	if article.Title != "" && !ruleTitle {
+		result.Title = article.Title
+	}
+	content, err := htmlMarkdown(article.Content)
+	if err != nil || content == "" {
+		return withText(result, strings.Join(strings.Fields(article.TextContent), " "))
+	}
+	result.Markdown = true
+	return withText(result, content)
+}
+
+// block elements, whose text is kept apart from that of their neighbours
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "br": true, "caption": true,
+	"dd": true, "details": true, "div": true, "dl": true, "dt": true, "fieldset": true, "figcaption": true,
+	"figure": true, "footer": true, "form": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "header": true, "hr": true, "li": true, "main": true, "nav": true, "ol": true,
+	"p": true, "pre": true, "section": true, "summary": true, "table": true, "tbody": true, "td": true,
+	"tfoot": true, "th": true, "thead": true, "tr": true, "ul": true,
+}
+
+// returns the text of a selection, with blocks and separate matches on their
+// own lines so that words from adjacent elements aren't run together
+func selectionText(selection *goquery.Selection) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			if blockElements[n.Data] {
+				b.WriteByte('\n')
+				defer b.WriteByte('\n')
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	for _, node := range selection.Nodes {
+		walk(node)
+		b.WriteByte('\n')
+	}
+
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// reads a date from an element's datetime, data-time (epoch milliseconds) or
+// content attribute, or from its text
+func selectionDate(selection *goquery.Selection) time.Time {
+	if selection.Length() == 0 {
+		return time.Time{}
+	}
+	if millis, ok := selection.Attr("data-time"); ok {
+		var ms int64
+		if _, err := fmt.Sscan(millis, &ms); err == nil && ms > 0 {
+			return time.UnixMilli(ms).UTC()
+		}
+	}
+	values := []string{}
+	for _, attr := range []string{"datetime", "content", "title"} {
+		if value, ok := selection.Attr(attr); ok {
+			values = append(values, value)
+		}
+	}
+	values = append(values, selection.Text())
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		for _, layout := range ruleDateLayouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t.UTC()
+			}
+		}
+	}
+	return time.Time{}
+}
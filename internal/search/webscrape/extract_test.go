@@ -0,0 +1,179 @@
+package webscrape
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// extractionFixture is the sidecar of a page under testdata/extraction,
+// describing what extracting it should give.
+type extractionFixture struct {
+	URL       string   `json:"url"`
+	Title     string   `json:"title"`
+	Published string   `json:"published"`
+	Contains  []string `json:"contains"`
+	Excludes  []string `json:"excludes"`
+}
+
+func TestExtractionFixtures(t *testing.T) {
+	pages, err := filepath.Glob("testdata/extraction/*.html")
+	if err != nil || len(pages) == 0 {
+		t.Fatalf("No extraction fixtures found: %v", err)
+	}
+	for _, page := range pages {
+		name := strings.TrimSuffix(filepath.Base(page), ".html")
+		t.Run(name, func(t *testing.T) {
+			body, err := os.ReadFile(page)
+			if err != nil {
+				t.Fatalf("Failed to read fixture: %v", err)
+			}
+			raw, err := os.ReadFile(strings.TrimSuffix(page, ".html") + ".json")
+			if err != nil {
+				t.Fatalf("Failed to read fixture expectations: %v", err)
+			}
+			var want extractionFixture
+			if err := json.Unmarshal(raw, &want); err != nil {
+				t.Fatalf("Failed to parse fixture expectations: %v", err)
+			}
+
+			result, err := BuiltinExtractionRules().Extract(PageInfo{URL: want.URL}, body)
+			if err != nil {
+				t.Fatalf("Extract failed: %v", err)
+			}
+			if result.Title != want.Title {
+				t.Errorf("Expected title %q, got %q", want.Title, result.Title)
+			}
+			if want.Published != "" {
+				published, _ := time.Parse(time.RFC3339, want.Published)
+				if !result.Published.Equal(published) {
+					t.Errorf("Expected published %s, got %s", published, result.Published)
+				}
+			}
+			for _, text := range want.Contains {
+				if !strings.Contains(result.Content, text) {
+					t.Errorf("Expected content to contain %q, got:\n%s", text, result.Content)
+				}
+			}
+			for _, text := range want.Excludes {
+				if strings.Contains(result.Content, text) {
+					t.Errorf("Expected content not to contain %q, got:\n%s", text, result.Content)
+				}
+			}
+		})
+	}
+}
+
+func TestParseExtractionRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr string
+	}{
+		{"valid", `{"rules": [{"name": "wiki", "hosts": ["*.wiki.example"], "content": "#content", "drop": [".toc"]}]}`, ""},
+		{"no hosts", `{"rules": [{"name": "wiki", "content": "#content"}]}`, "has no hosts"},
+		{"bad host pattern", `{"rules": [{"name": "wiki", "hosts": ["[wiki"]}]}`, "invalid host pattern"},
+		{"bad selector", `{"rules": [{"hosts": ["wiki.example"], "content": "div[", "drop": []}]}`, `extraction rule #1: invalid selector "div["`},
+		{"bad drop selector", `{"rules": [{"name": "wiki", "hosts": ["wiki.example"], "drop": [">>"]}]}`, "invalid selector"},
+		{"not JSON", `rules: []`, "parsing extraction rules"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseExtractionRules([]byte(tt.raw))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected the rules to parse, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRuleFor(t *testing.T) {
+	rules := BuiltinExtractionRules()
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"stackoverflow.com", "stackexchange"},
+		{"unix.stackexchange.com", "stackexchange"},
+		{"docs.python.org", "sphinx"},
+		{"requests.readthedocs.io", "sphinx"},
+		{"discuss.python.org", "discourse"},
+		{"forum.example.com", "discourse"},
+		{"developer.mozilla.org", "mdn"},
+		{"example.com", ""},
+		{"notstackoverflow.com", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if rule := rules.ruleFor(tt.host); rule != nil {
+			got = rule.Name
+		}
+		if got != tt.want {
+			t.Errorf("ruleFor(%s): expected %q, got %q", tt.host, tt.want, got)
+		}
+	}
+}
+
+func TestExtractFallsBackToReadability(t *testing.T) {
+	// The sphinx rule's content selector matches nothing on this page
+	body := []byte(`<html><head><title>Moved docs</title></head><body>
+		<div class="sphinxsidebar">Table of Contents</div>
+		<article><h1>Moved docs</h1><p>` + strings.Repeat("This project's documentation now lives on its own site. ", 10) + `</p></article>
+	</body></html>`)
+
+	result, err := BuiltinExtractionRules().Extract(PageInfo{URL: "https://old.readthedocs.io/en/latest/"}, body)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if !strings.Contains(result.Content, "documentation now lives on its own site") {
+		t.Errorf("Expected readability to find the article, got %q", result.Content)
+	}
+	if strings.Contains(result.Content, "Table of Contents") {
+		t.Errorf("Expected the rule's drop selectors to still apply, got %q", result.Content)
+	}
+}
+
+func TestDefaultExtractionRulesFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.json")
+	custom := `{"rules": [
+		{"name": "python-docs", "hosts": ["docs.python.org"], "content": "dl.py.class dd", "title": "dt.sig .descname"}
+	]}`
+	if err := os.WriteFile(file, []byte(custom), 0o644); err != nil {
+		t.Fatalf("Failed to write rules: %v", err)
+	}
+	t.Setenv("EXTRACT_RULES_FILE", file)
+
+	rules := DefaultExtractionRules()
+	if rule := rules.ruleFor("docs.python.org"); rule == nil || rule.Name != "python-docs" {
+		t.Fatalf("Expected the custom rule to take precedence, got %+v", rule)
+	}
+	if rule := rules.ruleFor("stackoverflow.com"); rule == nil || rule.Name != "stackexchange" {
+		t.Errorf("Expected the built-in rules to follow the custom ones, got %+v", rule)
+	}
+
+	body, _ := os.ReadFile("testdata/extraction/python_docs.html")
+	result, err := rules.Extract(PageInfo{URL: "https://docs.python.org/3/library/collections.html"}, body)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if result.Title != "Counter" || !strings.HasPrefix(result.Content, "A Counter is a dict subclass") {
+		t.Errorf("Expected the custom rule's title and content, got %q: %q", result.Title, result.Content)
+	}
+	if strings.Contains(result.Content, "This module implements") {
+		t.Errorf("Expected only the custom rule's content, got %q", result.Content)
+	}
+
+	t.Setenv("EXTRACT_RULES_FILE", filepath.Join(t.TempDir(), "missing.json"))
+	if DefaultExtractionRules() != BuiltinExtractionRules() {
+		t.Errorf("Expected the built-in rules when the rules file can't be loaded")
+	}
+}
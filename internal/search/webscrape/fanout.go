@@ -0,0 +1,301 @@
+package webscrape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"open-sonar/internal/utils"
+)
+
+// DefaultProviderTimeout bounds each provider in a fan-out search unless its
+// spec sets a timeout.
+const DefaultProviderTimeout = 10 * time.Second
+
+// rrfK dampens the advantage of top ranks in reciprocal rank fusion.
+const rrfK = 60
+
+// ProviderSpec selects a search provider for a fan-out search.
+type ProviderSpec struct {
+	Name    string
+	Weight  float64       // multiplies the provider's fused scores; defaults to 1
+	Timeout time.Duration // per-provider deadline; defaults to DefaultProviderTimeout
+}
+
+// ProviderReport is the outcome of one provider in a fan-out search.
+type ProviderReport struct {
+	Name     string
+	Weight   float64
+	Results  int
+	Duration time.Duration
+	Err      error
+}
+
+// ParseProviderSpec parses "name[:weight[:timeout]]", e.g. "searxng:0.5:3s".
+func ParseProviderSpec(value string) (ProviderSpec, error) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	spec := ProviderSpec{Name: strings.ToLower(strings.TrimSpace(parts[0])), Weight: 1}
+	if spec.Name == "" {
+		return spec, fmt.Errorf("empty search provider name in %q", value)
+	}
+	if len(parts) > 3 {
+		return spec, fmt.Errorf("invalid search provider %q: expected name[:weight[:timeout]]", value)
+	}
+	if len(parts) > 1 && parts[1] != "" {
+		weight, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || weight <= 0 {
+			return spec, fmt.Errorf("invalid weight in search provider %q", value)
+		}
+		spec.Weight = weight
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		timeout, err := time.ParseDuration(parts[2])
+		if err != nil || timeout <= 0 {
+			return spec, fmt.Errorf("invalid timeout in search provider %q", value)
+		}
+		spec.Timeout = timeout
+	}
+	return spec, nil
+}
+
+// ParseProviderSpecs parses a list of provider specs.
+func ParseProviderSpecs(values []string) ([]ProviderSpec, error) {
+	specs := make([]ProviderSpec, 0, len(values))
+	for _, value := range values {
+		spec, err := ParseProviderSpec(value)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// ProvidersForModel returns the providers configured for a model alias through
+// SEARCH_PROVIDERS_<MODEL> (e.g. SEARCH_PROVIDERS_SONAR_PRO), falling back to
+// SEARCH_PROVIDERS. Both hold comma-separated specs. It returns nil when
+// neither is set, meaning the single default provider is used.
+func ProvidersForModel(model string) ([]ProviderSpec, error) {
+	value := os.Getenv(ModelProvidersEnvVar(model))
+	if value == "" {
+		value = os.Getenv("SEARCH_PROVIDERS")
+	}
+	if value == "" {
+		return nil, nil
+	}
+	return ParseProviderSpecs(splitList(value))
+}
+
+// ModelProvidersEnvVar returns the environment variable holding a model's
+// provider list: SEARCH_PROVIDERS_ followed by the upper-cased alias with
+// every other character replaced by an underscore.
+func ModelProvidersEnvVar(model string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, model)
+	return "SEARCH_PROVIDERS_" + name
+}
+
+type providerOutcome struct {
+	index   int
+	results []PageInfo
+	err     error
+	elapsed time.Duration
+}
+
+// searchProviders queries every provider concurrently and fuses their rankings.
+// Providers that fail or miss their deadline are reported but do not hold up
+// the others; the search only fails when no provider succeeds.
+func searchProviders(query string, specs []ProviderSpec, options SearchOptions) SearchReport {
+	names := make([]string, len(specs))
+	for i, spec := range specs {
+		names[i] = spec.Name
+	}
+	report := SearchReport{
+		Provider:        strings.Join(names, "+"),
+		Results:         []PageInfo{},
+		Providers:       make([]ProviderReport, len(specs)),
+		ResultProviders: map[string][]string{},
+	}
+
+	// Buffered so providers that finish after their deadline don't block.
+	// Each provider's requests are cancelled at its deadline, so one that
+	// is given up on doesn't keep running in the background.
+	outcomes := make(chan providerOutcome, len(specs))
+	for i, spec := range specs {
+		report.Providers[i] = ProviderReport{Name: spec.Name, Weight: spec.Weight}
+		go func(i int, spec ProviderSpec) {
+			ctx, cancel := context.WithTimeout(options.requestContext(), specTimeout(spec))
+			defer cancel()
+			providerOptions := options
+			providerOptions.Context = ctx
+
+			start := time.Now()
+			results, err := runProvider(query, spec.Name, providerOptions)
+			outcomes <- providerOutcome{index: i, results: results, err: err, elapsed: time.Since(start)}
+		}(i, spec)
+	}
+
+	rankings := make([][]PageInfo, len(specs))
+	pending := make(map[int]bool, len(specs))
+	for i := range specs {
+		pending[i] = true
+	}
+
+	start := time.Now()
+	for len(pending) > 0 {
+		// Wait until the next outcome or the earliest pending deadline
+		next := time.Duration(-1)
+		for i := range pending {
+			remaining := specTimeout(specs[i]) - time.Since(start)
+			if next < 0 || remaining < next {
+				next = remaining
+			}
+		}
+
+		timer := time.NewTimer(next)
+		select {
+		case outcome := <-outcomes:
+			timer.Stop()
+			if !pending[outcome.index] {
+				continue
+			}
+			delete(pending, outcome.index)
+			pr := &report.Providers[outcome.index]
+			pr.Duration = outcome.elapsed
+			pr.Err = outcome.err
+			pr.Results = len(outcome.results)
+			rankings[outcome.index] = outcome.results
+		case <-timer.C:
+			for i := range pending {
+				if time.Since(start) >= specTimeout(specs[i]) {
+					delete(pending, i)
+					pr := &report.Providers[i]
+					pr.Duration = time.Since(start)
+					pr.Err = fmt.Errorf("timed out after %s", specTimeout(specs[i]))
+				}
+			}
+		}
+	}
+
+	var failures []error
+	for _, pr := range report.Providers {
+		if pr.Err == nil {
+			continue
+		}
+		failures = append(failures, fmt.Errorf("%s: %w", pr.Name, pr.Err))
+		if errors.Is(pr.Err, ErrBlocked) {
+			report.Blocked = append(report.Blocked, pr)
+		}
+		if errors.Is(pr.Err, ErrQuotaExceeded) {
+			utils.Warn(fmt.Sprintf("Search provider %s quota exceeded: %v", pr.Name, pr.Err))
+		} else {
+			utils.Warn(fmt.Sprintf("Search provider %s failed: %v", pr.Name, pr.Err))
+		}
+	}
+	if len(failures) == len(specs) {
+		report.Err = errors.Join(failures...)
+		return report
+	}
+
+	report.Results = fuseRankings(rankings, specs, report.ResultProviders)
+	return report
+}
+
+func specTimeout(spec ProviderSpec) time.Duration {
+	if spec.Timeout > 0 {
+		return spec.Timeout
+	}
+	return DefaultProviderTimeout
+}
+
+// fuseRankings merges per-provider rankings by weighted reciprocal rank
+// fusion: each result scores weight/(k+rank) for every provider returning it.
+// sources is filled with the providers that returned each fused URL.
+func fuseRankings(rankings [][]PageInfo, specs []ProviderSpec, sources map[string][]string) []PageInfo {
+	type fused struct {
+		result PageInfo
+		score  float64
+		order  int
+	}
+	byKey := map[string]*fused{}
+	var merged []*fused
+
+	for i, ranking := range rankings {
+		weight := specs[i].Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		seen := map[string]bool{}
+		for rank, result := range ranking {
+			key := fusionKey(result.URL)
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			entry, ok := byKey[key]
+			if !ok {
+				entry = &fused{result: result, order: len(merged)}
+				byKey[key] = entry
+				merged = append(merged, entry)
+			} else {
+				fillMissing(&entry.result, result)
+			}
+			entry.score += weight / float64(rrfK+rank+1)
+			sources[entry.result.URL] = append(sources[entry.result.URL], specs[i].Name)
+		}
+	}
+
+	// Instant answers lead; ties keep the order in which results were first seen
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].result.InstantAnswer != merged[j].result.InstantAnswer {
+			return merged[i].result.InstantAnswer
+		}
+		if merged[i].score != merged[j].score {
+			return merged[i].score > merged[j].score
+		}
+		return merged[i].order < merged[j].order
+	})
+
+	results := make([]PageInfo, len(merged))
+	for i, entry := range merged {
+		results[i] = entry.result
+	}
+	return results
+}
+
+// normalizes a URL for duplicate detection across providers
+func fusionKey(rawURL string) string {
+	key := strings.TrimSpace(rawURL)
+	key = strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+	key = strings.TrimPrefix(key, "www.")
+	return strings.TrimRight(key, "/")
+}
+
+// copies fields the first provider left empty from another provider's copy
+func fillMissing(dst *PageInfo, src PageInfo) {
+	if dst.Title == "" {
+		dst.Title = src.Title
+	}
+	if len(src.Content) > len(dst.Content) {
+		dst.Content = src.Content
+		dst.Markdown = src.Markdown
+	}
+	if dst.Summary == "" {
+		dst.Summary = src.Summary
+	}
+	if dst.Published.IsZero() {
+		dst.Published = src.Published
+	}
+	dst.InstantAnswer = dst.InstantAnswer || src.InstantAnswer
+}
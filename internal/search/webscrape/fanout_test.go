@@ -0,0 +1,199 @@
+package webscrape
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubProvider returns fixed results after an optional delay
+type stubProvider struct {
+	results []PageInfo
+	err     error
+	delay   time.Duration
+	calls   atomic.Int32
+}
+
+func (p *stubProvider) Search(query string, options SearchOptions) ([]PageInfo, error) {
+	p.calls.Add(1)
+	time.Sleep(p.delay)
+	return p.results, p.err
+}
+
+func pages(urls ...string) []PageInfo {
+	results := make([]PageInfo, len(urls))
+	for i, u := range urls {
+		results[i] = PageInfo{URL: u, Title: u}
+	}
+	return results
+}
+
+func TestParseProviderSpec(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ProviderSpec
+		wantErr  bool
+	}{
+		{input: "duckduckgo", expected: ProviderSpec{Name: "duckduckgo", Weight: 1}},
+		{input: " SearXNG:0.5 ", expected: ProviderSpec{Name: "searxng", Weight: 0.5}},
+		{input: "opensearch:2:1500ms", expected: ProviderSpec{Name: "opensearch", Weight: 2, Timeout: 1500 * time.Millisecond}},
+		{input: "brave::3s", expected: ProviderSpec{Name: "brave", Weight: 1, Timeout: 3 * time.Second}},
+		{input: "", wantErr: true},
+		{input: "brave:-1", wantErr: true},
+		{input: "brave:1:soon", wantErr: true},
+		{input: "brave:1:2s:extra", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			spec, err := ParseProviderSpec(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && spec != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, spec)
+			}
+		})
+	}
+}
+
+func TestProvidersForModel(t *testing.T) {
+	t.Setenv("SEARCH_PROVIDERS", "duckduckgo,searxng:0.5")
+	t.Setenv("SEARCH_PROVIDERS_SONAR_INTERNAL", "opensearch:2")
+
+	specs, err := ProvidersForModel("sonar-internal")
+	if err != nil || len(specs) != 1 || specs[0].Name != "opensearch" {
+		t.Errorf("Expected model-specific providers, got %+v (%v)", specs, err)
+	}
+
+	specs, err = ProvidersForModel("sonar")
+	if err != nil || len(specs) != 2 || specs[1].Weight != 0.5 {
+		t.Errorf("Expected default provider list, got %+v (%v)", specs, err)
+	}
+}
+
+func TestFanOutSearch(t *testing.T) {
+	providers := map[string]SearchProvider{
+		"a":      &stubProvider{results: pages("https://one.example/", "https://two.example", "https://three.example")},
+		"b":      &stubProvider{results: pages("https://www.two.example", "https://four.example")},
+		"slow":   &stubProvider{results: pages("https://slow.example"), delay: time.Second},
+		"broken": &stubProvider{err: errors.New("connection refused")},
+	}
+	old := SetGetSearchProvider(func(name string) (SearchProvider, error) {
+		if p, ok := providers[name]; ok {
+			return p, nil
+		}
+		return nil, fmt.Errorf("unknown provider %s", name)
+	})
+	defer SetGetSearchProvider(old)
+
+	start := time.Now()
+	report := SearchWithReport("query", SearchOptions{Providers: []ProviderSpec{
+		{Name: "a", Weight: 1},
+		{Name: "b", Weight: 2},
+		{Name: "slow", Weight: 1, Timeout: 50 * time.Millisecond},
+		{Name: "broken", Weight: 1},
+	}})
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Slow provider blocked the search for %s", elapsed)
+	}
+
+	if report.Err != nil {
+		t.Fatalf("Expected partial success, got %v", report.Err)
+	}
+	if report.Provider != "a+b+slow+broken" {
+		t.Errorf("Unexpected provider label %q", report.Provider)
+	}
+
+	// two.example is returned by both providers; b's weight of 2 then puts its
+	// second result ahead of a's first
+	order := make([]string, len(report.Results))
+	for i, r := range report.Results {
+		order[i] = r.URL
+	}
+	want := []string{"https://two.example", "https://four.example", "https://one.example/", "https://three.example"}
+	if fmt.Sprint(order) != fmt.Sprint(want) {
+		t.Errorf("Expected fused order %v, got %v", want, order)
+	}
+	if sources := report.ResultProviders["https://two.example"]; fmt.Sprint(sources) != "[a b]" && fmt.Sprint(sources) != "[b a]" {
+		t.Errorf("Expected two.example attributed to both providers, got %v", sources)
+	}
+
+	outcomes := map[string]ProviderReport{}
+	for _, pr := range report.Providers {
+		outcomes[pr.Name] = pr
+	}
+	if outcomes["slow"].Err == nil || outcomes["broken"].Err == nil {
+		t.Errorf("Expected slow and broken providers to report errors: %+v", report.Providers)
+	}
+	if outcomes["a"].Err != nil || outcomes["a"].Results != 3 {
+		t.Errorf("Unexpected outcome for provider a: %+v", outcomes["a"])
+	}
+}
+
+func TestFanOutSearchCancelsSlowProvider(t *testing.T) {
+	// The slow server holds each request until the client gives up on it
+	cancelled := make(chan struct{}, 1)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			cancelled <- struct{}{}
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow.Close()
+
+	useStubProviders(t, map[string]SearchProvider{
+		"fast": &stubProvider{results: pages("https://one.example")},
+		"slow": &SearXNGSearchProvider{BaseURL: slow.URL, Client: slow.Client()},
+	})
+	report := SearchWithReport("query", SearchOptions{Providers: []ProviderSpec{
+		{Name: "fast", Weight: 1},
+		{Name: "slow", Weight: 1, Timeout: 50 * time.Millisecond},
+	}})
+	if report.Err != nil || len(report.Results) != 1 {
+		t.Fatalf("Expected the fast provider's result, got %+v", report)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Errorf("Expected the slow provider's request cancelled at its deadline")
+	}
+}
+
+func TestFanOutSearchAllFail(t *testing.T) {
+	old := SetGetSearchProvider(func(name string) (SearchProvider, error) {
+		return &stubProvider{err: &QuotaError{Provider: name, StatusCode: 429}}, nil
+	})
+	defer SetGetSearchProvider(old)
+
+	report := SearchWithReport("query", SearchOptions{Providers: []ProviderSpec{{Name: "x", Weight: 1}, {Name: "y", Weight: 1}}})
+	if report.Err == nil {
+		t.Fatal("Expected an error when every provider fails")
+	}
+	if !errors.Is(report.Err, ErrQuotaExceeded) {
+		t.Errorf("Expected joined error to match ErrQuotaExceeded, got %v", report.Err)
+	}
+	if len(report.Results) != 0 {
+		t.Errorf("Expected no results, got %d", len(report.Results))
+	}
+}
+
+func TestFuseRankingsKeepsInstantAnswerFirst(t *testing.T) {
+	answer := PageInfo{URL: "https://en.wikipedia.org/wiki/Go", Title: "Go", InstantAnswer: true}
+	rankings := [][]PageInfo{
+		pages("https://one.example", "https://two.example"),
+		append(pages("https://one.example"), answer),
+	}
+	specs := []ProviderSpec{{Name: "a", Weight: 1}, {Name: "ddg", Weight: 1}}
+
+	results := fuseRankings(rankings, specs, map[string][]string{})
+	if len(results) != 3 || results[0].URL != answer.URL || !results[0].InstantAnswer {
+		t.Errorf("Expected the instant answer ranked first, got %+v", results)
+	}
+}
@@ -0,0 +1,316 @@
+package webscrape
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"open-sonar/internal/proxy"
+	"open-sonar/internal/utils"
+
+	"golang.org/x/net/html/charset"
+)
+
+// FeedSearchProvider searches a fixed list of RSS and Atom feeds, e.g. the
+// wire services a newsroom follows. Entries are matched against the query
+// terms; when none match, every entry is returned so that broad questions
+// like "what happened today" still get a briefing.
+type FeedSearchProvider struct {
+	Feeds  []string
+	Client *http.Client
+}
+
+// feedDocument covers RSS 2.0 (<rss><channel><item>), RSS 1.0 (<rdf:RDF><item>)
+// and Atom (<feed><entry>).
+type feedDocument struct {
+	Title        string      `xml:"title"`         // Atom
+	ChannelTitle string      `xml:"channel>title"` // RSS
+	ChannelItems []rssItem   `xml:"channel>item"`
+	Items        []rssItem   `xml:"item"`
+	Entries      []atomEntry `xml:"entry"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	Description string `xml:"description"`
+	Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PubDate     string `xml:"pubDate"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+}
+
+type atomEntry struct {
+	Title string `xml:"title"`
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+	Summary   string `xml:"summary"`
+	Content   string `xml:"content"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+}
+
+// layouts feeds use for dates; RSS mandates RFC 822 but many feeds vary it
+var feedDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+}
+
+func init() {
+	RegisterSearchProvider("feeds", func(config ProviderConfig) (SearchProvider, error) {
+		provider, err := NewFeedSearchProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	})
+}
+
+// NewFeedSearchProvider creates a provider for the comma-separated NEWS_FEEDS.
+func NewFeedSearchProvider(config ProviderConfig) (*FeedSearchProvider, error) {
+	feeds := splitList(config.Get("NEWS_FEEDS"))
+	if len(feeds) == 0 {
+		return nil, fmt.Errorf("NEWS_FEEDS not set")
+	}
+	return &FeedSearchProvider{
+		Feeds:  feeds,
+		Client: proxy.NewClient(proxy.Search, 15*time.Second),
+	}, nil
+}
+
+// Search fetches every feed concurrently and returns the entries matching the
+// query, best match first and newest first within a match score. Entries
+// without a parsable date are skipped.
+func (p *FeedSearchProvider) Search(query string, options SearchOptions) ([]PageInfo, error) {
+	timer := utils.NewTimer("Feed search")
+	defer timer.Stop()
+
+	type feedResult struct {
+		entries []PageInfo
+		err     error
+	}
+	fetched := make([]feedResult, len(p.Feeds))
+	var wg sync.WaitGroup
+	for i, feedURL := range p.Feeds {
+		wg.Add(1)
+		go func(i int, feedURL string) {
+			defer wg.Done()
+			entries, err := p.fetchFeed(options.requestContext(), feedURL)
+			fetched[i] = feedResult{entries: entries, err: err}
+		}(i, feedURL)
+	}
+	wg.Wait()
+
+	var entries []PageInfo
+	var failures []error
+	for i, feed := range fetched {
+		if feed.err != nil {
+			utils.Warn(fmt.Sprintf("Feed %s failed: %v", p.Feeds[i], feed.err))
+			failures = append(failures, fmt.Errorf("%s: %w", p.Feeds[i], feed.err))
+			continue
+		}
+		entries = append(entries, feed.entries...)
+	}
+	if len(failures) == len(p.Feeds) {
+		return nil, errors.Join(failures...)
+	}
+
+	return rankFeedEntries(entries, query), nil
+}
+
+// orders entries by query term matches, then by date, dropping duplicates;
+// when nothing matches all entries are kept, newest first
+func rankFeedEntries(entries []PageInfo, query string) []PageInfo {
+	terms := uniqueTerms(tokenize(query))
+	type scored struct {
+		entry PageInfo
+		score int
+	}
+
+	var matched, all []scored
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		key := fusionKey(entry.URL)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		text := map[string]bool{}
+		for _, token := range tokenize(entry.Title + " " + entry.Content) {
+			text[token] = true
+		}
+		score := 0
+		for _, term := range terms {
+			if text[term] {
+				score++
+			}
+		}
+		all = append(all, scored{entry: entry})
+		if score > 0 {
+			matched = append(matched, scored{entry: entry, score: score})
+		}
+	}
+	if len(matched) == 0 {
+		matched = all
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		if matched[i].score != matched[j].score {
+			return matched[i].score > matched[j].score
+		}
+		return matched[i].entry.Published.After(matched[j].entry.Published)
+	})
+
+	results := make([]PageInfo, len(matched))
+	for i, m := range matched {
+		results[i] = m.entry
+	}
+	return results
+}
+
+// fetches and parses one feed
+func (p *FeedSearchProvider) fetchFeed(ctx context.Context, feedURL string) ([]PageInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, */*;q=0.8")
+	req.Header.Set("User-Agent", FetchUserAgent())
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var doc feedDocument
+	decoder := xml.NewDecoder(resp.Body)
+	decoder.CharsetReader = charset.NewReaderLabel
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("error parsing feed: %w", err)
+	}
+	return doc.pageInfos(), nil
+}
+
+// converts feed items and entries into results
+func (d feedDocument) pageInfos() []PageInfo {
+	var results []PageInfo
+	for _, item := range append(d.ChannelItems, d.Items...) {
+		link := strings.TrimSpace(item.Link)
+		if link == "" && strings.HasPrefix(item.GUID, "http") {
+			link = strings.TrimSpace(item.GUID)
+		}
+		published := parseFeedDate(item.PubDate)
+		if published.IsZero() {
+			published = parseFeedDate(item.Date)
+		}
+		results = appendFeedEntry(results, link, item.Title, item.Description, item.Content, published)
+	}
+	for _, entry := range d.Entries {
+		var link string
+		for _, l := range entry.Links {
+			if l.Rel == "" || l.Rel == "alternate" {
+				link = strings.TrimSpace(l.Href)
+				break
+			}
+		}
+		published := parseFeedDate(entry.Published)
+		if published.IsZero() {
+			published = parseFeedDate(entry.Updated)
+		}
+		results = appendFeedEntry(results, link, entry.Title, entry.Summary, entry.Content, published)
+	}
+	return results
+}
+
+// returns a "title: summary" line for every item and entry, including
+// undated ones, for reading a feed as a page
+func (d feedDocument) textLines() []string {
+	var lines []string
+	add := func(title, summary, content string) {
+		text := feedText(summary)
+		if text == "" {
+			text = feedText(content)
+		}
+		line := feedText(title)
+		if line != "" && text != "" {
+			line += ": "
+		}
+		if line += text; line != "" {
+			lines = append(lines, line)
+		}
+	}
+	for _, item := range append(d.ChannelItems, d.Items...) {
+		add(item.Title, item.Description, item.Content)
+	}
+	for _, entry := range d.Entries {
+		add(entry.Title, entry.Summary, entry.Content)
+	}
+	return lines
+}
+
+// appends an entry unless it lacks a link or date
+func appendFeedEntry(results []PageInfo, link, title, summary, content string, published time.Time) []PageInfo {
+	if link == "" || published.IsZero() {
+		return results
+	}
+	summary = feedText(summary)
+	content = feedText(content)
+	if content == "" {
+		content = summary
+	}
+	if summary == "" {
+		summary = content
+	}
+	return append(results, PageInfo{
+		URL:       link,
+		Title:     feedText(title),
+		Content:   content,
+		Summary:   summary,
+		Published: published,
+	})
+}
+
+// strips markup and entities from feed text
+func feedText(text string) string {
+	text = html.UnescapeString(htmlTagPattern.ReplaceAllString(text, " "))
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// parses a feed date, returning the zero time when no layout matches
+func parseFeedDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+	for _, layout := range feedDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
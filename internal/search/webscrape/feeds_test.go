@@ -0,0 +1,185 @@
+package webscrape
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func newFeedServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFeedSearchProvider(t *testing.T) {
+	server := newFeedServer(t)
+	provider := &FeedSearchProvider{
+		Feeds:  []string{server.URL + "/feed_rss.xml", server.URL + "/feed_atom.xml", server.URL + "/missing.xml"},
+		Client: server.Client(),
+	}
+
+	results, err := provider.Search("interest rate decision", SearchOptions{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	// Both rate stories match; the one matching more terms comes first
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 matching entries, got %d: %+v", len(results), results)
+	}
+	if results[0].URL != "https://wire.example.com/markets/rally" {
+		t.Errorf("Expected the GUID link for an item without <link>, got %s", results[0].URL)
+	}
+	if !results[0].Published.Equal(time.Date(2025, 10, 14, 10, 45, 0, 0, time.UTC)) {
+		t.Errorf("Expected dc:date to be parsed, got %v", results[0].Published)
+	}
+	if results[0].Content != "Stocks rose after the interest rate decision." {
+		t.Errorf("Expected content:encoded stripped of markup, got %q", results[0].Content)
+	}
+	if results[1].Summary != "The central bank kept its benchmark interest rate unchanged on Tuesday & signalled caution." {
+		t.Errorf("Unexpected description %q", results[1].Summary)
+	}
+}
+
+func TestFeedSearchProviderBriefing(t *testing.T) {
+	server := newFeedServer(t)
+	provider := &FeedSearchProvider{
+		Feeds:  []string{server.URL + "/feed_rss.xml", server.URL + "/feed_atom.xml"},
+		Client: server.Client(),
+	}
+
+	// Nothing matches, so every dated entry is returned newest first
+	results, err := provider.Search("what happened", SearchOptions{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	expected := []string{
+		"https://wire.example.com/markets/rally",
+		"https://wire.example.com/business/central-bank-holds-rates",
+		"https://citydesk.example.org/2025/10/14/bike-lanes",
+		"https://wire.example.com/weather/storm-flooding",
+		"https://citydesk.example.org/2025/10/12/library-hours",
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d entries without the undated one, got %d: %+v", len(expected), len(results), results)
+	}
+	for i, u := range expected {
+		if results[i].URL != u {
+			t.Errorf("Result %d: expected %s, got %s", i, u, results[i].URL)
+		}
+	}
+	if !results[2].Published.Equal(time.Date(2025, 10, 14, 4, 15, 0, 0, time.UTC)) {
+		t.Errorf("Expected Atom published time in UTC, got %v", results[2].Published)
+	}
+	if !results[4].Published.Equal(time.Date(2025, 10, 12, 15, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected Atom updated time as fallback, got %v", results[4].Published)
+	}
+}
+
+func TestFeedSearchProviderCharset(t *testing.T) {
+	feed, err := charmap.Windows1251.NewEncoder().String(`<?xml version="1.0" encoding="windows-1251"?>
+<rss version="2.0"><channel><title>Новости</title>
+<item><title>Выборы в Москве</title><link>https://news.example.ru/1</link><description>Итоги голосования.</description><pubDate>Tue, 14 Oct 2025 09:00:00 +0300</pubDate></item>
+</channel></rss>`)
+	if err != nil {
+		t.Fatalf("Failed to encode fixture: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(feed))
+	}))
+	defer server.Close()
+
+	provider := &FeedSearchProvider{Feeds: []string{server.URL}, Client: server.Client()}
+	results, err := provider.Search("выборы", SearchOptions{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Title != "Выборы в Москве" {
+		t.Errorf("Expected the windows-1251 feed decoded, got %+v", results)
+	}
+}
+
+func TestFeedSearchProviderAllFail(t *testing.T) {
+	server := newFeedServer(t)
+	provider := &FeedSearchProvider{Feeds: []string{server.URL + "/missing.xml"}, Client: server.Client()}
+	if _, err := provider.Search("news", SearchOptions{}); err == nil {
+		t.Error("Expected an error when every feed fails")
+	}
+}
+
+func TestParseFeedDate(t *testing.T) {
+	expected := time.Date(2025, 10, 14, 8, 30, 0, 0, time.UTC)
+	for _, value := range []string{
+		"Tue, 14 Oct 2025 08:30:00 +0000",
+		"Tue, 14 Oct 2025 08:30:00 GMT",
+		"Tue, 14 Oct 2025 10:30:00 +0200",
+		"14 Oct 2025 08:30:00 +0000",
+		"2025-10-14T08:30:00Z",
+		"2025-10-14T10:30:00+02:00",
+	} {
+		if got := parseFeedDate(value); !got.Equal(expected) {
+			t.Errorf("parseFeedDate(%q) = %v, expected %v", value, got, expected)
+		}
+	}
+	if got := parseFeedDate("yesterday"); !got.IsZero() {
+		t.Errorf("Expected zero time for an unparsable date, got %v", got)
+	}
+}
+
+func TestNewsSearchMode(t *testing.T) {
+	now := time.Now()
+	news := &stubProvider{results: []PageInfo{
+		{URL: "https://fresh.example/story", Title: "Fresh", Published: now.Add(-2 * time.Hour)},
+		{URL: "https://stale.example/story", Title: "Stale", Published: now.AddDate(0, 0, -3)},
+	}}
+	useStubProviders(t, map[string]SearchProvider{"wire": news})
+	t.Setenv("NEWS_PROVIDERS", "wire")
+	t.Setenv("NEWS_RECENCY_FILTER", "")
+
+	report := SearchWithReport("what happened today", SearchOptions{SearchMode: SearchModeNews})
+	if report.Err != nil {
+		t.Fatalf("News search failed: %v", report.Err)
+	}
+	if report.Provider != "wire" {
+		t.Errorf("Expected the news provider, got %q", report.Provider)
+	}
+	if len(report.Results) != 1 || report.Results[0].Title != "Fresh" {
+		t.Errorf("Expected only the story from the last day, got %+v", report.Results)
+	}
+	if len(report.Dropped) != 1 || report.Dropped[0].Reason != "older than recency filter" {
+		t.Errorf("Expected the stale story to be dropped by recency, got %+v", report.Dropped)
+	}
+
+	// An explicit recency filter replaces the default
+	report = SearchWithReport("what happened this week", SearchOptions{SearchMode: SearchModeNews, SearchRecencyFilter: "week"})
+	if len(report.Results) != 2 {
+		t.Errorf("Expected both stories within a week, got %+v", report.Results)
+	}
+}
+
+func TestNewsProviders(t *testing.T) {
+	t.Setenv("NEWS_PROVIDERS", "")
+	t.Setenv("NEWS_FEEDS", "")
+	specs, err := NewsProviders()
+	if err != nil || len(specs) != 1 || specs[0].Name != "duckduckgo" {
+		t.Errorf("Expected DuckDuckGo news only, got %+v (%v)", specs, err)
+	}
+
+	t.Setenv("NEWS_FEEDS", "https://wire.example.com/rss")
+	specs, err = NewsProviders()
+	if err != nil || len(specs) != 2 || specs[1].Name != "feeds" {
+		t.Errorf("Expected feeds to be added, got %+v (%v)", specs, err)
+	}
+
+	t.Setenv("NEWS_PROVIDERS", "searxng:2")
+	specs, err = NewsProviders()
+	if err != nil || len(specs) != 1 || specs[0].Name != "searxng" || specs[0].Weight != 2 {
+		t.Errorf("Expected NEWS_PROVIDERS to win, got %+v (%v)", specs, err)
+	}
+}
@@ -0,0 +1,415 @@
+package webscrape
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"open-sonar/internal/utils"
+)
+
+// Defaults for the page fetch stage.
+const (
+	DefaultFetchWorkers     = 8
+	DefaultFetchPerHost     = 2
+	DefaultFetchPageTimeout = 5 * time.Second
+	DefaultFetchDeadline    = 10 * time.Second
+	DefaultFetchHostDelay   = 250 * time.Millisecond
+	DefaultFetchMaxBytes    = 10 * 1024 * 1024
+)
+
+// maxPageBytes caps how much of a text page is read for extraction; only
+// PDFs, which can't be read in part, use the whole of FetchOptions.MaxBytes.
+const maxPageBytes = 1024 * 1024
+
+// snippetProviders return only search snippets, so the fetch stage replaces
+// their content with the text of the page itself.
+var snippetProviders = map[string]bool{
+	"duckduckgo": true,
+	"brave":      true,
+	"searxng":    true,
+}
+
+// FetchOptions bounds the page fetch stage. Zero values use the defaults.
+type FetchOptions struct {
+	Disabled    bool
+	MaxPages    int           // most snippet-only results fetched, best ranked first; 0 fetches all
+	Workers     int           // pages fetched at once overall
+	PerHost     int           // pages fetched at once from one host
+	PageTimeout time.Duration // limit for a single page
+	Deadline    time.Duration // limit for the whole stage
+	HostDelay   time.Duration // minimum spacing between requests to one host; negative disables it
+	UserAgent   string
+	Cache       *PageCache // nil uses DefaultPageCache, which may be nil
+
+	// Limits on what a fetch may reach and read. Client, when set, replaces
+	// the client built from AllowPrivate and MaxRedirects.
+	MaxBytes     int64 // largest response read
+	MaxRedirects int   // redirects followed; negative follows none
+	AllowPrivate bool  // allow loopback, private and link-local addresses
+	Client       *http.Client
+}
+
+// fills unset limits from FETCH_WORKERS, FETCH_PER_HOST, FETCH_PAGE_TIMEOUT,
+// FETCH_DEADLINE, FETCH_HOST_DELAY, FETCH_USER_AGENT, FETCH_MAX_BYTES,
+// FETCH_MAX_REDIRECTS and FETCH_ALLOW_PRIVATE, then from the defaults
+func (o FetchOptions) withDefaults() FetchOptions {
+	if o.Workers <= 0 {
+		o.Workers = envInt("FETCH_WORKERS", DefaultFetchWorkers)
+	}
+	if o.PerHost <= 0 {
+		o.PerHost = envInt("FETCH_PER_HOST", DefaultFetchPerHost)
+	}
+	if o.PageTimeout <= 0 {
+		o.PageTimeout = envDuration("FETCH_PAGE_TIMEOUT", DefaultFetchPageTimeout)
+	}
+	if o.Deadline <= 0 {
+		o.Deadline = envDuration("FETCH_DEADLINE", DefaultFetchDeadline)
+	}
+	if o.HostDelay == 0 {
+		o.HostDelay = DefaultFetchHostDelay
+		if value := os.Getenv("FETCH_HOST_DELAY"); value != "" {
+			if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+				o.HostDelay = d
+			} else {
+				utils.Warn(fmt.Sprintf("Invalid FETCH_HOST_DELAY %q, using %s", value, DefaultFetchHostDelay))
+			}
+		}
+	}
+	if o.UserAgent == "" {
+		o.UserAgent = FetchUserAgent()
+	}
+	if o.MaxBytes <= 0 {
+		o.MaxBytes = int64(envInt("FETCH_MAX_BYTES", DefaultFetchMaxBytes))
+	}
+	if o.MaxRedirects == 0 {
+		o.MaxRedirects = DefaultFetchMaxRedirects
+		if value := os.Getenv("FETCH_MAX_REDIRECTS"); value != "" {
+			if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+				o.MaxRedirects = n
+			} else {
+				utils.Warn(fmt.Sprintf("Invalid FETCH_MAX_REDIRECTS %q, using %d", value, DefaultFetchMaxRedirects))
+			}
+		}
+		if o.MaxRedirects == 0 {
+			o.MaxRedirects = -1
+		}
+	}
+	if !o.AllowPrivate {
+		o.AllowPrivate = utils.GetEnvWithDefault("FETCH_ALLOW_PRIVATE", "false") == "true"
+	}
+	if o.Client == nil {
+		o.Client = NewFetchClient(o.AllowPrivate, o.MaxRedirects)
+	}
+	if o.Cache == nil {
+		o.Cache = DefaultPageCache()
+	}
+	return o
+}
+
+// returns a positive integer setting, or def when unset or invalid
+func envInt(key string, def int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			return n
+		}
+		utils.Warn(fmt.Sprintf("Invalid %s %q, using %d", key, value, def))
+	}
+	return def
+}
+
+// returns a positive duration setting, or def when unset or invalid
+func envDuration(key string, def time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+		utils.Warn(fmt.Sprintf("Invalid %s %q, using %s", key, value, def))
+	}
+	return def
+}
+
+// FetchReport summarizes a fetch stage.
+type FetchReport struct {
+	Fetched    int // pages whose content was extracted
+	Cached     int // of those, pages served from the page cache, fresh or revalidated
+	Failed     int // pages that errored or timed out, keeping their snippet
+	Disallowed int // pages robots.txt doesn't let us fetch, keeping their snippet
+	Duration   time.Duration
+}
+
+// FetchPages fetches the pages of results and replaces their snippets with
+// the readable text of each page. Pages are fetched by a bounded worker pool
+// that limits and spaces out requests per host and honors robots.txt; a page
+// that fails or misses the deadline keeps its snippet, and one robots.txt
+// disallows keeps its snippet and is marked RobotsDisallowed. The results
+// keep their order, and the call returns only once every fetch has finished.
+func FetchPages(ctx context.Context, results []PageInfo, options FetchOptions) ([]PageInfo, FetchReport) {
+	indexes := make([]int, 0, len(results))
+	for i, result := range results {
+		if needsFetch(result) {
+			indexes = append(indexes, i)
+		}
+	}
+	return fetchIndexes(ctx, results, indexes, options)
+}
+
+// fetches the pages at the given indexes
+func fetchIndexes(ctx context.Context, results []PageInfo, indexes []int, options FetchOptions) ([]PageInfo, FetchReport) {
+	out := append([]PageInfo(nil), results...)
+	if len(indexes) == 0 {
+		return out, FetchReport{}
+	}
+
+	options = options.withDefaults()
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, options.Deadline)
+	defer cancel()
+
+	// One semaphore per host, created up front so workers share them
+	hosts := map[string]chan struct{}{}
+	for _, i := range indexes {
+		host := pageHost(out[i].URL)
+		if _, ok := hosts[host]; !ok {
+			hosts[host] = make(chan struct{}, options.PerHost)
+		}
+	}
+
+	jobs := make(chan int, len(indexes))
+	for _, i := range indexes {
+		jobs <- i
+	}
+	close(jobs)
+
+	workers := options.Workers
+	if workers > len(indexes) {
+		workers = len(indexes)
+	}
+
+	// Each index is written by exactly one worker
+	fetched := make([]bool, len(out))
+	cached := make([]bool, len(out))
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				page, fromCache, err := fetchAllowed(ctx, &out[i], hosts[pageHost(out[i].URL)], options)
+				if err != nil {
+					utils.Debug(fmt.Sprintf("Fetching %s failed: %v", out[i].URL, err))
+					continue
+				}
+				if page != nil {
+					out[i] = *page
+					fetched[i] = true
+					cached[i] = fromCache
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	report := FetchReport{Duration: time.Since(start)}
+	for _, i := range indexes {
+		switch {
+		case fetched[i]:
+			report.Fetched++
+			if cached[i] {
+				report.Cached++
+			}
+		case out[i].RobotsDisallowed:
+			report.Disallowed++
+		default:
+			report.Failed++
+		}
+	}
+	return out, report
+}
+
+// fetches a result's page if robots.txt allows it, once a slot for its host
+// is free and the host's request spacing has passed. A page fresh in the page
+// cache is served without a request, and a stale one is revalidated. A
+// disallowed result is marked and nil is returned.
+func fetchAllowed(ctx context.Context, result *PageInfo, slot chan struct{}, options FetchOptions) (*PageInfo, bool, error) {
+	var cached *cachedPage
+	if options.Cache != nil {
+		if cached = options.Cache.get(result.URL); cached != nil && cached.fresh() {
+			page := cached.apply(*result)
+			return &page, true, nil
+		}
+	}
+
+	u, err := url.Parse(result.URL)
+	if err != nil {
+		return nil, false, err
+	}
+	rules, err := robots.rules(ctx, options.Client, options.UserAgent, u)
+	if err != nil {
+		return nil, false, err
+	}
+	if !rules.Allowed(u.RequestURI()) {
+		utils.Debug(fmt.Sprintf("robots.txt disallows %s, citing its snippet", result.URL))
+		result.RobotsDisallowed = true
+		return nil, false, nil
+	}
+
+	select {
+	case slot <- struct{}{}:
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+	defer func() { <-slot }()
+
+	delay := options.HostDelay
+	if rules.crawlDelay > delay {
+		delay = rules.crawlDelay
+	}
+	if err := pacer.wait(ctx, strings.ToLower(u.Host), delay); err != nil {
+		return nil, false, err
+	}
+
+	pageCtx, cancel := context.WithTimeout(ctx, options.PageTimeout)
+	defer cancel()
+	page, entry, err := fetchPage(pageCtx, *result, cached, options)
+	if err != nil {
+		return nil, false, err
+	}
+	if entry != nil && options.Cache != nil {
+		options.Cache.put(entry)
+	}
+	return &page, entry != nil && entry.notModified, nil
+}
+
+// reports whether a result's page should be fetched for its content
+func needsFetch(result PageInfo) bool {
+	if result.InstantAnswer {
+		return false
+	}
+	u, err := url.Parse(result.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	path := strings.ToLower(u.Path)
+	for _, ext := range []string{".doc", ".docx", ".xlsx"} {
+		if strings.HasSuffix(path, ext) {
+			return false
+		}
+	}
+	return true
+}
+
+// returns the lower-cased host of a URL, used to group per-host limits
+func pageHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// fetches one page and extracts its text. Given a cached copy, the request
+// is conditional and a 304 response returns the cached text. It also returns
+// the entry to store in the page cache, or nil.
+func fetchPage(ctx context.Context, result PageInfo, cached *cachedPage, options FetchOptions) (PageInfo, *cachedPage, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", result.URL, nil)
+	if err != nil {
+		return result, nil, err
+	}
+	if !allowedScheme(req.URL.Scheme) {
+		return result, nil, fmt.Errorf("%q URLs are not fetched", req.URL.Scheme)
+	}
+	req.Header.Set("User-Agent", options.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,application/pdf;q=0.9,text/*;q=0.8,*/*;q=0.7")
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := options.Client.Do(req)
+	if err != nil {
+		return result, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		return cached.apply(result), cached.revalidated(resp.Header), nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return result, nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if resp.ContentLength > options.MaxBytes {
+		return result, nil, fmt.Errorf("response too large (%d bytes)", resp.ContentLength)
+	}
+
+	body := bufio.NewReader(resp.Body)
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		// Sniff untyped responses the way browsers do
+		head, _ := body.Peek(512)
+		contentType = http.DetectContentType(head)
+	}
+	if isPDFResponse(contentType, result.URL) {
+		result, err = readPDF(result, body, options.MaxBytes)
+	} else {
+		result, err = readContent(result, body, contentType, min(options.MaxBytes, maxPageBytes))
+	}
+	if err != nil {
+		return result, nil, err
+	}
+
+	if result.Published.IsZero() {
+		if lastMod := resp.Header.Get("Last-Modified"); lastMod != "" {
+			if pubTime, err := time.Parse(time.RFC1123, lastMod); err == nil {
+				result.Published = pubTime
+			}
+		}
+	}
+	return result, newCachedPage(result.URL, result, resp.Header), nil
+}
+
+func generateSummary(content string) string {
+	sentences := splitToSentences(content)
+	if len(sentences) == 0 {
+		return ""
+	}
+	var summary strings.Builder
+	totalLength := 0
+	maxLength := 300
+	for i, sentence := range sentences {
+		if i >= 3 || totalLength+len(sentence) > maxLength {
+			break
+		}
+		if summary.Len() > 0 {
+			summary.WriteString(" ")
+		}
+		summary.WriteString(sentence)
+		totalLength += len(sentence)
+	}
+	return summary.String()
+}
+
+func splitToSentences(text string) []string {
+	sentenceEnders := regexp.MustCompile(`[.!?]`)
+	parts := sentenceEnders.Split(text, -1)
+	var sentences []string
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if len(part) > 10 {
+			sentences = append(sentences, part+".")
+		}
+	}
+	return sentences
+}
@@ -0,0 +1,226 @@
+package webscrape
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// articleServer serves testdata/article.html after an optional per-path delay
+// and tracks the largest number of requests it handled at once.
+type articleServer struct {
+	*httptest.Server
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func newArticleServer(t *testing.T, delays map[string]time.Duration) *articleServer {
+	t.Helper()
+	article, err := os.ReadFile("testdata/article.html")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	s := &articleServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.inFlight++
+		if s.inFlight > s.maxInFlight {
+			s.maxInFlight = s.inFlight
+		}
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			s.inFlight--
+			s.mu.Unlock()
+		}()
+
+		delay := delays[r.URL.Path]
+		if delay == 0 {
+			delay = 20 * time.Millisecond
+		}
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Last-Modified", "Tue, 14 Oct 2025 08:30:00 GMT")
+		w.Write(article)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func snippets(base string, paths ...string) []PageInfo {
+	results := make([]PageInfo, len(paths))
+	for i, path := range paths {
+		results[i] = PageInfo{URL: base + path, Title: "Snippet " + path, Content: "snippet", Summary: "snippet"}
+	}
+	return results
+}
+
+func TestFetchPagesExtractsContent(t *testing.T) {
+	server := newArticleServer(t, map[string]time.Duration{"/slow": 100 * time.Millisecond})
+	results := snippets(server.URL, "/slow", "/fast", "/missing")
+	results = append(results, PageInfo{URL: server.URL + "/report.docx", Content: "snippet"})
+
+	fetched, report := FetchPages(context.Background(), results, FetchOptions{Client: server.Client()})
+
+	if len(fetched) != len(results) {
+		t.Fatalf("Expected %d results, got %d", len(results), len(fetched))
+	}
+	// Results keep their order even though /fast finishes first
+	for i, result := range fetched {
+		if result.URL != results[i].URL {
+			t.Errorf("Result %d: expected %s, got %s", i, results[i].URL, result.URL)
+		}
+	}
+	for _, result := range fetched[:2] {
+		if !strings.Contains(result.Content, "Go 1.18 added type parameters") {
+			t.Errorf("Expected readable text for %s, got %q", result.URL, result.Content)
+		}
+		if strings.Contains(result.Content, "Archive") {
+			t.Errorf("Expected navigation to be dropped for %s", result.URL)
+		}
+		if result.Title != "Understanding Go Generics | Example Blog" {
+			t.Errorf("Expected the page title, got %q", result.Title)
+		}
+		if !result.Published.Equal(time.Date(2025, 10, 14, 8, 30, 0, 0, time.UTC)) {
+			t.Errorf("Expected Last-Modified as the date, got %v", result.Published)
+		}
+	}
+	if fetched[2].Content != "snippet" || fetched[3].Content != "snippet" {
+		t.Errorf("Expected failed and skipped pages to keep their snippets")
+	}
+	if report.Fetched != 2 || report.Failed != 1 {
+		t.Errorf("Expected 2 fetched and 1 failed, got %+v", report)
+	}
+	if results[0].Content != "snippet" {
+		t.Errorf("Expected the input slice to be left unchanged")
+	}
+}
+
+func TestFetchPagesConcurrencyLimits(t *testing.T) {
+	paths := make([]string, 12)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("/page%d", i)
+	}
+
+	tests := []struct {
+		name     string
+		options  FetchOptions
+		expected int
+	}{
+		{name: "per host", options: FetchOptions{Workers: 8, PerHost: 2, HostDelay: -1}, expected: 2},
+		{name: "workers", options: FetchOptions{Workers: 3, PerHost: 10, HostDelay: -1}, expected: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newArticleServer(t, nil)
+			tt.options.Client = server.Client()
+
+			_, report := FetchPages(context.Background(), snippets(server.URL, paths...), tt.options)
+			if report.Fetched != len(paths) {
+				t.Errorf("Expected every page fetched, got %+v", report)
+			}
+			if server.maxInFlight > tt.expected {
+				t.Errorf("Expected at most %d concurrent requests, got %d", tt.expected, server.maxInFlight)
+			}
+		})
+	}
+}
+
+func TestFetchPagesTimeouts(t *testing.T) {
+	server := newArticleServer(t, map[string]time.Duration{
+		"/hang1": 5 * time.Second,
+		"/hang2": 5 * time.Second,
+		"/hang3": 5 * time.Second,
+	})
+
+	// A page over its own timeout keeps its snippet
+	start := time.Now()
+	fetched, report := FetchPages(context.Background(), snippets(server.URL, "/ok", "/hang1"), FetchOptions{
+		Client:      server.Client(),
+		PageTimeout: 100 * time.Millisecond,
+		HostDelay:   -1,
+	})
+	if fetched[1].Content != "snippet" || report.Fetched != 1 || report.Failed != 1 {
+		t.Errorf("Expected the slow page to time out, got %+v", report)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the page timeout to bound the stage, took %s", elapsed)
+	}
+
+	// The overall deadline stops fetches still running or queued
+	start = time.Now()
+	_, report = FetchPages(context.Background(), snippets(server.URL, "/hang1", "/hang2", "/hang3", "/ok"), FetchOptions{
+		Client:    server.Client(),
+		Workers:   1,
+		Deadline:  150 * time.Millisecond,
+		HostDelay: -1,
+	})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the deadline to bound the stage, took %s", elapsed)
+	}
+	if report.Fetched != 0 || report.Failed != 4 {
+		t.Errorf("Expected every page to miss the deadline, got %+v", report)
+	}
+}
+
+func TestSearchFetchesSnippetResults(t *testing.T) {
+	server := newArticleServer(t, nil)
+	useStubProviders(t, map[string]SearchProvider{
+		"duckduckgo": &stubProvider{results: snippets(server.URL, "/a", "/b")},
+		"corpus":     &stubProvider{results: snippets(server.URL, "/c")},
+	})
+
+	options := SearchOptions{
+		Providers: []ProviderSpec{{Name: "duckduckgo", Weight: 1}, {Name: "corpus", Weight: 1}},
+		Fetch:     FetchOptions{Client: server.Client()},
+	}
+	report := SearchWithReport("go generics", options)
+	if report.Err != nil {
+		t.Fatalf("Search failed: %v", report.Err)
+	}
+	if report.Fetch.Fetched != 2 {
+		t.Errorf("Expected only the DuckDuckGo pages fetched, got %+v", report.Fetch)
+	}
+	for _, result := range report.Results {
+		fetched := !strings.HasSuffix(result.URL, "/c")
+		if fetched != strings.Contains(result.Content, "type parameters") {
+			t.Errorf("Unexpected content for %s: %q", result.URL, result.Content)
+		}
+	}
+
+	// A cap fetches the best ranked pages only
+	options.Fetch.MaxPages = 1
+	report = SearchWithReport("go generics", options)
+	if report.Fetch.Fetched != 1 {
+		t.Errorf("Expected one page fetched with a cap of 1, got %+v", report.Fetch)
+	}
+	for _, result := range report.Results {
+		if strings.Contains(result.Content, "type parameters") && result.URL != report.Results[0].URL {
+			t.Errorf("Expected the top result fetched, got %s fetched", result.URL)
+		}
+	}
+
+	options.Fetch.MaxPages = 0
+	options.Fetch.Disabled = true
+	report = SearchWithReport("go generics", options)
+	if report.Fetch.Fetched != 0 || report.Results[0].Content != "snippet" {
+		t.Errorf("Expected no fetching when disabled, got %+v", report.Fetch)
+	}
+}
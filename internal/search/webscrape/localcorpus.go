@@ -0,0 +1,506 @@
+package webscrape
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/go-shiori/go-readability"
+
+	"open-sonar/internal/utils"
+)
+
+// Files larger than this are not indexed.
+const maxCorpusFileSize = 20 * 1024 * 1024
+
+// Number of results returned per requested page.
+const localCorpusPageSize = 10
+
+// BM25 parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// LocalCorpusSearchProvider searches Markdown, text, HTML and PDF files in a
+// directory through an in-memory inverted index. Results cite file:// URLs,
+// or BaseURL joined with the file's relative path when BaseURL is set.
+type LocalCorpusSearchProvider struct {
+	Root    string
+	BaseURL string
+
+	refreshMu sync.Mutex // serializes Refresh calls
+	mu        sync.RWMutex
+	docs      map[string]*corpusDoc     // relative path -> document
+	postings  map[string]map[string]int // term -> relative path -> term frequency
+	totalLen  int
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type corpusDoc struct {
+	path    string
+	url     string
+	title   string
+	content string
+	modTime time.Time
+	size    int64
+	length  int
+}
+
+var (
+	localCorpora   = map[string]*LocalCorpusSearchProvider{}
+	localCorporaMu sync.Mutex
+)
+
+func init() {
+	RegisterSearchProvider("local", func(config ProviderConfig) (SearchProvider, error) {
+		provider, err := NewLocalCorpusSearchProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	})
+}
+
+// NewLocalCorpusSearchProvider returns the corpus configured by LOCAL_CORPUS_*
+// settings. The index is built once and shared between requests;
+// it is rescanned every LOCAL_CORPUS_REFRESH (default 30s, "0" disables).
+func NewLocalCorpusSearchProvider(config ProviderConfig) (*LocalCorpusSearchProvider, error) {
+	root := config.Get("LOCAL_CORPUS_DIR")
+	if root == "" {
+		return nil, fmt.Errorf("LOCAL_CORPUS_DIR not set")
+	}
+	baseURL := config.Get("LOCAL_CORPUS_BASE_URL")
+
+	refresh := 30 * time.Second
+	if value := config.Get("LOCAL_CORPUS_REFRESH"); value != "" {
+		if value == "0" {
+			refresh = 0
+		} else {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid LOCAL_CORPUS_REFRESH: %w", err)
+			}
+			refresh = d
+		}
+	}
+
+	key := root + "\x00" + baseURL
+	localCorporaMu.Lock()
+	defer localCorporaMu.Unlock()
+	if corpus, ok := localCorpora[key]; ok {
+		return corpus, nil
+	}
+
+	corpus, err := OpenLocalCorpus(root, baseURL, refresh)
+	if err != nil {
+		return nil, err
+	}
+	localCorpora[key] = corpus
+	return corpus, nil
+}
+
+// OpenLocalCorpus indexes root and, when refresh is positive, re-indexes
+// changed files in the background until Close is called.
+func OpenLocalCorpus(root, baseURL string, refresh time.Duration) (*LocalCorpusSearchProvider, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("resolving corpus directory: %w", err)
+	}
+	info, err := os.Stat(absRoot)
+	if err != nil {
+		return nil, fmt.Errorf("opening corpus directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("corpus path %s is not a directory", absRoot)
+	}
+
+	corpus := &LocalCorpusSearchProvider{
+		Root:     absRoot,
+		BaseURL:  strings.TrimRight(baseURL, "/"),
+		docs:     map[string]*corpusDoc{},
+		postings: map[string]map[string]int{},
+		stop:     make(chan struct{}),
+	}
+
+	timer := utils.NewTimer("Local corpus indexing")
+	if _, err := corpus.Refresh(); err != nil {
+		return nil, err
+	}
+	timer.Stop()
+	utils.Info(fmt.Sprintf("Indexed %d documents from %s", corpus.Len(), absRoot))
+
+	if refresh > 0 {
+		go corpus.watch(refresh)
+	}
+	return corpus, nil
+}
+
+// Close stops background re-indexing.
+func (c *LocalCorpusSearchProvider) Close() {
+	c.stopOnce.Do(func() { close(c.stop) })
+}
+
+// Len returns the number of indexed documents.
+func (c *LocalCorpusSearchProvider) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.docs)
+}
+
+// rescans the corpus every interval
+func (c *LocalCorpusSearchProvider) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			if changed, err := c.Refresh(); err != nil {
+				utils.Warn(fmt.Sprintf("Local corpus refresh failed: %v", err))
+			} else if changed > 0 {
+				utils.Info(fmt.Sprintf("Re-indexed %d changed documents in %s", changed, c.Root))
+			}
+		}
+	}
+}
+
+// Refresh re-indexes files that were added, modified or removed since the
+// last scan and returns how many documents changed.
+func (c *LocalCorpusSearchProvider) Refresh() (int, error) {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	type fileState struct {
+		modTime time.Time
+		size    int64
+	}
+	current := map[string]fileState{}
+
+	err := filepath.WalkDir(c.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			utils.Warn(fmt.Sprintf("Skipping %s: %v", path, err))
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			// Skip hidden directories such as .git
+			if path != c.Root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isCorpusFile(path) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() > maxCorpusFileSize {
+			return nil
+		}
+		rel, err := filepath.Rel(c.Root, path)
+		if err != nil {
+			return nil
+		}
+		current[filepath.ToSlash(rel)] = fileState{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("scanning corpus directory: %w", err)
+	}
+
+	// Work out what changed under the read lock, then parse without holding any lock
+	var stale []string
+	var removed []string
+	c.mu.RLock()
+	for rel, state := range current {
+		doc, ok := c.docs[rel]
+		if !ok || !doc.modTime.Equal(state.modTime) || doc.size != state.size {
+			stale = append(stale, rel)
+		}
+	}
+	for rel := range c.docs {
+		if _, ok := current[rel]; !ok {
+			removed = append(removed, rel)
+		}
+	}
+	c.mu.RUnlock()
+
+	parsed := make([]*corpusDoc, 0, len(stale))
+	for _, rel := range stale {
+		doc, err := c.parseFile(rel)
+		if err != nil {
+			utils.Warn(fmt.Sprintf("Failed to index %s: %v", rel, err))
+			// Drop any previous version rather than serve outdated text
+			removed = append(removed, rel)
+			continue
+		}
+		doc.modTime = current[rel].modTime
+		doc.size = current[rel].size
+		parsed = append(parsed, doc)
+	}
+
+	c.mu.Lock()
+	for _, rel := range removed {
+		c.removeLocked(rel)
+	}
+	for _, doc := range parsed {
+		c.removeLocked(doc.path)
+		c.addLocked(doc)
+	}
+	c.mu.Unlock()
+
+	return len(parsed) + len(removed), nil
+}
+
+// adds a document's terms to the index; callers hold the write lock
+func (c *LocalCorpusSearchProvider) addLocked(doc *corpusDoc) {
+	terms := tokenize(doc.title + " " + doc.content)
+	doc.length = len(terms)
+	for _, term := range terms {
+		postings, ok := c.postings[term]
+		if !ok {
+			postings = map[string]int{}
+			c.postings[term] = postings
+		}
+		postings[doc.path]++
+	}
+	c.docs[doc.path] = doc
+	c.totalLen += doc.length
+}
+
+// removes a document from the index; callers hold the write lock
+func (c *LocalCorpusSearchProvider) removeLocked(rel string) {
+	doc, ok := c.docs[rel]
+	if !ok {
+		return
+	}
+	for _, term := range tokenize(doc.title + " " + doc.content) {
+		if postings, ok := c.postings[term]; ok {
+			delete(postings, rel)
+			if len(postings) == 0 {
+				delete(c.postings, term)
+			}
+		}
+	}
+	c.totalLen -= doc.length
+	delete(c.docs, rel)
+}
+
+// Search ranks documents against the query with BM25.
+func (c *LocalCorpusSearchProvider) Search(query string, options SearchOptions) ([]PageInfo, error) {
+	if options.MaxPages <= 0 {
+		options.MaxPages = 1
+	}
+
+	terms := uniqueTerms(tokenize(query))
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.docs) == 0 {
+		return nil, nil
+	}
+	avgLen := float64(c.totalLen) / float64(len(c.docs))
+	n := float64(len(c.docs))
+
+	scores := map[string]float64{}
+	for _, term := range terms {
+		postings := c.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for rel, tf := range postings {
+			docLen := float64(c.docs[rel].length)
+			freq := float64(tf)
+			scores[rel] += idf * freq * (bm25K1 + 1) / (freq + bm25K1*(1-bm25B+bm25B*docLen/avgLen))
+		}
+	}
+
+	ranked := make([]string, 0, len(scores))
+	for rel := range scores {
+		ranked = append(ranked, rel)
+	}
+	// Ties are broken by path so results are stable
+	sort.Slice(ranked, func(i, j int) bool {
+		if scores[ranked[i]] != scores[ranked[j]] {
+			return scores[ranked[i]] > scores[ranked[j]]
+		}
+		return ranked[i] < ranked[j]
+	})
+
+	limit := options.MaxPages * localCorpusPageSize
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	results := make([]PageInfo, 0, len(ranked))
+	for _, rel := range ranked {
+		doc := c.docs[rel]
+		results = append(results, PageInfo{
+			URL:       doc.url,
+			Title:     doc.title,
+			Content:   doc.content,
+			Summary:   bestSnippet(doc.content, terms, 300),
+			Published: doc.modTime,
+		})
+	}
+	return results, nil
+}
+
+// reads and extracts a corpus file
+func (c *LocalCorpusSearchProvider) parseFile(rel string) (*corpusDoc, error) {
+	path := filepath.Join(c.Root, filepath.FromSlash(rel))
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var title, content string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		content = string(raw)
+		title = markdownTitle(content)
+	case ".txt":
+		content = string(raw)
+	case ".html", ".htm":
+		title, content, err = extractHTMLFile(raw, c.citationURL(rel))
+	case ".pdf":
+		content, err = extractPDFText(raw)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return &corpusDoc{
+		path:    rel,
+		url:     c.citationURL(rel),
+		title:   strings.TrimSpace(title),
+		content: strings.TrimSpace(content),
+	}, nil
+}
+
+// builds the citation URL for a relative path
+func (c *LocalCorpusSearchProvider) citationURL(rel string) string {
+	if c.BaseURL != "" {
+		segments := strings.Split(rel, "/")
+		for i, segment := range segments {
+			segments[i] = url.PathEscape(segment)
+		}
+		return c.BaseURL + "/" + strings.Join(segments, "/")
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(c.Root, filepath.FromSlash(rel)))}
+	return u.String()
+}
+
+func isCorpusFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown", ".txt", ".html", ".htm", ".pdf":
+		return true
+	default:
+		return false
+	}
+}
+
+// returns the first level-one heading of a Markdown document
+func markdownTitle(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "# ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "# "))
+		}
+	}
+	return ""
+}
+
+// extracts the title and readable text of an HTML document, falling back to
+// the whole body when readability finds no article
+func extractHTMLFile(raw []byte, pageURL string) (string, string, error) {
+	base, _ := url.Parse(pageURL)
+	if article, err := readability.FromReader(bytes.NewReader(raw), base); err == nil && strings.TrimSpace(article.TextContent) != "" {
+		return article.Title, article.TextContent, nil
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(raw))
+	if err != nil {
+		return "", "", fmt.Errorf("parsing HTML: %w", err)
+	}
+	doc.Find("script, style, noscript").Remove()
+	return doc.Find("title").First().Text(), doc.Find("body").Text(), nil
+}
+
+// lowercases text and splits it into letter/digit runs of two or more
+// characters, folding simple plurals so "backups" matches "backup"
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := fields[:0]
+	for _, field := range fields {
+		if len([]rune(field)) < 2 {
+			continue
+		}
+		if len(field) > 3 && strings.HasSuffix(field, "s") && !strings.HasSuffix(field, "ss") {
+			field = field[:len(field)-1]
+		}
+		terms = append(terms, field)
+	}
+	return terms
+}
+
+func uniqueTerms(terms []string) []string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			unique = append(unique, term)
+		}
+	}
+	return unique
+}
+
+// returns the paragraph mentioning the most query terms, truncated to maxLen
+func bestSnippet(content string, terms []string, maxLen int) string {
+	best, bestHits := "", -1
+	for _, paragraph := range strings.Split(content, "\n\n") {
+		paragraph = strings.Join(strings.Fields(paragraph), " ")
+		if paragraph == "" {
+			continue
+		}
+		hits := 0
+		for _, term := range uniqueTerms(tokenize(paragraph)) {
+			for _, t := range terms {
+				if term == t {
+					hits++
+				}
+			}
+		}
+		if hits > bestHits {
+			best, bestHits = paragraph, hits
+		}
+	}
+	return utils.TruncateText(best, maxLen)
+}
@@ -0,0 +1,143 @@
+package webscrape
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeCorpusFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+}
+
+func TestLocalCorpusSearch(t *testing.T) {
+	dir := t.TempDir()
+	writeCorpusFile(t, dir, "guides/backup.md", "# Backup guide\n\nSnapshots run nightly.\n\nRestore a backup with the restore command.")
+	writeCorpusFile(t, dir, "notes.txt", "Meeting notes about the cafeteria menu.")
+	writeCorpusFile(t, dir, "faq.html", "<html><head><title>FAQ</title></head><body><p>Backups are kept for 30 days.</p><script>var backup = 1;</script></body></html>")
+	writeCorpusFile(t, dir, ".git/backup.md", "ignored backup")
+	writeCorpusFile(t, dir, "image.png", "backup")
+
+	pdfData, err := os.ReadFile("testdata/corpus.pdf")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	writeCorpusFile(t, dir, "offline.pdf", string(pdfData))
+
+	corpus, err := OpenLocalCorpus(dir, "", 0)
+	if err != nil {
+		t.Fatalf("Failed to open corpus: %v", err)
+	}
+	defer corpus.Close()
+
+	if corpus.Len() != 4 {
+		t.Fatalf("Expected 4 indexed documents, got %d", corpus.Len())
+	}
+
+	results, err := corpus.Search("how do I restore a backup?", SearchOptions{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d: %+v", len(results), results)
+	}
+
+	first := results[0]
+	if first.Title != "Backup guide" {
+		t.Errorf("Expected Markdown heading as title, got %q", first.Title)
+	}
+	if !strings.HasPrefix(first.URL, "file://") || !strings.HasSuffix(first.URL, "/guides/backup.md") {
+		t.Errorf("Expected file:// citation, got %q", first.URL)
+	}
+	if first.Summary != "Restore a backup with the restore command." {
+		t.Errorf("Expected best matching paragraph as summary, got %q", first.Summary)
+	}
+	if first.Published.IsZero() {
+		t.Error("Expected modification time as published date")
+	}
+	if results[1].Title != "FAQ" || strings.Contains(results[1].Content, "var backup") {
+		t.Errorf("Unexpected HTML result: %+v", results[1])
+	}
+
+	results, _ = corpus.Search("reindexing offline", SearchOptions{})
+	if len(results) != 1 || results[0].Title != "offline" || !strings.Contains(results[0].Content, "Reindexing happens") {
+		t.Errorf("Expected PDF text to be searchable, got %+v", results)
+	}
+}
+
+func TestLocalCorpusRefresh(t *testing.T) {
+	dir := t.TempDir()
+	writeCorpusFile(t, dir, "a.md", "alpha content")
+	writeCorpusFile(t, dir, "b.md", "bravo content")
+
+	corpus, err := OpenLocalCorpus(dir, "https://docs.internal/corpus/", 0)
+	if err != nil {
+		t.Fatalf("Failed to open corpus: %v", err)
+	}
+	defer corpus.Close()
+
+	// Modify a.md, delete b.md and add c.md
+	writeCorpusFile(t, dir, "a.md", "charlie content")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(dir, "a.md"), later, later)
+	os.Remove(filepath.Join(dir, "b.md"))
+	writeCorpusFile(t, dir, "sub dir/c.md", "charlie again")
+
+	changed, err := corpus.Refresh()
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if changed != 3 {
+		t.Errorf("Expected 3 changed documents, got %d", changed)
+	}
+
+	if results, _ := corpus.Search("alpha", SearchOptions{}); len(results) != 0 {
+		t.Errorf("Expected stale terms to be removed, got %+v", results)
+	}
+	if results, _ := corpus.Search("bravo", SearchOptions{}); len(results) != 0 {
+		t.Errorf("Expected deleted file to be removed, got %+v", results)
+	}
+
+	results, _ := corpus.Search("charlie", SearchOptions{})
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	urls := results[0].URL + " " + results[1].URL
+	if !strings.Contains(urls, "https://docs.internal/corpus/a.md") || !strings.Contains(urls, "https://docs.internal/corpus/sub%20dir/c.md") {
+		t.Errorf("Expected base URL citations, got %s", urls)
+	}
+
+	if changed, _ := corpus.Refresh(); changed != 0 {
+		t.Errorf("Expected no changes on second refresh, got %d", changed)
+	}
+}
+
+func TestGetLocalCorpusProvider(t *testing.T) {
+	t.Setenv("LOCAL_CORPUS_DIR", "")
+	if _, err := DefaultGetSearchProvider("local"); err == nil {
+		t.Error("Expected error when LOCAL_CORPUS_DIR is not set")
+	}
+
+	dir := t.TempDir()
+	writeCorpusFile(t, dir, "a.txt", "hello")
+	t.Setenv("LOCAL_CORPUS_DIR", dir)
+	t.Setenv("LOCAL_CORPUS_REFRESH", "0")
+
+	first, err := DefaultGetSearchProvider("local")
+	if err != nil {
+		t.Fatalf("Failed to create local provider: %v", err)
+	}
+	second, _ := DefaultGetSearchProvider("local")
+	if first != second {
+		t.Error("Expected the index to be shared between lookups")
+	}
+}
@@ -0,0 +1,365 @@
+package webscrape
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// Extracted pages are kept as compact Markdown: headings, lists, tables,
+// block quotes and code survive, since they often carry the facts (a spec
+// table, a version list), while links, images and emphasis are reduced to
+// their text so they don't spend tokens.
+
+// elements rendered as nothing
+var skippedElements = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true, "svg": true,
+	"img": true, "picture": true, "video": true, "audio": true, "canvas": true,
+	"button": true, "input": true, "select": true, "textarea": true, "head": true,
+}
+
+// selectionMarkdown renders the elements of a selection as Markdown, in
+// document order.
+func selectionMarkdown(selection *goquery.Selection) string {
+	var blocks []string
+	for _, node := range selection.Nodes {
+		if block := markdownBlock(node); block != "" {
+			blocks = append(blocks, block)
+		}
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// htmlMarkdown renders an HTML fragment, such as readability's article, as
+// Markdown.
+func htmlMarkdown(fragment string) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(fragment))
+	if err != nil {
+		return "", fmt.Errorf("parsing HTML: %w", err)
+	}
+	return selectionMarkdown(doc.Find("body")), nil
+}
+
+// renders an element as a Markdown block, or "" when it has no text
+func markdownBlock(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return strings.Join(strings.Fields(n.Data), " ")
+	case html.ElementNode:
+	default:
+		return markdownBlocks(n, "\n\n")
+	}
+	if skippedElements[n.Data] {
+		return ""
+	}
+
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		text := strings.ReplaceAll(inlineMarkdown(n), "\n", " ")
+		if text == "" {
+			return ""
+		}
+		return strings.Repeat("#", int(n.Data[1]-'0')) + " " + text
+	case "pre":
+		return codeBlock(n)
+	case "ul", "ol":
+		return listMarkdown(n)
+	case "table":
+		return tableMarkdown(n)
+	case "blockquote":
+		inner := markdownBlocks(n, "\n\n")
+		if inner == "" {
+			return ""
+		}
+		lines := strings.Split(inner, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		return strings.Join(lines, "\n")
+	case "hr":
+		return "---"
+	case "br":
+		return ""
+	}
+	return markdownBlocks(n, "\n\n")
+}
+
+// renders the children of n, each block and each run of inline content on
+// its own, joined by sep
+func markdownBlocks(n *html.Node, sep string) string {
+	var blocks []string
+	var inline strings.Builder
+	flush := func() {
+		if text := collapseInline(inline.String()); text != "" {
+			blocks = append(blocks, text)
+		}
+		inline.Reset()
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		// A <br> breaks the line within a paragraph
+		if child.Type == html.ElementNode && blockElements[child.Data] && child.Data != "br" {
+			flush()
+			if block := markdownBlock(child); block != "" {
+				blocks = append(blocks, block)
+			}
+			continue
+		}
+		writeInline(&inline, child)
+	}
+	flush()
+	return strings.Join(blocks, sep)
+}
+
+// returns the inline text of an element, with line breaks kept
+func inlineMarkdown(n *html.Node) string {
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		writeInline(&b, child)
+	}
+	return collapseInline(b.String())
+}
+
+func writeInline(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+	switch {
+	case skippedElements[n.Data]:
+		return
+	case n.Data == "br":
+		b.WriteByte('\n')
+		return
+	case n.Data == "code" || n.Data == "kbd" || n.Data == "samp":
+		if code := strings.Join(strings.Fields(nodeText(n)), " "); code != "" {
+			fence := "`"
+			if strings.Contains(code, "`") {
+				fence = "``"
+			}
+			b.WriteString(fence + code + fence)
+		}
+		return
+	case blockElements[n.Data]:
+		// A block inside inline content, e.g. a <div> in a <span>
+		b.WriteByte('\n')
+		defer b.WriteByte('\n')
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		writeInline(b, child)
+	}
+}
+
+// collapses whitespace within lines and drops empty ones
+func collapseInline(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// returns the text of a node and its descendants, as is
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.Data == "br" {
+			b.WriteByte('\n')
+			continue
+		}
+		b.WriteString(nodeText(child))
+	}
+	return b.String()
+}
+
+// classes naming a code block's language, e.g. "language-go" or
+// "highlight-python3"
+var codeLanguageClass = regexp.MustCompile(`(?:^|\s)(?:language|lang|highlight)-([A-Za-z0-9_+#-]+)`)
+
+// renders a <pre> as a fenced code block, labelled with its language
+func codeBlock(n *html.Node) string {
+	code := strings.Trim(nodeText(n), "\n")
+	if strings.TrimSpace(code) == "" {
+		return ""
+	}
+	// Highlighters put the class on the <pre>, its <code> or a wrapper
+	language := ""
+	for node, depth := n, 0; node != nil && depth < 3 && language == ""; node, depth = node.Parent, depth+1 {
+		language = codeLanguage(node)
+	}
+	for child := n.FirstChild; child != nil && language == ""; child = child.NextSibling {
+		language = codeLanguage(child)
+	}
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + language + "\n" + code + "\n" + fence
+}
+
+func codeLanguage(n *html.Node) string {
+	if n.Type != html.ElementNode {
+		return ""
+	}
+	for _, attr := range n.Attr {
+		if attr.Key != "class" {
+			continue
+		}
+		if match := codeLanguageClass.FindStringSubmatch(attr.Val); match != nil && match[1] != "default" {
+			return strings.ToLower(match[1])
+		}
+	}
+	return ""
+}
+
+// renders a list, with nested lists indented under their item
+func listMarkdown(n *html.Node) string {
+	number := 1
+	if n.Data == "ol" {
+		for _, attr := range n.Attr {
+			if attr.Key == "start" {
+				fmt.Sscan(attr.Val, &number)
+			}
+		}
+	}
+
+	var items []string
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode || child.Data != "li" {
+			// Stray content between items is rendered as its own item
+			if text := markdownBlock(child); text != "" {
+				items = append(items, "- "+text)
+			}
+			continue
+		}
+		text := markdownBlocks(child, "\n")
+		if text == "" {
+			continue
+		}
+		marker := "- "
+		if n.Data == "ol" {
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+		indent := strings.Repeat(" ", len(marker))
+		lines := strings.Split(text, "\n")
+		for i := 1; i < len(lines); i++ {
+			lines[i] = indent + lines[i]
+		}
+		items = append(items, marker+strings.Join(lines, "\n"))
+	}
+	return strings.Join(items, "\n")
+}
+
+// renders a table as a pipe table headed by its first row. Layout tables,
+// with a single column, are rendered as their cells' content.
+func tableMarkdown(n *html.Node) string {
+	var rows [][]string
+	var cells []*html.Node
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			switch child.Data {
+			case "thead", "tbody", "tfoot":
+				walk(child)
+			case "tr":
+				var row []string
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.Data == "td" || cell.Data == "th") {
+						row = append(row, tableCell(cell))
+						cells = append(cells, cell)
+					}
+				}
+				if len(row) > 0 {
+					rows = append(rows, row)
+				}
+			}
+		}
+	}
+	walk(n)
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	if columns <= 1 {
+		var blocks []string
+		for _, cell := range cells {
+			if block := markdownBlocks(cell, "\n\n"); block != "" {
+				blocks = append(blocks, block)
+			}
+		}
+		return strings.Join(blocks, "\n\n")
+	}
+
+	var b strings.Builder
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", columns) + "\n")
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// returns a table cell's text on one line, with pipes escaped
+func tableCell(n *html.Node) string {
+	text := strings.ReplaceAll(inlineMarkdown(n), "\n", " ")
+	return strings.ReplaceAll(text, "|", `\|`)
+}
+
+// compactMarkdown trims trailing whitespace and collapses runs of blank lines
+// in Markdown or plain text, keeping indentation.
+func compactMarkdown(text string) string {
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			blank = len(lines) > 0
+			continue
+		}
+		if blank {
+			lines = append(lines, "")
+			blank = false
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// markdown markers at the start of a line: headings, quotes, list items,
+// table pipes and code fences
+var markdownLinePrefix = regexp.MustCompile("^\\s*(?:#{1,6}\\s+|>\\s?|[-*+]\\s+|\\d+[.)]\\s+|`{3,}\\w*|~{3,}\\w*|\\|)")
+
+// plainText flattens Markdown to a single line of text, e.g. for a summary.
+func plainText(markdown string) string {
+	var words []string
+	for _, line := range strings.Split(markdown, "\n") {
+		line = markdownLinePrefix.ReplaceAllString(line, "")
+		if strings.Trim(line, "|-: ") == "" {
+			continue
+		}
+		line = strings.ReplaceAll(line, " | ", " ")
+		line = strings.TrimSuffix(strings.TrimSpace(line), "|")
+		words = append(words, strings.Fields(line)...)
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,84 @@
+package webscrape
+
+import (
+	"testing"
+)
+
+func TestHTMLMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			"headings and paragraphs",
+			`<h2>Install <a href="/x">guide</a></h2><p>Run the   <em>installer</em>.<br>Then restart.</p><div>Loose <span>text</span></div>`,
+			"## Install guide\n\nRun the installer.\nThen restart.\n\nLoose text",
+		},
+		{
+			"ordered list with start",
+			`<ol start="3"><li>Unpack</li><li><p>Configure</p><ul><li>ports</li><li>users</li></ul></li></ol>`,
+			"3. Unpack\n4. Configure\n   - ports\n   - users",
+		},
+		{
+			"table with pipes and missing cells",
+			`<table><tr><th>Flag</th><th>Meaning</th></tr><tr><td><code>-a|-b</code></td><td>either</td></tr><tr><td>-v</td></tr></table>`,
+			"| Flag | Meaning |\n| --- | --- |\n| `-a\\|-b` | either |\n| -v |  |",
+		},
+		{
+			"layout table",
+			`<table><tr><td><p>Only one column</p></td></tr><tr><td>so no grid</td></tr></table>`,
+			"Only one column\n\nso no grid",
+		},
+		{
+			"code block keeps indentation",
+			"<pre class=\"lang-go\">func main() {\n\tfmt.Println(\"a  b\")\n}\n</pre>",
+			"```go\nfunc main() {\n\tfmt.Println(\"a  b\")\n}\n```",
+		},
+		{
+			"code containing a fence",
+			"<pre><code>```\nnested\n```</code></pre>",
+			"````\n```\nnested\n```\n````",
+		},
+		{
+			"inline code with a backtick",
+			"<p>Quote with <code>`x`</code> here</p>",
+			"Quote with ```x``` here",
+		},
+		{
+			"blockquote",
+			`<blockquote><p>First</p><p>Second</p></blockquote>`,
+			"> First\n>\n> Second",
+		},
+		{
+			"skipped elements",
+			`<p>Kept<script>alert(1)</script><img alt="logo"></p><button>Click</button><hr><p>After</p>`,
+			"Kept\n\n---\n\nAfter",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := htmlMarkdown(tt.html)
+			if err != nil {
+				t.Fatalf("htmlMarkdown failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCompactMarkdown(t *testing.T) {
+	got := compactMarkdown("\n\n# Notes  \r\n\r\n\r\n- one\n  - two\t\n\n\n\nEnd\n")
+	if want := "# Notes\n\n- one\n  - two\n\nEnd"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	markdown := "# Title\n\n- one\n2. two\n\n| a | b |\n| --- | --- |\n| 1 | 2 |\n\n```go\nx := 1\n```\n\n> quoted"
+	if got, want := plainText(markdown), "Title one two a b 1 2 x := 1 quoted"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,268 @@
+package webscrape
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"open-sonar/internal/proxy"
+	"open-sonar/internal/utils"
+)
+
+// DefaultMediaWikiLanguage is the Wikipedia searched when no wiki is set.
+const DefaultMediaWikiLanguage = "en"
+
+// The TextExtracts API returns at most 20 intro extracts per request.
+const mediaWikiResultsPerPage = 10
+
+// maxMediaWikiResponseBytes caps how much of an API response is read.
+const maxMediaWikiResponseBytes = maxPageBytes
+
+// MediaWikiSearchProvider searches a MediaWiki wiki, such as Wikipedia,
+// through its search API and reads each page's plain-text intro from the
+// TextExtracts API, so no HTML is scraped.
+type MediaWikiSearchProvider struct {
+	APIURL    string // the wiki's api.php
+	Variant   string // language variant, e.g. "zh-tw" or "sr-el"
+	UserAgent string
+	Client    *http.Client
+}
+
+type mediaWikiResponse struct {
+	Continue *struct {
+		Offset int `json:"gsroffset"`
+	} `json:"continue"`
+	Query struct {
+		Pages []mediaWikiPage `json:"pages"`
+	} `json:"query"`
+	Error *struct {
+		Code string `json:"code"`
+		Info string `json:"info"`
+	} `json:"error"`
+}
+
+type mediaWikiPage struct {
+	Title         string            `json:"title"`
+	Index         int               `json:"index"` // rank in the search results
+	Missing       bool              `json:"missing"`
+	Extract       string            `json:"extract"`
+	CanonicalURL  string            `json:"canonicalurl"`
+	FullURL       string            `json:"fullurl"`
+	VariantTitles map[string]string `json:"varianttitles"`
+	Revisions     []struct {
+		Timestamp string `json:"timestamp"`
+	} `json:"revisions"`
+}
+
+func init() {
+	for _, name := range []string{"wikipedia", "mediawiki"} {
+		RegisterSearchProvider(name, func(config ProviderConfig) (SearchProvider, error) {
+			provider, err := NewMediaWikiSearchProvider(config)
+			if err != nil {
+				return nil, err
+			}
+			return provider, nil
+		})
+	}
+}
+
+// NewMediaWikiSearchProvider creates a provider from MEDIAWIKI_* settings.
+// MEDIAWIKI_URL names a wiki's api.php; without it the Wikipedia in
+// MEDIAWIKI_LANGUAGE (default English) is searched.
+func NewMediaWikiSearchProvider(config ProviderConfig) (*MediaWikiSearchProvider, error) {
+	p := &MediaWikiSearchProvider{
+		Variant:   config.Get("MEDIAWIKI_VARIANT"),
+		UserAgent: config.Get("MEDIAWIKI_USER_AGENT"),
+	}
+	if p.UserAgent == "" {
+		p.UserAgent = FetchUserAgent()
+	}
+	if apiURL := config.Get("MEDIAWIKI_URL"); apiURL != "" {
+		u, err := url.Parse(apiURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid MEDIAWIKI_URL %q", apiURL)
+		}
+		if !strings.HasSuffix(u.Path, ".php") {
+			u.Path = strings.TrimRight(u.Path, "/") + "/api.php"
+		}
+		p.APIURL = u.String()
+		// A configured wiki is usually internal, so like Elasticsearch it is
+		// reached directly rather than through SEARCH_PROXIES
+		p.Client = &http.Client{Timeout: 30 * time.Second}
+		return p, nil
+	}
+
+	language := strings.ToLower(config.Get("MEDIAWIKI_LANGUAGE"))
+	if language == "" {
+		language = DefaultMediaWikiLanguage
+	}
+	for _, r := range language {
+		if (r < 'a' || r > 'z') && r != '-' {
+			return nil, fmt.Errorf("invalid MEDIAWIKI_LANGUAGE %q", language)
+		}
+	}
+	p.APIURL = "https://" + language + ".wikipedia.org/w/api.php"
+	p.Client = proxy.NewClient(proxy.Search, 30*time.Second)
+	return p, nil
+}
+
+// Search fetches up to options.MaxPages pages of search results.
+func (p *MediaWikiSearchProvider) Search(query string, options SearchOptions) ([]PageInfo, error) {
+	if options.MaxPages <= 0 {
+		options.MaxPages = 1
+	}
+
+	timer := utils.NewTimer("MediaWiki search")
+	defer timer.Stop()
+
+	var results []PageInfo
+	seen := make(map[string]bool)
+
+	offset := 0
+	for page := 1; page <= options.MaxPages; page++ {
+		pageResults, next, err := p.fetchPage(options.requestContext(), query, offset)
+		if err != nil {
+			// Keep what earlier pages returned
+			if page > 1 {
+				utils.Warn(fmt.Sprintf("MediaWiki page %d failed: %v", page, err))
+				break
+			}
+			return nil, err
+		}
+
+		for _, result := range pageResults {
+			if result.URL == "" || seen[result.URL] {
+				continue
+			}
+			seen[result.URL] = true
+			results = append(results, result)
+		}
+
+		if next <= offset {
+			break
+		}
+		offset = next
+	}
+
+	return results, nil
+}
+
+// fetches one page of results in search order; next is the offset of the
+// following page, or 0 when there is none
+func (p *MediaWikiSearchProvider) fetchPage(ctx context.Context, query string, offset int) ([]PageInfo, int, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.searchURL(query, offset), nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	// Wikimedia refuses requests without a descriptive User-Agent
+	req.Header.Set("User-Agent", p.UserAgent)
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, 0, &QuotaError{Provider: "mediawiki", StatusCode: resp.StatusCode, RetryAfter: mediaWikiRetryAfter(resp.Header)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("MediaWiki API error: %s", resp.Status)
+	}
+
+	var decoded mediaWikiResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxMediaWikiResponseBytes)).Decode(&decoded); err != nil {
+		return nil, 0, fmt.Errorf("error parsing response: %w", err)
+	}
+	if decoded.Error != nil {
+		// Errors come with a 200 status
+		switch decoded.Error.Code {
+		case "ratelimited", "maxlag":
+			return nil, 0, &QuotaError{Provider: "mediawiki", StatusCode: resp.StatusCode, Code: decoded.Error.Code, Message: decoded.Error.Info, RetryAfter: mediaWikiRetryAfter(resp.Header)}
+		}
+		return nil, 0, fmt.Errorf("MediaWiki API error: %s: %s", decoded.Error.Code, decoded.Error.Info)
+	}
+
+	// Generated pages are keyed by page ID; index gives the search order
+	pages := decoded.Query.Pages
+	sort.SliceStable(pages, func(i, j int) bool { return pages[i].Index < pages[j].Index })
+
+	results := make([]PageInfo, 0, len(pages))
+	for _, page := range pages {
+		if page.Missing {
+			continue
+		}
+		pageURL := page.CanonicalURL
+		if pageURL == "" {
+			pageURL = page.FullURL
+		}
+		title := page.Title
+		if variant := page.VariantTitles[p.Variant]; variant != "" {
+			title = variant
+		}
+		extract := compactMarkdown(page.Extract)
+		var published time.Time
+		if len(page.Revisions) > 0 {
+			published, _ = time.Parse(time.RFC3339, page.Revisions[0].Timestamp)
+		}
+		results = append(results, PageInfo{
+			URL:       pageURL,
+			Title:     title,
+			Content:   extract,
+			Summary:   generateSummary(extract),
+			Published: published,
+		})
+	}
+
+	next := 0
+	if decoded.Continue != nil {
+		next = decoded.Continue.Offset
+	}
+	return results, next, nil
+}
+
+// builds a query generating pages from the search, with their intro
+// extract, URLs, titles in each variant and last revision time
+func (p *MediaWikiSearchProvider) searchURL(query string, offset int) string {
+	params := url.Values{}
+	params.Set("action", "query")
+	params.Set("format", "json")
+	params.Set("formatversion", "2")
+	params.Set("generator", "search")
+	params.Set("gsrsearch", query)
+	params.Set("gsrnamespace", "0")
+	params.Set("gsrlimit", strconv.Itoa(mediaWikiResultsPerPage))
+	params.Set("gsroffset", strconv.Itoa(offset))
+	params.Set("prop", "extracts|info|revisions")
+	params.Set("exintro", "1")
+	params.Set("explaintext", "1")
+	params.Set("exsectionformat", "plain")
+	params.Set("exlimit", "max")
+	params.Set("inprop", "url|varianttitles")
+	params.Set("rvprop", "timestamp")
+	params.Set("redirects", "1")
+	if p.Variant != "" {
+		params.Set("variant", p.Variant)
+	}
+	return p.APIURL + "?" + params.Encode()
+}
+
+// reads the wait time from Retry-After, in seconds
+func mediaWikiRetryAfter(header http.Header) time.Duration {
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
+}
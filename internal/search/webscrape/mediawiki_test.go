@@ -0,0 +1,221 @@
+package webscrape
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newMediaWikiServer serves recorded api.php responses, the first page from
+// fixture and later pages from more, recording each request's parameters.
+func newMediaWikiServer(t *testing.T, fixture string, more string) (*httptest.Server, *[]url.Values) {
+	t.Helper()
+	body, err := os.ReadFile("testdata/" + fixture)
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	var requests []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/w/api.php" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.UserAgent() != "TestBot/1.0 (+https://bot.example)" {
+			http.Error(w, "Please set a user-agent", http.StatusForbidden)
+			return
+		}
+		requests = append(requests, r.URL.Query())
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if r.URL.Query().Get("gsroffset") == "0" {
+			w.Write(body)
+			return
+		}
+		fmt.Fprint(w, more)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestMediaWikiSearchProvider(t *testing.T) {
+	secondPage := `{"batchcomplete": true, "query": {"pages": [
+		{"pageid": 1, "ns": 0, "title": "Ken Thompson", "index": 11, "extract": "Kenneth Lane Thompson is an American pioneer of computer science.",
+		 "canonicalurl": "https://en.wikipedia.org/wiki/Ken_Thompson", "revisions": [{"timestamp": "2024-01-05T00:00:00Z"}]},
+		{"pageid": 25039021, "ns": 0, "title": "Go (programming language)", "index": 12, "extract": "Duplicate.",
+		 "canonicalurl": "https://en.wikipedia.org/wiki/Go_(programming_language)"}
+	]}}`
+	server, requests := newMediaWikiServer(t, "mediawiki_search.json", secondPage)
+
+	provider, err := NewMediaWikiSearchProvider(ProviderConfig{
+		"MEDIAWIKI_URL":        server.URL + "/w/",
+		"MEDIAWIKI_USER_AGENT": "TestBot/1.0 (+https://bot.example)",
+	})
+	if err != nil {
+		t.Fatalf("NewMediaWikiSearchProvider failed: %v", err)
+	}
+	if provider.APIURL != server.URL+"/w/api.php" {
+		t.Errorf("Expected api.php appended to the wiki URL, got %s", provider.APIURL)
+	}
+	provider.Client = server.Client()
+
+	results, err := provider.Search("golang", SearchOptions{MaxPages: 5})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	// The second page has no continuation, so pagination stops there
+	if len(*requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(*requests))
+	}
+	first := (*requests)[0]
+	for key, want := range map[string]string{
+		"action": "query", "format": "json", "formatversion": "2", "generator": "search",
+		"gsrsearch": "golang", "gsroffset": "0", "prop": "extracts|info|revisions",
+		"exintro": "1", "explaintext": "1", "inprop": "url|varianttitles", "rvprop": "timestamp",
+	} {
+		if got := first.Get(key); got != want {
+			t.Errorf("Expected %s=%q, got %q", key, want, got)
+		}
+	}
+	if first.Has("variant") {
+		t.Errorf("Expected no variant parameter without MEDIAWIKI_VARIANT")
+	}
+	if got := (*requests)[1].Get("gsroffset"); got != "10" {
+		t.Errorf("Expected the second page at the continuation offset 10, got %s", got)
+	}
+
+	// Search order follows each page's index, not the response order
+	wantURLs := []string{
+		"https://en.wikipedia.org/wiki/Go_(programming_language)",
+		"https://en.wikipedia.org/wiki/Robert_Griesemer",
+		"https://en.wikipedia.org/wiki/Goroutine",
+		"https://en.wikipedia.org/wiki/Ken_Thompson",
+	}
+	if len(results) != len(wantURLs) {
+		t.Fatalf("Expected %d results, got %d: %+v", len(wantURLs), len(results), results)
+	}
+	for i, want := range wantURLs {
+		if results[i].URL != want {
+			t.Errorf("Result %d: expected %s, got %s", i, want, results[i].URL)
+		}
+	}
+
+	golang := results[0]
+	if golang.Title != "Go (programming language)" {
+		t.Errorf("Unexpected title %q", golang.Title)
+	}
+	if !strings.HasPrefix(golang.Content, "Go is a statically typed") || !strings.HasSuffix(golang.Content, "its proper name is Go.") {
+		t.Errorf("Expected the plain-text extract without trailing blank lines, got %q", golang.Content)
+	}
+	if !strings.HasPrefix(golang.Summary, "Go is a statically typed") {
+		t.Errorf("Expected a summary of the extract, got %q", golang.Summary)
+	}
+	if !golang.Published.Equal(time.Date(2024, 5, 1, 21, 40, 5, 0, time.UTC)) {
+		t.Errorf("Expected the last revision time, got %v", golang.Published)
+	}
+}
+
+func TestMediaWikiLanguageVariant(t *testing.T) {
+	server, requests := newMediaWikiServer(t, "mediawiki_search_zh.json", "")
+	provider := &MediaWikiSearchProvider{
+		APIURL:    server.URL + "/w/api.php",
+		Variant:   "zh-tw",
+		UserAgent: "TestBot/1.0 (+https://bot.example)",
+		Client:    server.Client(),
+	}
+
+	results, err := provider.Search("Go 語言", SearchOptions{MaxPages: 3})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(*requests) != 1 || (*requests)[0].Get("variant") != "zh-tw" {
+		t.Fatalf("Expected one request for the zh-tw variant, got %v", *requests)
+	}
+	if len(results) != 1 || results[0].Title != "Go語言" {
+		t.Fatalf("Expected the title in the zh-tw variant, got %+v", results)
+	}
+	if !strings.Contains(results[0].Content, "Google開發") || results[0].URL != "https://zh.wikipedia.org/wiki/Go" {
+		t.Errorf("Unexpected result %+v", results[0])
+	}
+}
+
+func TestMediaWikiErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		wantQuota bool
+		wantErr   string
+	}{
+		{"rate limited", http.StatusOK, `{"error": {"code": "ratelimited", "info": "You've exceeded your rate limit."}}`, true, "ratelimited"},
+		{"too many requests", http.StatusTooManyRequests, `Too many requests`, true, "retry after 30s"},
+		{"bad search", http.StatusOK, `{"error": {"code": "nosrsearch", "info": "The \"gsrsearch\" parameter must be set."}}`, false, "nosrsearch"},
+		{"server error", http.StatusBadGateway, `Bad gateway`, false, "502"},
+		{"oversized response", http.StatusOK, `{"batchcomplete": true, "padding": "` + strings.Repeat("x", maxMediaWikiResponseBytes) + `"}`, false, "error parsing response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "30")
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			provider := &MediaWikiSearchProvider{APIURL: server.URL + "/w/api.php", Client: server.Client()}
+			_, err := provider.Search("golang", SearchOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if errors.Is(err, ErrQuotaExceeded) != tt.wantQuota {
+				t.Errorf("Expected quota error %v, got %v", tt.wantQuota, err)
+			}
+		})
+	}
+}
+
+func TestNewMediaWikiSearchProvider(t *testing.T) {
+	t.Setenv("MEDIAWIKI_URL", "")
+	t.Setenv("MEDIAWIKI_LANGUAGE", "")
+	t.Setenv("MEDIAWIKI_USER_AGENT", "")
+	t.Setenv("FETCH_USER_AGENT", "")
+
+	provider, err := NewMediaWikiSearchProvider(ProviderConfig{})
+	if err != nil {
+		t.Fatalf("NewMediaWikiSearchProvider failed: %v", err)
+	}
+	if provider.APIURL != "https://en.wikipedia.org/w/api.php" || provider.UserAgent != DefaultUserAgent {
+		t.Errorf("Expected English Wikipedia and the fetcher's User-Agent, got %+v", provider)
+	}
+
+	provider, err = NewMediaWikiSearchProvider(ProviderConfig{"MEDIAWIKI_LANGUAGE": "ZH", "MEDIAWIKI_VARIANT": "zh-hk"})
+	if err != nil || provider.APIURL != "https://zh.wikipedia.org/w/api.php" || provider.Variant != "zh-hk" {
+		t.Errorf("Expected Chinese Wikipedia with the zh-hk variant, got %+v, %v", provider, err)
+	}
+
+	provider, err = NewMediaWikiSearchProvider(ProviderConfig{"MEDIAWIKI_URL": "https://wiki.corp.example/api.php"})
+	if err != nil || provider.APIURL != "https://wiki.corp.example/api.php" {
+		t.Errorf("Expected the configured api.php kept as is, got %+v, %v", provider, err)
+	}
+
+	for _, config := range []ProviderConfig{
+		{"MEDIAWIKI_URL": "wiki.corp.example"},
+		{"MEDIAWIKI_URL": "ftp://wiki.corp.example/"},
+		{"MEDIAWIKI_LANGUAGE": "en.evil.example/x?"},
+	} {
+		if _, err := NewMediaWikiSearchProvider(config); err == nil {
+			t.Errorf("Expected %v to be rejected", config)
+		}
+	}
+
+	for _, name := range []string{"wikipedia", "mediawiki"} {
+		if !HasSearchProvider(name) {
+			t.Errorf("Expected %s to be registered", name)
+		}
+	}
+}
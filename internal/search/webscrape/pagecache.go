@@ -0,0 +1,360 @@
+package webscrape
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"open-sonar/internal/utils"
+)
+
+// Defaults for the page cache.
+const (
+	DefaultPageCacheMaxBytes = 256 * 1024 * 1024
+	DefaultPageCacheMaxAge   = 24 * time.Hour
+	DefaultPageCacheFresh    = 10 * time.Minute
+)
+
+// query parameters that only track clicks and never change a page
+var trackingParams = []string{"utm_", "fbclid", "gclid", "mc_cid", "mc_eid"}
+
+// PageCache stores the extracted text of fetched pages on disk, keyed by
+// canonical URL, together with their ETag and Last-Modified validators.
+// An entry is served as is while fresh (per the response's Cache-Control
+// max-age, or DefaultPageCacheFresh), revalidated with a conditional request
+// after that, and dropped once it hasn't been validated for MaxAge. When the
+// files exceed MaxBytes the least recently used entries are evicted.
+type PageCache struct {
+	Dir      string
+	MaxBytes int64
+	MaxAge   time.Duration
+
+	mu      sync.Mutex
+	entries map[string]pageCacheFile // file name -> size and last use
+	size    int64
+}
+
+type pageCacheFile struct {
+	size    int64
+	lastUse time.Time
+}
+
+// cachedPage is one cache file.
+type cachedPage struct {
+	URL          string         `json:"url"`
+	Title        string         `json:"title"`
+	Content      string         `json:"content"`
+	Summary      string         `json:"summary"`
+	Published    time.Time      `json:"published"`
+	Pages        []DocumentPage `json:"pages,omitempty"`
+	Markdown     bool           `json:"markdown,omitempty"`
+	ETag         string         `json:"etag,omitempty"`
+	LastModified string         `json:"last_modified,omitempty"`
+	Validated    time.Time      `json:"validated"`   // when last fetched or revalidated
+	FreshUntil   time.Time      `json:"fresh_until"` // served without revalidation until then
+
+	notModified bool // set on an entry revalidated by a 304 response
+}
+
+var (
+	pageCaches   = map[string]*PageCache{}
+	pageCachesMu sync.Mutex
+)
+
+// DefaultPageCache returns the cache configured by FETCH_CACHE_DIR,
+// FETCH_CACHE_MAX_MB and FETCH_CACHE_MAX_AGE, shared between requests. It
+// returns nil when FETCH_CACHE_DIR is unset or unusable.
+func DefaultPageCache() *PageCache {
+	dir := os.Getenv("FETCH_CACHE_DIR")
+	if dir == "" {
+		return nil
+	}
+
+	pageCachesMu.Lock()
+	defer pageCachesMu.Unlock()
+	if cache, ok := pageCaches[dir]; ok {
+		return cache
+	}
+
+	maxBytes := int64(DefaultPageCacheMaxBytes)
+	if value := os.Getenv("FETCH_CACHE_MAX_MB"); value != "" {
+		if mb, err := strconv.Atoi(value); err == nil && mb > 0 {
+			maxBytes = int64(mb) * 1024 * 1024
+		} else {
+			utils.Warn(fmt.Sprintf("Invalid FETCH_CACHE_MAX_MB %q, using %d", value, DefaultPageCacheMaxBytes/(1024*1024)))
+		}
+	}
+	maxAge := envDuration("FETCH_CACHE_MAX_AGE", DefaultPageCacheMaxAge)
+
+	cache, err := OpenPageCache(dir, maxBytes, maxAge)
+	if err != nil {
+		utils.Warn(fmt.Sprintf("Page cache disabled: %v", err))
+		return nil
+	}
+	pageCaches[dir] = cache
+	return cache
+}
+
+// OpenPageCache opens or creates a page cache in dir, indexing the entries
+// already there.
+func OpenPageCache(dir string, maxBytes int64, maxAge time.Duration) (*PageCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating page cache directory: %w", err)
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading page cache directory: %w", err)
+	}
+
+	c := &PageCache{Dir: dir, MaxBytes: maxBytes, MaxAge: maxAge, entries: map[string]pageCacheFile{}}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		c.entries[file.Name()] = pageCacheFile{size: info.Size(), lastUse: info.ModTime()}
+		c.size += info.Size()
+	}
+	c.removeFiles(c.evictLocked())
+	return c, nil
+}
+
+// Len returns the number of cached pages.
+func (c *PageCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// get returns the entry for a URL, or nil when there is none or it hasn't
+// been validated within MaxAge.
+func (c *PageCache) get(rawURL string) *cachedPage {
+	name := pageCacheName(rawURL)
+	c.mu.Lock()
+	_, ok := c.entries[name]
+	c.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	// Files are read and written outside the lock; writes replace a file by
+	// renaming, so a read sees either the old page or the new one
+	path := filepath.Join(c.Dir, name)
+	data, err := os.ReadFile(path)
+	var page cachedPage
+	if err == nil {
+		err = json.Unmarshal(data, &page)
+	}
+	if err != nil || time.Since(page.Validated) > c.MaxAge {
+		c.mu.Lock()
+		c.forgetLocked(name)
+		c.mu.Unlock()
+		os.Remove(path)
+		return nil
+	}
+
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	c.mu.Lock()
+	if entry, ok := c.entries[name]; ok {
+		c.entries[name] = pageCacheFile{size: entry.size, lastUse: now}
+	}
+	c.mu.Unlock()
+	return &page
+}
+
+// put stores an entry, evicting the least recently used ones when the cache
+// grows past MaxBytes.
+func (c *PageCache) put(page *cachedPage) {
+	data, err := json.Marshal(page)
+	if err != nil {
+		return
+	}
+	name := pageCacheName(page.URL)
+
+	tmp, err := os.CreateTemp(c.Dir, ".page-*")
+	if err != nil {
+		utils.Warn(fmt.Sprintf("Writing page cache failed: %v", err))
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(c.Dir, name))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		utils.Warn(fmt.Sprintf("Writing page cache failed: %v", err))
+		return
+	}
+
+	c.mu.Lock()
+	c.size += int64(len(data)) - c.entries[name].size
+	c.entries[name] = pageCacheFile{size: int64(len(data)), lastUse: time.Now()}
+	evicted := c.evictLocked()
+	c.mu.Unlock()
+	c.removeFiles(evicted)
+}
+
+// drops the least recently used entries until the cache fits MaxBytes,
+// returning their file names for removal once c.mu is released
+func (c *PageCache) evictLocked() []string {
+	if c.MaxBytes <= 0 || c.size <= c.MaxBytes {
+		return nil
+	}
+	names := make([]string, 0, len(c.entries))
+	for name := range c.entries {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return c.entries[names[i]].lastUse.Before(c.entries[names[j]].lastUse)
+	})
+	var evicted []string
+	for _, name := range names {
+		if c.size <= c.MaxBytes {
+			break
+		}
+		c.forgetLocked(name)
+		evicted = append(evicted, name)
+	}
+	return evicted
+}
+
+// drops an entry from the index; the caller removes its file
+func (c *PageCache) forgetLocked(name string) {
+	c.size -= c.entries[name].size
+	delete(c.entries, name)
+}
+
+func (c *PageCache) removeFiles(names []string) {
+	for _, name := range names {
+		os.Remove(filepath.Join(c.Dir, name))
+	}
+}
+
+// returns the cache file name for a URL
+func pageCacheName(rawURL string) string {
+	sum := sha256.Sum256([]byte(canonicalURL(rawURL)))
+	return hex.EncodeToString(sum[:]) + ".json"
+}
+
+// canonicalURL normalizes a URL so that trivially different links to the
+// same page share a cache entry: the scheme and host are lowercased, default
+// ports, fragments and tracking parameters are dropped, and the query is
+// sorted.
+func canonicalURL(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return rawURL
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	u.Host = host
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	query := u.Query()
+	for key := range query {
+		for _, prefix := range trackingParams {
+			if strings.HasPrefix(strings.ToLower(key), prefix) {
+				query.Del(key)
+				break
+			}
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// fresh reports whether an entry can be used without revalidation.
+func (p *cachedPage) fresh() bool {
+	return time.Now().Before(p.FreshUntil)
+}
+
+// apply copies the cached text into a search result.
+func (p *cachedPage) apply(result PageInfo) PageInfo {
+	if p.Title != "" {
+		result.Title = p.Title
+	}
+	result.Content = p.Content
+	result.Summary = p.Summary
+	result.Pages = p.Pages
+	result.Markdown = p.Markdown
+	if result.Published.IsZero() {
+		result.Published = p.Published
+	}
+	return result
+}
+
+// revalidated updates an entry after a 304 Not Modified response.
+func (p *cachedPage) revalidated(header http.Header) *cachedPage {
+	updated := *p
+	if etag := header.Get("ETag"); etag != "" {
+		updated.ETag = etag
+	}
+	updated.Validated = time.Now()
+	updated.FreshUntil = freshUntil(header, updated.Validated)
+	updated.notModified = true
+	return &updated
+}
+
+// newCachedPage builds an entry for an extracted page, or returns nil when the
+// response forbids storing it.
+func newCachedPage(rawURL string, page PageInfo, header http.Header) *cachedPage {
+	directives := strings.ToLower(header.Get("Cache-Control"))
+	if strings.Contains(directives, "no-store") || strings.Contains(directives, "private") {
+		return nil
+	}
+	now := time.Now()
+	return &cachedPage{
+		URL:          rawURL,
+		Title:        page.Title,
+		Content:      page.Content,
+		Summary:      page.Summary,
+		Published:    page.Published,
+		Pages:        page.Pages,
+		Markdown:     page.Markdown,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		Validated:    now,
+		FreshUntil:   freshUntil(header, now),
+	}
+}
+
+// returns when a response stops being fresh: after its Cache-Control max-age,
+// immediately for no-cache, or after DefaultPageCacheFresh otherwise
+func freshUntil(header http.Header, from time.Time) time.Time {
+	for _, directive := range strings.Split(strings.ToLower(header.Get("Cache-Control")), ",") {
+		directive = strings.TrimSpace(directive)
+		if directive == "no-cache" {
+			return from
+		}
+		if value, ok := strings.CutPrefix(directive, "max-age="); ok {
+			if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+				return from.Add(time.Duration(seconds) * time.Second)
+			}
+		}
+	}
+	return from.Add(DefaultPageCacheFresh)
+}
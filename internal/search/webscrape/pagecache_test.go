@@ -0,0 +1,272 @@
+package webscrape
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"HTTPS://Example.COM/a", "https://example.com/a", true},
+		{"https://example.com:443/a", "https://example.com/a", true},
+		{"http://example.com:80/a", "http://example.com/a", true},
+		{"https://example.com", "https://example.com/", true},
+		{"https://example.com/a#intro", "https://example.com/a", true},
+		{"https://example.com/a?b=2&a=1", "https://example.com/a?a=1&b=2", true},
+		{"https://example.com/a?utm_source=x&id=7&fbclid=y", "https://example.com/a?id=7", true},
+		{"https://example.com:8080/a", "https://example.com/a", false},
+		{"https://example.com/A", "https://example.com/a", false},
+		{"https://example.com/a?id=7", "https://example.com/a?id=8", false},
+	}
+	for _, tt := range tests {
+		if got := canonicalURL(tt.a) == canonicalURL(tt.b); got != tt.same {
+			t.Errorf("canonicalURL(%q) == canonicalURL(%q): expected %v, got %v (%s, %s)",
+				tt.a, tt.b, tt.same, got, canonicalURL(tt.a), canonicalURL(tt.b))
+		}
+	}
+}
+
+// cacheServer serves a page with the given validators and cache headers,
+// answering conditional requests with 304 when they match.
+type cacheServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*http.Request
+	full     int // responses with a body
+}
+
+func newCacheServer(t *testing.T, headers map[string]string) *cacheServer {
+	t.Helper()
+	article, err := os.ReadFile("testdata/article.html")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	s := &cacheServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+		s.mu.Unlock()
+
+		for key, value := range headers {
+			w.Header().Set(key, value)
+		}
+		etag, lastModified := headers["ETag"], headers["Last-Modified"]
+		if (etag != "" && r.Header.Get("If-None-Match") == etag) ||
+			(lastModified != "" && r.Header.Get("If-Modified-Since") == lastModified) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		s.mu.Lock()
+		s.full++
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(article)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *cacheServer) counts() (requests, full int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests), s.full
+}
+
+func (s *cacheServer) lastRequest() *http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[len(s.requests)-1]
+}
+
+func openTestPageCache(t *testing.T, maxBytes int64) *PageCache {
+	t.Helper()
+	cache, err := OpenPageCache(t.TempDir(), maxBytes, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to open page cache: %v", err)
+	}
+	return cache
+}
+
+func fetchCached(t *testing.T, server *cacheServer, cache *PageCache, path string) ([]PageInfo, FetchReport) {
+	t.Helper()
+	options := FetchOptions{Client: server.Client(), Cache: cache, HostDelay: -1}
+	return FetchPages(context.Background(), snippets(server.URL, path), options)
+}
+
+func TestPageCacheServesFreshPages(t *testing.T) {
+	server := newCacheServer(t, map[string]string{"Cache-Control": "max-age=600"})
+	cache := openTestPageCache(t, 0)
+
+	first, report := fetchCached(t, server, cache, "/article")
+	if report.Fetched != 1 || report.Cached != 0 {
+		t.Fatalf("Expected the first fetch to miss the cache, got %+v", report)
+	}
+	second, report := fetchCached(t, server, cache, "/article?utm_source=feed")
+	if report.Fetched != 1 || report.Cached != 1 {
+		t.Fatalf("Expected the second fetch to hit the cache, got %+v", report)
+	}
+	if requests, _ := server.counts(); requests != 1 {
+		t.Errorf("Expected a fresh page to be served without a request, got %d requests", requests)
+	}
+	if second[0].Content != first[0].Content || second[0].Title != first[0].Title {
+		t.Errorf("Expected the cached text, got %q", second[0].Title)
+	}
+	if !strings.HasSuffix(second[0].URL, "?utm_source=feed") {
+		t.Errorf("Expected the result to keep its own URL, got %s", second[0].URL)
+	}
+}
+
+func TestPageCacheRevalidates(t *testing.T) {
+	tests := []struct {
+		name      string
+		headers   map[string]string
+		header    string
+		validator string
+	}{
+		{"etag", map[string]string{"ETag": `"v1"`, "Cache-Control": "no-cache"}, "If-None-Match", `"v1"`},
+		{"last modified", map[string]string{"Last-Modified": "Tue, 14 Oct 2025 08:30:00 GMT", "Cache-Control": "max-age=0"}, "If-Modified-Since", "Tue, 14 Oct 2025 08:30:00 GMT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newCacheServer(t, tt.headers)
+			cache := openTestPageCache(t, 0)
+
+			first, _ := fetchCached(t, server, cache, "/article")
+			second, report := fetchCached(t, server, cache, "/article")
+
+			requests, full := server.counts()
+			if requests != 2 || full != 1 {
+				t.Fatalf("Expected a conditional request answered with 304, got %d requests and %d bodies", requests, full)
+			}
+			if got := server.lastRequest().Header.Get(tt.header); got != tt.validator {
+				t.Errorf("Expected %s to carry the cached validator, got %q", tt.header, got)
+			}
+			if report.Cached != 1 {
+				t.Errorf("Expected the revalidated page to count as cached, got %+v", report)
+			}
+			if second[0].Content != first[0].Content {
+				t.Errorf("Expected the cached text after a 304")
+			}
+		})
+	}
+}
+
+func TestPageCacheSkipsNoStore(t *testing.T) {
+	server := newCacheServer(t, map[string]string{"Cache-Control": "no-store"})
+	cache := openTestPageCache(t, 0)
+
+	fetchCached(t, server, cache, "/article")
+	fetchCached(t, server, cache, "/article")
+
+	if _, full := server.counts(); full != 2 {
+		t.Errorf("Expected no-store pages to be fetched every time, got %d bodies", full)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Expected nothing cached, got %d entries", cache.Len())
+	}
+}
+
+func TestPageCacheExpires(t *testing.T) {
+	cache := openTestPageCache(t, 0)
+	page := newCachedPage("https://example.com/a", PageInfo{Content: "text"}, http.Header{})
+	page.Validated = time.Now().Add(-2 * time.Hour)
+	cache.put(page)
+
+	if cache.get("https://example.com/a") != nil {
+		t.Errorf("Expected an entry older than MaxAge to be dropped")
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Expected the expired file to be removed, got %d entries", cache.Len())
+	}
+}
+
+func TestPageCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	content := strings.Repeat("x", 1000)
+	page := func(path string) *cachedPage {
+		return newCachedPage("https://example.com"+path, PageInfo{Content: content}, http.Header{})
+	}
+	cache := openTestPageCache(t, 2500)
+
+	cache.put(page("/a"))
+	time.Sleep(10 * time.Millisecond)
+	cache.put(page("/b"))
+	time.Sleep(10 * time.Millisecond)
+	cache.get("https://example.com/a") // /b is now the least recently used
+	time.Sleep(10 * time.Millisecond)
+	cache.put(page("/c"))
+
+	if cache.Len() != 2 {
+		t.Fatalf("Expected 2 entries to fit, got %d", cache.Len())
+	}
+	if cache.get("https://example.com/b") != nil {
+		t.Errorf("Expected /b to be evicted")
+	}
+	if cache.get("https://example.com/a") == nil || cache.get("https://example.com/c") == nil {
+		t.Errorf("Expected /a and /c to be kept")
+	}
+
+	files, _ := filepath.Glob(filepath.Join(cache.Dir, "*.json"))
+	if len(files) != 2 {
+		t.Errorf("Expected 2 cache files on disk, got %d", len(files))
+	}
+}
+
+func TestPageCacheConcurrentAccess(t *testing.T) {
+	cache := openTestPageCache(t, 4000)
+	content := strings.Repeat("x", 1000)
+
+	// Workers reading and writing overlapping pages keep the index and the
+	// files on disk consistent
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				pageURL := "https://example.com/" + string(rune('a'+(w+i)%6))
+				if page := cache.get(pageURL); page != nil && page.Content != content {
+					t.Errorf("Unexpected content for %s: %q", pageURL, page.Content)
+				}
+				cache.put(newCachedPage(pageURL, PageInfo{Content: content}, http.Header{}))
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if n := cache.Len(); n == 0 || n > 3 {
+		t.Errorf("Expected the cache bounded to 3 entries, got %d", n)
+	}
+}
+
+func TestPageCacheReopens(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := OpenPageCache(dir, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to open page cache: %v", err)
+	}
+	cache.put(newCachedPage("https://example.com/a", PageInfo{Title: "A", Content: "text"}, http.Header{}))
+
+	reopened, err := OpenPageCache(dir, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to reopen page cache: %v", err)
+	}
+	page := reopened.get("https://example.com/a")
+	if page == nil || page.Title != "A" {
+		t.Errorf("Expected the entry to survive reopening, got %+v", page)
+	}
+}
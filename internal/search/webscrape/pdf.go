@@ -0,0 +1,234 @@
+package webscrape
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"open-sonar/internal/utils"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// Page passages quoted from a paged document such as a PDF.
+const (
+	maxPagePassages   = 3
+	pagePassageLength = 400
+)
+
+var sentenceEnd = regexp.MustCompile(`[.!?]["')\]]?\s+`)
+
+// extractPDFPages returns the text of each page of a PDF that has any, with
+// its 1-based page number. The PDF reader panics on some malformed files;
+// that is reported as an error.
+func extractPDFPages(raw []byte) (pages []DocumentPage, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			pages, err = nil, fmt.Errorf("parsing PDF: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("parsing PDF: %w", err)
+	}
+
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		text, err := pdfPageText(page)
+		if err != nil {
+			return nil, fmt.Errorf("reading PDF page %d: %w", i, err)
+		}
+		if text = strings.TrimSpace(text); text != "" {
+			pages = append(pages, DocumentPage{Number: i, Text: text})
+		}
+	}
+	return pages, nil
+}
+
+// returns a page's text one line per row, so that words on adjacent lines
+// aren't run together, falling back to the plain text stream
+func pdfPageText(page pdf.Page) (string, error) {
+	rows, err := page.GetTextByRow()
+	if err != nil || len(rows) == 0 {
+		return page.GetPlainText(nil)
+	}
+	lines := make([]string, 0, len(rows))
+	for _, row := range rows {
+		var line strings.Builder
+		for _, text := range row.Content {
+			line.WriteString(text.S)
+		}
+		lines = append(lines, line.String())
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// extracts the plain text of every page of a PDF
+func extractPDFText(raw []byte) (string, error) {
+	pages, err := extractPDFPages(raw)
+	if err != nil {
+		return "", err
+	}
+	texts := make([]string, len(pages))
+	for i, page := range pages {
+		texts[i] = page.Text
+	}
+	return strings.Join(texts, "\n\n"), nil
+}
+
+// reports whether a response holds a PDF; servers often send PDFs as
+// application/octet-stream, so the URL's extension is checked too
+func isPDFResponse(contentType string, pageURL string) bool {
+	if strings.Contains(contentType, "application/pdf") {
+		return true
+	}
+	return strings.Contains(contentType, "application/octet-stream") &&
+		strings.HasSuffix(strings.ToLower(strings.SplitN(pageURL, "?", 2)[0]), ".pdf")
+}
+
+// reads a PDF response body of at most maxBytes into result, keeping the
+// text of each page
+func readPDF(result PageInfo, body io.Reader, maxBytes int64) (PageInfo, error) {
+	raw, err := io.ReadAll(io.LimitReader(body, maxBytes+1))
+	if err != nil {
+		return result, fmt.Errorf("reading PDF: %w", err)
+	}
+	if int64(len(raw)) > maxBytes {
+		return result, fmt.Errorf("PDF larger than %d bytes", maxBytes)
+	}
+
+	pages, err := extractPDFPages(raw)
+	if err != nil {
+		return result, err
+	}
+	if len(pages) == 0 {
+		// Scanned documents have no text layer
+		return result, fmt.Errorf("no text in PDF")
+	}
+
+	texts := make([]string, len(pages))
+	for i := range pages {
+		pages[i].Text = strings.Join(strings.Fields(pages[i].Text), " ")
+		texts[i] = pages[i].Text
+	}
+	result.Pages = pages
+	result.Content = strings.Join(texts, "\n\n")
+	if summary := generateSummary(pages[0].Text); summary != "" {
+		result.Summary = summary
+	}
+	return result, nil
+}
+
+// withPagePassages quotes the pages of a paged document that best match the
+// query, each labelled with its page number, and points the result's URL at
+// the best page with a #page=N fragment, which PDF viewers open at that page.
+func withPagePassages(result PageInfo, query string) PageInfo {
+	if len(result.Pages) == 0 {
+		return result
+	}
+	terms := uniqueTerms(tokenize(query))
+
+	// Pages matching more distinct query terms come first, then those
+	// mentioning them more often
+	type scoredPage struct {
+		page     DocumentPage
+		distinct int
+		hits     int
+	}
+	scored := make([]scoredPage, len(result.Pages))
+	for i, page := range result.Pages {
+		scored[i] = scoredPage{page: page}
+		for _, term := range terms {
+			if n := termHits(page.Text, []string{term}); n > 0 {
+				scored[i].distinct++
+				scored[i].hits += n
+			}
+		}
+	}
+	sort.SliceStable(scored, func(i, j int) bool {
+		if scored[i].distinct != scored[j].distinct {
+			return scored[i].distinct > scored[j].distinct
+		}
+		return scored[i].hits > scored[j].hits
+	})
+
+	var passages []string
+	for i, s := range scored {
+		if i >= maxPagePassages || (i > 0 && s.hits == 0) {
+			break
+		}
+		passages = append(passages, fmt.Sprintf("[page %d] %s", s.page.Number, pagePassage(s.page.Text, terms, pagePassageLength)))
+	}
+	result.Summary = strings.Join(passages, "\n")
+
+	if scored[0].hits > 0 {
+		result.URL = withPageFragment(result.URL, scored[0].page.Number)
+	}
+	return result
+}
+
+// counts the occurrences of query terms in text
+func termHits(text string, terms []string) int {
+	wanted := map[string]bool{}
+	for _, term := range terms {
+		wanted[term] = true
+	}
+	hits := 0
+	for _, token := range tokenize(text) {
+		if wanted[token] {
+			hits++
+		}
+	}
+	return hits
+}
+
+// returns the run of sentences, starting at the one mentioning the most query
+// terms, that fits in maxLen
+func pagePassage(text string, terms []string, maxLen int) string {
+	var sentences []string
+	start := 0
+	for _, loc := range sentenceEnd.FindAllStringIndex(text, -1) {
+		sentences = append(sentences, strings.TrimSpace(text[start:loc[1]]))
+		start = loc[1]
+	}
+	if rest := strings.TrimSpace(text[start:]); rest != "" {
+		sentences = append(sentences, rest)
+	}
+	if len(sentences) == 0 {
+		return ""
+	}
+
+	best, bestHits := 0, -1
+	for i, sentence := range sentences {
+		if hits := termHits(sentence, terms); hits > bestHits {
+			best, bestHits = i, hits
+		}
+	}
+
+	passage := sentences[best]
+	for _, sentence := range sentences[best+1:] {
+		if len(passage)+1+len(sentence) > maxLen {
+			break
+		}
+		passage += " " + sentence
+	}
+	return utils.TruncateText(passage, maxLen)
+}
+
+// sets a URL's fragment to page=N
+func withPageFragment(rawURL string, page int) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.Fragment = fmt.Sprintf("page=%d", page)
+	return u.String()
+}
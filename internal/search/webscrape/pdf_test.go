@@ -0,0 +1,151 @@
+package webscrape
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func readPDFFixture(t *testing.T) []byte {
+	t.Helper()
+	raw, err := os.ReadFile("testdata/report.pdf")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	return raw
+}
+
+func TestExtractPDFPages(t *testing.T) {
+	pages, err := extractPDFPages(readPDFFixture(t))
+	if err != nil {
+		t.Fatalf("Extraction failed: %v", err)
+	}
+	if len(pages) != 3 {
+		t.Fatalf("Expected 3 pages, got %d", len(pages))
+	}
+	for i, page := range pages {
+		if page.Number != i+1 {
+			t.Errorf("Expected page %d, got %d", i+1, page.Number)
+		}
+	}
+	if !strings.Contains(pages[1].Text, "highest lead reading") {
+		t.Errorf("Expected page 2 text, got %q", pages[1].Text)
+	}
+
+	// Malformed files are errors rather than panics
+	for _, raw := range [][]byte{[]byte("not a pdf"), readPDFFixture(t)[:300]} {
+		if _, err := extractPDFPages(raw); err == nil {
+			t.Errorf("Expected an error for a malformed PDF")
+		}
+	}
+}
+
+func TestWithPagePassages(t *testing.T) {
+	pages, err := extractPDFPages(readPDFFixture(t))
+	if err != nil {
+		t.Fatalf("Extraction failed: %v", err)
+	}
+	for i := range pages {
+		pages[i].Text = strings.Join(strings.Fields(pages[i].Text), " ")
+	}
+	result := PageInfo{URL: "https://city.example/water-2024.pdf", Pages: pages}
+
+	tests := []struct {
+		name    string
+		query   string
+		url     string
+		summary string
+	}{
+		{name: "best page cited", query: "nitrate violations", url: "https://city.example/water-2024.pdf#page=3", summary: "[page 3] "},
+		{name: "matching pages quoted", query: "lead nitrate", url: "https://city.example/water-2024.pdf#page=2", summary: "[page 2] "},
+		{name: "no match", query: "fluoride", url: "https://city.example/water-2024.pdf", summary: "[page 1] Annual Water Quality Report"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := withPagePassages(result, tt.query)
+			if got.URL != tt.url {
+				t.Errorf("Expected URL %s, got %s", tt.url, got.URL)
+			}
+			if !strings.HasPrefix(got.Summary, tt.summary) {
+				t.Errorf("Expected summary starting %q, got %q", tt.summary, got.Summary)
+			}
+		})
+	}
+
+	got := withPagePassages(result, "lead nitrate")
+	if lines := strings.Split(got.Summary, "\n"); len(lines) != 2 || !strings.HasPrefix(lines[1], "[page 3] ") {
+		t.Errorf("Expected passages from pages 2 and 3, got %q", got.Summary)
+	}
+}
+
+func TestFetchPagesPDF(t *testing.T) {
+	fixture := readPDFFixture(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/report.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Write(fixture)
+		case "/download":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(fixture)
+		case "/download.pdf":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(fixture)
+		case "/huge.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Write(bytes.Repeat([]byte("0"), DefaultFetchMaxBytes+1))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	results := snippets(server.URL, "/report.pdf", "/download.pdf", "/download", "/huge.pdf")
+	fetched, report := FetchPages(context.Background(), results, FetchOptions{Client: server.Client(), HostDelay: -1})
+
+	for _, result := range fetched[:2] {
+		if len(result.Pages) != 3 || !strings.Contains(result.Content, "Nitrate results") {
+			t.Errorf("Expected PDF pages for %s, got %+v", result.URL, result)
+		}
+	}
+	for _, result := range fetched[2:] {
+		if result.Content != "snippet" || result.Pages != nil {
+			t.Errorf("Expected %s to keep its snippet", result.URL)
+		}
+	}
+	if report.Fetched != 2 || report.Failed != 2 {
+		t.Errorf("Expected 2 fetched and 2 failed, got %+v", report)
+	}
+}
+
+func TestSearchCitesPDFPage(t *testing.T) {
+	fixture := readPDFFixture(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(fixture)
+	}))
+	defer server.Close()
+
+	useStubProviders(t, map[string]SearchProvider{
+		"duckduckgo": &stubProvider{results: snippets(server.URL, "/water-2024.pdf")},
+	})
+	report := SearchWithReport("nitrate limits", SearchOptions{
+		Providers: []ProviderSpec{{Name: "duckduckgo", Weight: 1}},
+		Fetch:     FetchOptions{Client: server.Client(), HostDelay: -1},
+	})
+	if report.Err != nil {
+		t.Fatalf("Search failed: %v", report.Err)
+	}
+	result := report.Results[0]
+	if result.URL != server.URL+"/water-2024.pdf#page=3" {
+		t.Errorf("Expected the nitrate page cited, got %s", result.URL)
+	}
+	if !strings.HasPrefix(result.Summary, "[page 3] Nitrate results averaged") {
+		t.Errorf("Expected a page-labelled passage, got %q", result.Summary)
+	}
+}
@@ -0,0 +1,339 @@
+package webscrape
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"open-sonar/internal/utils"
+)
+
+// DefaultUserAgent identifies the page fetcher to the sites it visits,
+// configurable through FETCH_USER_AGENT. Its product token ("OpenSonar") is
+// the name robots.txt groups are matched against.
+const DefaultUserAgent = "OpenSonar/1.0"
+
+// DefaultRobotsTTL is how long a site's robots.txt is cached.
+const DefaultRobotsTTL = 24 * time.Hour
+
+const (
+	robotsErrorTTL = 10 * time.Minute // how long an unreachable robots.txt disallows a site
+	robotsTimeout  = 5 * time.Second
+	robotsSweep    = 10 * time.Minute // how often expired entries are removed
+	maxRobotsBytes = 512 * 1024
+)
+
+// FetchUserAgent returns the User-Agent the page fetcher sends.
+func FetchUserAgent() string {
+	return utils.GetEnvWithDefault("FETCH_USER_AGENT", DefaultUserAgent)
+}
+
+// robotsRule is one Allow or Disallow line.
+type robotsRule struct {
+	pattern string
+	allow   bool
+}
+
+// robotsRules are the rules of the robots.txt groups that apply to us.
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+var (
+	allowAll    = &robotsRules{}
+	disallowAll = &robotsRules{rules: []robotsRule{{pattern: "/"}}}
+)
+
+// parseRobots parses a robots.txt and keeps the groups for the given product
+// token, or the "*" groups when none name it. Groups naming the same agent
+// are merged, as RFC 9309 requires.
+func parseRobots(r io.Reader, token string) *robotsRules {
+	token = strings.ToLower(token)
+
+	type group struct {
+		agents []string
+		rules  robotsRules
+	}
+	var groups []*group
+	var current *group
+	inAgents := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxRobotsBytes)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				current = &group{}
+				groups = append(groups, current)
+				inAgents = true
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			inAgents = false
+			if current == nil || value == "" {
+				// An empty Disallow allows everything, which is the default
+				continue
+			}
+			current.rules.rules = append(current.rules.rules, robotsRule{pattern: value, allow: key == "allow"})
+		case "crawl-delay":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.rules.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+
+	merge := func(match func(agent string) bool) *robotsRules {
+		var merged *robotsRules
+		for _, g := range groups {
+			for _, agent := range g.agents {
+				if !match(agent) {
+					continue
+				}
+				if merged == nil {
+					merged = &robotsRules{}
+				}
+				merged.rules = append(merged.rules, g.rules.rules...)
+				if g.rules.crawlDelay > merged.crawlDelay {
+					merged.crawlDelay = g.rules.crawlDelay
+				}
+				break
+			}
+		}
+		return merged
+	}
+	if rules := merge(func(agent string) bool { return agent == token }); rules != nil {
+		return rules
+	}
+	if rules := merge(func(agent string) bool { return agent == "*" }); rules != nil {
+		return rules
+	}
+	return allowAll
+}
+
+// Allowed reports whether a path (with its query) may be fetched. The longest
+// matching rule wins, and Allow wins a tie.
+func (r *robotsRules) Allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	allowed := true
+	longest := -1
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+			longest = len(rule.pattern)
+			allowed = rule.allow
+		}
+	}
+	return allowed
+}
+
+// reports whether a robots.txt pattern matches a path; "*" matches any run of
+// characters and a trailing "$" anchors the pattern to the end of the path
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for i, part := range parts[1:] {
+		last := i == len(parts)-2
+		if last && anchored {
+			return strings.HasSuffix(path[pos:], part)
+		}
+		idx := strings.Index(path[pos:], part)
+		if idx < 0 {
+			return false
+		}
+		pos += idx + len(part)
+	}
+	return !anchored || pos == len(path)
+}
+
+// robotsEntry is a cached robots.txt; ready is closed once rules is set.
+type robotsEntry struct {
+	ready   chan struct{}
+	rules   *robotsRules
+	expires time.Time
+}
+
+// robotsCache holds robots.txt rules per scheme and host, shared by every
+// fetch so that each host's file is downloaded once per TTL. Expired entries
+// are swept out as new hosts are added, so the cache only grows with the
+// hosts seen within a TTL.
+type robotsCache struct {
+	mu        sync.Mutex
+	entries   map[string]*robotsEntry
+	ttl       time.Duration
+	nextSweep time.Time
+}
+
+var robots = newRobotsCache(DefaultRobotsTTL)
+
+func newRobotsCache(ttl time.Duration) *robotsCache {
+	return &robotsCache{entries: map[string]*robotsEntry{}, ttl: ttl}
+}
+
+// rules returns the robots.txt rules for a page's site, downloading them when
+// they aren't cached. Concurrent callers share one download, which runs on its
+// own timeout so that a caller giving up doesn't poison the cache.
+func (c *robotsCache) rules(ctx context.Context, client *http.Client, userAgent string, page *url.URL) (*robotsRules, error) {
+	key := page.Scheme + "://" + strings.ToLower(page.Host)
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if !ok || (isClosed(entry.ready) && time.Now().After(entry.expires)) {
+		c.sweep(time.Now())
+		entry = &robotsEntry{ready: make(chan struct{})}
+		c.entries[key] = entry
+		go c.download(entry, client, userAgent, key)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-entry.ready:
+		return entry.rules, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for robots.txt: %w", ctx.Err())
+	}
+}
+
+// removes the downloaded entries that have expired, at most once per
+// robotsSweep. c.mu must be held.
+func (c *robotsCache) sweep(now time.Time) {
+	if now.Before(c.nextSweep) {
+		return
+	}
+	c.nextSweep = now.Add(robotsSweep)
+	for key, entry := range c.entries {
+		if isClosed(entry.ready) && now.After(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// downloads a site's robots.txt into entry. Following RFC 9309, a missing
+// file (4xx) allows everything and an unreachable one (5xx or a network
+// error) disallows everything until it can be retried.
+func (c *robotsCache) download(entry *robotsEntry, client *http.Client, userAgent, site string) {
+	defer close(entry.ready)
+
+	rules, ttl := func() (*robotsRules, time.Duration) {
+		ctx, cancel := context.WithTimeout(context.Background(), robotsTimeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, "GET", site+"/robots.txt", nil)
+		if err != nil {
+			return disallowAll, robotsErrorTTL
+		}
+		req.Header.Set("User-Agent", userAgent)
+		resp, err := client.Do(req)
+		if errors.Is(err, ErrBlockedAddress) {
+			// Leave it to the page fetch to refuse the address and report it
+			return allowAll, robotsErrorTTL
+		}
+		if err != nil {
+			utils.Debug(fmt.Sprintf("Fetching %s/robots.txt failed: %v", site, err))
+			return disallowAll, robotsErrorTTL
+		}
+		defer resp.Body.Close()
+
+		switch {
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			return parseRobots(io.LimitReader(resp.Body, maxRobotsBytes), productToken(userAgent)), c.ttl
+		case resp.StatusCode >= 400 && resp.StatusCode < 500:
+			return allowAll, c.ttl
+		default:
+			utils.Debug(fmt.Sprintf("Fetching %s/robots.txt failed: %s", site, resp.Status))
+			return disallowAll, robotsErrorTTL
+		}
+	}()
+
+	entry.rules = rules
+	entry.expires = time.Now().Add(ttl)
+}
+
+// returns the product token of a User-Agent, e.g. "OpenSonar" for "OpenSonar/1.0"
+func productToken(userAgent string) string {
+	token, _, _ := strings.Cut(strings.TrimSpace(userAgent), "/")
+	token, _, _ = strings.Cut(token, " ")
+	return token
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// hostPacer spaces out requests to each host across all concurrent fetches.
+type hostPacer struct {
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+var pacer = newHostPacer()
+
+func newHostPacer() *hostPacer {
+	return &hostPacer{next: map[string]time.Time{}}
+}
+
+// wait blocks until a request to host may be sent, at least delay after the
+// previous one. It gives up without taking a turn when the turn would come
+// after ctx's deadline.
+func (p *hostPacer) wait(ctx context.Context, host string, delay time.Duration) error {
+	p.mu.Lock()
+	now := time.Now()
+	turn := p.next[host]
+	if turn.Before(now) {
+		turn = now
+	}
+	if deadline, ok := ctx.Deadline(); ok && turn.After(deadline) {
+		p.mu.Unlock()
+		return fmt.Errorf("next request to %s allowed in %s: %w", host, turn.Sub(now).Round(time.Millisecond), context.DeadlineExceeded)
+	}
+	p.next[host] = turn.Add(delay)
+	p.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(turn))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,242 @@
+package webscrape
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRobots(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/robots.txt")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		path    string
+		allowed bool
+	}{
+		{name: "our group", token: "OpenSonar", path: "/drafts/one", allowed: false},
+		{name: "longer allow wins", token: "OpenSonar", path: "/drafts/published/one", allowed: true},
+		{name: "merged group", token: "OpenSonar", path: "/tmp/file", allowed: false},
+		{name: "anchored wildcard", token: "OpenSonar", path: "/api/data.json", allowed: false},
+		{name: "anchored wildcard with suffix", token: "OpenSonar", path: "/api/data.json?x=1", allowed: true},
+		{name: "star group ignored when named", token: "OpenSonar", path: "/private/page", allowed: true},
+		{name: "star group", token: "SomeBot", path: "/private/page", allowed: false},
+		{name: "star group allow", token: "SomeBot", path: "/private/press/release", allowed: true},
+		{name: "prefix match", token: "SomeBot", path: "/search?q=go", allowed: false},
+		{name: "unlisted path", token: "SomeBot", path: "/about", allowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := parseRobots(strings.NewReader(string(fixture)), tt.token)
+			if got := rules.Allowed(tt.path); got != tt.allowed {
+				t.Errorf("Allowed(%q) for %s = %v, expected %v", tt.path, tt.token, got, tt.allowed)
+			}
+		})
+	}
+
+	rules := parseRobots(strings.NewReader(string(fixture)), "OpenSonar")
+	if rules.crawlDelay != 200*time.Millisecond {
+		t.Errorf("Expected a 200ms crawl delay, got %s", rules.crawlDelay)
+	}
+	if rules := parseRobots(strings.NewReader("User-agent: *\nDisallow:\n"), "OpenSonar"); !rules.Allowed("/anything") {
+		t.Error("Expected an empty Disallow to allow everything")
+	}
+}
+
+func TestProductToken(t *testing.T) {
+	for userAgent, expected := range map[string]string{
+		"OpenSonar/1.0":                        "OpenSonar",
+		"AcmeAnswers/2.1 (+https://acme.test)": "AcmeAnswers",
+		"plainbot":                             "plainbot",
+	} {
+		if got := productToken(userAgent); got != expected {
+			t.Errorf("productToken(%q) = %q, expected %q", userAgent, got, expected)
+		}
+	}
+}
+
+func TestRobotsCache(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		allowed bool
+	}{
+		{name: "rules", status: http.StatusOK, body: "User-agent: *\nDisallow: /page\n", allowed: false},
+		{name: "missing file allows everything", status: http.StatusNotFound, allowed: true},
+		{name: "server error disallows everything", status: http.StatusServiceUnavailable, allowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var downloads atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				downloads.Add(1)
+				time.Sleep(20 * time.Millisecond)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			cache := newRobotsCache(time.Hour)
+			page, _ := url.Parse(server.URL + "/page")
+
+			// Concurrent lookups share one download
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					rules, err := cache.rules(context.Background(), server.Client(), DefaultUserAgent, page)
+					if err != nil {
+						t.Errorf("Lookup failed: %v", err)
+						return
+					}
+					if got := rules.Allowed(page.RequestURI()); got != tt.allowed {
+						t.Errorf("Expected allowed=%v, got %v", tt.allowed, got)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if n := downloads.Load(); n != 1 {
+				t.Errorf("Expected robots.txt downloaded once, got %d", n)
+			}
+		})
+	}
+}
+
+func TestRobotsCacheSweepsExpiredEntries(t *testing.T) {
+	cache := newRobotsCache(time.Hour)
+	now := time.Now()
+	downloaded := func(expires time.Time) *robotsEntry {
+		entry := &robotsEntry{ready: make(chan struct{}), rules: allowAll, expires: expires}
+		close(entry.ready)
+		return entry
+	}
+	cache.entries["https://old.example"] = downloaded(now.Add(-time.Minute))
+	cache.entries["https://fresh.example"] = downloaded(now.Add(time.Minute))
+	cache.entries["https://pending.example"] = &robotsEntry{ready: make(chan struct{})}
+
+	cache.sweep(now)
+	if _, ok := cache.entries["https://old.example"]; ok || len(cache.entries) != 2 {
+		t.Errorf("Expected only the expired entry removed, got %v", cache.entries)
+	}
+
+	// Sweeps are spaced out, so one right after finds nothing to do
+	cache.entries["https://fresh.example"].expires = now.Add(-time.Minute)
+	cache.sweep(now.Add(time.Second))
+	if len(cache.entries) != 2 {
+		t.Errorf("Expected no sweep before the interval, got %v", cache.entries)
+	}
+	cache.sweep(now.Add(robotsSweep + time.Second))
+	if len(cache.entries) != 1 {
+		t.Errorf("Expected the newly expired entry removed, got %v", cache.entries)
+	}
+}
+
+func TestHostPacer(t *testing.T) {
+	p := newHostPacer()
+	delay := 50 * time.Millisecond
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := p.wait(context.Background(), "example.com", delay); err != nil {
+				t.Errorf("Wait failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 2*delay {
+		t.Errorf("Expected three requests spaced %s apart, took %s", delay, elapsed)
+	}
+
+	// Other hosts aren't held back
+	start = time.Now()
+	if err := p.wait(context.Background(), "example.org", delay); err != nil || time.Since(start) > delay {
+		t.Errorf("Expected an idle host to go immediately, err=%v", err)
+	}
+
+	// A turn past the deadline is refused without waiting
+	p.wait(context.Background(), "example.net", time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start = time.Now()
+	if err := p.wait(ctx, "example.net", time.Hour); err == nil || time.Since(start) > 100*time.Millisecond {
+		t.Errorf("Expected an immediate refusal, err=%v", err)
+	}
+}
+
+func TestFetchPagesHonorsRobots(t *testing.T) {
+	article, err := os.ReadFile("testdata/article.html")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	var mu sync.Mutex
+	var requested []time.Time
+	var userAgents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: TestBot\nDisallow: /private/\nCrawl-delay: 0.1\n"))
+			return
+		}
+		mu.Lock()
+		requested = append(requested, time.Now())
+		userAgents = append(userAgents, r.UserAgent())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(article)
+	}))
+	defer server.Close()
+
+	results := snippets(server.URL, "/private/report", "/public/a", "/public/b")
+	fetched, report := FetchPages(context.Background(), results, FetchOptions{
+		Client:    server.Client(),
+		UserAgent: "TestBot/2.0 (+https://bot.example)",
+		HostDelay: -1,
+	})
+
+	if !fetched[0].RobotsDisallowed || fetched[0].Content != "snippet" || fetched[0].URL != results[0].URL {
+		t.Errorf("Expected the disallowed page cited from its snippet, got %+v", fetched[0])
+	}
+	if fetched[1].RobotsDisallowed || !strings.Contains(fetched[1].Content, "type parameters") {
+		t.Errorf("Expected the allowed page fetched, got %+v", fetched[1])
+	}
+	if report.Fetched != 2 || report.Disallowed != 1 || report.Failed != 0 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, userAgent := range userAgents {
+		if userAgent != "TestBot/2.0 (+https://bot.example)" {
+			t.Errorf("Expected the configured User-Agent, got %q", userAgent)
+		}
+	}
+	if len(requested) == 2 {
+		gap := requested[1].Sub(requested[0])
+		if gap < 0 {
+			gap = -gap
+		}
+		if gap < 90*time.Millisecond {
+			t.Errorf("Expected requests spaced by the crawl delay, got %s", gap)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package webscrape
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"open-sonar/internal/proxy"
+)
+
+// DefaultFetchMaxRedirects is how many redirects a page fetch follows.
+const DefaultFetchMaxRedirects = 5
+
+// ErrBlockedAddress is returned when a fetch would connect to a loopback,
+// private, link-local or otherwise non-public address.
+var ErrBlockedAddress = errors.New("address not allowed")
+
+// blockedPrefixes are the ranges a page fetch may not connect to: addresses
+// that reach the host itself, the local network or cloud metadata services
+// (169.254.169.254) rather than the public internet.
+var blockedPrefixes = mustParsePrefixes(
+	"0.0.0.0/8",      // "this" network
+	"10.0.0.0/8",     // RFC 1918
+	"100.64.0.0/10",  // carrier-grade NAT
+	"127.0.0.0/8",    // loopback
+	"169.254.0.0/16", // link-local, including cloud metadata
+	"172.16.0.0/12",  // RFC 1918
+	"192.0.0.0/24",   // IETF protocol assignments
+	"192.168.0.0/16", // RFC 1918
+	"198.18.0.0/15",  // benchmarking
+	"224.0.0.0/4",    // multicast
+	"240.0.0.0/4",    // reserved and broadcast
+	"::/128",         // unspecified
+	"::1/128",        // loopback
+	"64:ff9b::/96",   // NAT64, embedding an IPv4 address
+	"64:ff9b:1::/48", // local-use NAT64
+	"2002::/16",      // 6to4, embedding an IPv4 address
+	"fc00::/7",       // unique local
+	"fe80::/10",      // link-local
+	"ff00::/8",       // multicast
+)
+
+func mustParsePrefixes(cidrs ...string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, len(cidrs))
+	for i, cidr := range cidrs {
+		prefixes[i] = netip.MustParsePrefix(cidr)
+	}
+	return prefixes
+}
+
+// reports whether an address is outside the public internet; IPv4-mapped
+// IPv6 addresses are checked as IPv4
+func isBlockedAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// reports whether a URL scheme may be fetched
+func allowedScheme(scheme string) bool {
+	return scheme == "http" || scheme == "https"
+}
+
+var (
+	publicTransport  = newFetchTransport(isBlockedAddress)
+	privateTransport = newFetchTransport(nil)
+)
+
+// NewFetchClient returns the HTTP client pages are fetched with. Unless
+// allowPrivate is set it refuses to connect to non-public addresses. The
+// check runs on the address actually dialed, after DNS resolution, so a
+// hostname resolving to 127.0.0.1 or a DNS rebinding answer is caught as
+// well as a literal IP. It follows at most maxRedirects redirects, none
+// when negative, and only to http and https URLs.
+//
+// When FETCH_PROXIES is set, requests go through the proxy pool instead. The
+// proxy makes the connection, so each target host is resolved and checked
+// locally before the request is sent; one that can't be resolved is refused.
+func NewFetchClient(allowPrivate bool, maxRedirects int) *http.Client {
+	var transport http.RoundTripper = publicTransport
+	if pool := proxy.ForTraffic(proxy.Fetch); pool != nil {
+		transport = pool.RoundTripper()
+		if !allowPrivate {
+			transport = &vettingTransport{next: transport, blocked: isBlockedAddress}
+		}
+	} else if allowPrivate {
+		transport = privateTransport
+	}
+	return &http.Client{Transport: transport, CheckRedirect: checkRedirect(maxRedirects)}
+}
+
+// vettingTransport refuses requests whose host resolves to a blocked address,
+// for transports that don't dial the target themselves.
+type vettingTransport struct {
+	next    http.RoundTripper
+	blocked func(netip.Addr) bool
+}
+
+func (t *vettingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	addrs := []netip.Addr{}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = append(addrs, addr)
+	} else {
+		resolved, err := net.DefaultResolver.LookupNetIP(req.Context(), "ip", host)
+		if err != nil {
+			return nil, fmt.Errorf("%w: resolving %s: %v", ErrBlockedAddress, host, err)
+		}
+		addrs = resolved
+	}
+	for _, addr := range addrs {
+		if t.blocked(addr) {
+			return nil, fmt.Errorf("%w: %s resolves to %s", ErrBlockedAddress, host, addr)
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+// returns a transport whose connections are refused when blocked reports
+// their address; a nil blocked allows every address. Proxies from the
+// environment are ignored, since the check would then only see the proxy.
+func newFetchTransport(blocked func(netip.Addr) bool) *http.Transport {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	if blocked != nil {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+			}
+			if blocked(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, addrPort.Addr())
+			}
+			return nil
+		}
+	}
+	return &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// returns a redirect policy following at most maxRedirects redirects to
+// http and https URLs
+func checkRedirect(maxRedirects int) func(*http.Request, []*http.Request) error {
+	if maxRedirects < 0 {
+		maxRedirects = 0
+	}
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		if !allowedScheme(req.URL.Scheme) {
+			return fmt.Errorf("redirect to %q URL not allowed", req.URL.Scheme)
+		}
+		return nil
+	}
+}
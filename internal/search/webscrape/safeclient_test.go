@@ -0,0 +1,265 @@
+package webscrape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestIsBlockedAddress(t *testing.T) {
+	tests := []struct {
+		addr    string
+		blocked bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.20.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"::1", true},
+		{"fd00::1", true},
+		{"fe80::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:10.0.0.1", true},
+		{"64:ff9b::a9fe:a9fe", true}, // NAT64 for 169.254.169.254
+		{"64:ff9b::5db8:d822", true},
+		{"64:ff9b:1::a00:1", true},
+		{"2002:a9fe:a9fe::1", true}, // 6to4 for 169.254.169.254
+		{"2002:7f00:1::1", true},
+		{"93.184.216.34", false},
+		{"172.32.0.1", false},
+		{"2606:2800:220:1:248:1893:25c8:1946", false},
+	}
+	for _, tt := range tests {
+		if got := isBlockedAddress(netip.MustParseAddr(tt.addr)); got != tt.blocked {
+			t.Errorf("isBlockedAddress(%s): expected %v, got %v", tt.addr, tt.blocked, got)
+		}
+	}
+}
+
+func TestFetchClientBlocksPrivateAddresses(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		fmt.Fprint(w, "internal")
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	client := NewFetchClient(false, DefaultFetchMaxRedirects)
+	// The hostname is only checked once resolved
+	for _, target := range []string{server.URL, "http://localhost:" + port} {
+		_, err := client.Get(target)
+		if !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("Expected %s to be blocked, got %v", target, err)
+		}
+	}
+	if hits.Load() != 0 {
+		t.Errorf("Expected no request to reach the server, got %d", hits.Load())
+	}
+
+	resp, err := NewFetchClient(true, DefaultFetchMaxRedirects).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected private addresses to be allowed when opted in: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestFetchClientBlocksRedirectToPrivate(t *testing.T) {
+	// 127.0.0.2 plays the internal service and 127.0.0.1 the public site
+	listener, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("Can't listen on 127.0.0.2: %v", err)
+	}
+	var internalHits atomic.Int32
+	internal := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		internalHits.Add(1)
+		fmt.Fprint(w, `{"secret": "metadata"}`)
+	}))
+	internal.Listener.Close()
+	internal.Listener = listener
+	internal.Start()
+	defer internal.Close()
+
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL+"/latest/meta-data", http.StatusFound)
+	}))
+	defer public.Close()
+
+	internalAddr := netip.MustParseAddr("127.0.0.2")
+	client := &http.Client{
+		Transport:     newFetchTransport(func(addr netip.Addr) bool { return addr == internalAddr }),
+		CheckRedirect: checkRedirect(DefaultFetchMaxRedirects),
+	}
+	_, err = client.Get(public.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Expected the redirect to be blocked, got %v", err)
+	}
+	if internalHits.Load() != 0 {
+		t.Errorf("Expected the internal service not to be reached")
+	}
+}
+
+func TestFetchClientRedirectLimits(t *testing.T) {
+	// /hop/N redirects to /hop/N-1 and /hop/0 answers
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/file":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		case r.URL.Path == "/gopher":
+			http.Redirect(w, r, "gopher://example.com/", http.StatusFound)
+		case strings.HasPrefix(r.URL.Path, "/hop/"):
+			n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/hop/"))
+			if n == 0 {
+				fmt.Fprint(w, "done")
+				return
+			}
+			http.Redirect(w, r, fmt.Sprintf("/hop/%d", n-1), http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		path         string
+		maxRedirects int
+		wantErr      string
+	}{
+		{"within limit", "/hop/2", 2, ""},
+		{"over limit", "/hop/3", 2, "stopped after 2 redirects"},
+		{"redirects disabled", "/hop/1", -1, "stopped after 0 redirects"},
+		{"no redirect", "/hop/0", -1, ""},
+		{"file scheme", "/file", 5, `redirect to "file" URL not allowed`},
+		{"gopher scheme", "/gopher", 5, `redirect to "gopher" URL not allowed`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := NewFetchClient(true, tt.maxRedirects).Get(server.URL + tt.path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Expected success, got %v", err)
+				}
+				resp.Body.Close()
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestFetchPagesBlocksPrivateAddresses(t *testing.T) {
+	t.Setenv("FETCH_ALLOW_PRIVATE", "")
+	server := newArticleServer(t, nil)
+
+	fetched, report := FetchPages(context.Background(), snippets(server.URL, "/article"), FetchOptions{HostDelay: -1})
+
+	if report.Fetched != 0 || report.Failed != 1 {
+		t.Errorf("Expected the private address to be refused, got %+v", report)
+	}
+	if fetched[0].Content != "snippet" {
+		t.Errorf("Expected the refused page to keep its snippet, got %q", fetched[0].Content)
+	}
+	if fetched[0].RobotsDisallowed {
+		t.Errorf("Expected a refused address not to be reported as disallowed by robots.txt")
+	}
+
+	_, report = FetchPages(context.Background(), snippets(server.URL, "/article"), FetchOptions{HostDelay: -1, AllowPrivate: true})
+	if report.Fetched != 1 {
+		t.Errorf("Expected the page to be fetched when private addresses are allowed, got %+v", report)
+	}
+}
+
+func TestFetchPagesSizeLimits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := "<html><head><title>Big</title></head><body><p>" + strings.Repeat("All work and no play. ", 200) + "</p></body></html>"
+		switch r.URL.Path {
+		case "/declared":
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			fmt.Fprint(w, body)
+		case "/chunked.pdf":
+			// No Content-Length, so the cap applies while reading
+			w.Header().Set("Content-Type", "application/pdf")
+			w.(http.Flusher).Flush()
+			fmt.Fprint(w, strings.Repeat("0", 4096))
+		}
+	}))
+	defer server.Close()
+
+	options := FetchOptions{Client: server.Client(), HostDelay: -1, MaxBytes: 1024}
+	fetched, report := FetchPages(context.Background(), snippets(server.URL, "/declared", "/chunked.pdf"), options)
+
+	if report.Fetched != 0 || report.Failed != 2 {
+		t.Errorf("Expected both oversized responses to be refused, got %+v", report)
+	}
+	for _, result := range fetched {
+		if result.Content != "snippet" {
+			t.Errorf("Expected %s to keep its snippet, got %q", result.URL, result.Content)
+		}
+	}
+}
+
+func TestFetchPagesThroughProxy(t *testing.T) {
+	article := newArticleServer(t, nil)
+	var proxied []string
+	var mu sync.Mutex
+	// A forward proxy that serves every public page from the article server
+	forward := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proxied = append(proxied, r.URL.String())
+		mu.Unlock()
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		resp, err := http.Get(article.URL + r.URL.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+		io.Copy(w, resp.Body)
+	}))
+	defer forward.Close()
+	t.Setenv("FETCH_PROXIES", forward.URL)
+	t.Setenv("FETCH_ALLOW_PRIVATE", "")
+
+	// The proxy itself is on a private address; the targets are still vetted
+	results := []PageInfo{
+		{URL: "http://93.184.216.34/article", Content: "snippet"},
+		{URL: article.URL + "/internal", Content: "snippet"},
+	}
+	fetched, report := FetchPages(context.Background(), results, FetchOptions{HostDelay: -1})
+
+	if report.Fetched != 1 || report.Failed != 1 {
+		t.Fatalf("Expected the public page fetched and the private one refused, got %+v", report)
+	}
+	if !strings.Contains(fetched[0].Content, "Go 1.18 added type parameters") {
+		t.Errorf("Expected the page text through the proxy, got %q", fetched[0].Content)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, target := range proxied {
+		if strings.Contains(target, "/internal") {
+			t.Errorf("Expected the private target never to reach the proxy, got %s", target)
+		}
+	}
+	if len(proxied) == 0 {
+		t.Errorf("Expected requests to go through the proxy")
+	}
+}
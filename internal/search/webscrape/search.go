@@ -1,35 +1,116 @@
 package webscrape
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"open-sonar/internal/utils"
 	"os"
+	"strings"
+	"time"
 )
 
 var testMode = os.Getenv("TEST_MODE") == "true"
 
-func ScrapeWithOptions(query string, options SearchOptions) []PageInfo {
-	searchTimer := utils.NewTimer("DuckDuckGo search")
-	defer searchTimer.Stop()
+// SearchReport describes a search run, including results removed by filters.
+type SearchReport struct {
+	Provider string
+	Results  []PageInfo
+	Dropped  []DroppedResult
+	Err      error // set when the provider failed; Results is then empty
+
+	// Set for fan-out searches across several providers
+	Providers       []ProviderReport
+	ResultProviders map[string][]string // result URL -> providers that returned it
+
+	// Fetch summarizes fetching the pages of snippet-only results
+	Fetch FetchReport
+
+	// Providers that were blocked or cooling down after a block
+	Blocked []ProviderReport
+	// FailedOver is the blocked provider that Provider stood in for
+	FailedOver string
+}
+
+// DroppedResult is a search result removed by filtering, with the reason.
+type DroppedResult struct {
+	Result PageInfo
+	Reason string
+}
 
-	var provider SearchProvider
-	var err error
+// DefaultProviderName returns the provider used when none is requested,
+// configurable through SEARCH_PROVIDER.
+func DefaultProviderName() string {
 	if testMode {
-		provider, err = GetSearchProvider("mock")
-	} else {
-		provider, err = GetSearchProvider("duckduckgo")
+		return "mock"
 	}
-	if err != nil {
-		utils.Error(fmt.Sprintf("Search provider error: %v", err))
-		return []PageInfo{}
+	return utils.GetEnvWithDefault("SEARCH_PROVIDER", "duckduckgo")
+}
+
+// DefaultNewsRecency is the recency filter news searches use unless the
+// request sets one, configurable through NEWS_RECENCY_FILTER.
+const DefaultNewsRecency = "day"
+
+// NewsProviders returns the providers for news searches: NEWS_PROVIDERS when
+// set, otherwise DuckDuckGo's news vertical plus the feeds provider when
+// NEWS_FEEDS is configured.
+func NewsProviders() ([]ProviderSpec, error) {
+	if value := os.Getenv("NEWS_PROVIDERS"); value != "" {
+		return ParseProviderSpecs(splitList(value))
+	}
+	specs := []ProviderSpec{{Name: "duckduckgo", Weight: 1}}
+	if os.Getenv("NEWS_FEEDS") != "" {
+		specs = append(specs, ProviderSpec{Name: "feeds", Weight: 1})
 	}
+	return specs, nil
+}
 
-	results, err := provider.Search(query, options)
-	if err != nil {
-		utils.Error(fmt.Sprintf("Search error: %v", err))
-		return []PageInfo{}
+// fills in the news providers and default recency filter
+func newsOptions(options SearchOptions) (SearchOptions, error) {
+	if len(options.Providers) == 0 {
+		specs, err := NewsProviders()
+		if err != nil {
+			return options, fmt.Errorf("invalid NEWS_PROVIDERS: %w", err)
+		}
+		options.Providers = specs
+	}
+	if options.SearchRecencyFilter == "" {
+		options.SearchRecencyFilter = utils.GetEnvWithDefault("NEWS_RECENCY_FILTER", DefaultNewsRecency)
+	}
+	return options, nil
+}
+
+func ScrapeWithOptions(query string, options SearchOptions) []PageInfo {
+	return SearchWithReport(query, options).Results
+}
+
+// SearchWithReport runs a search like ScrapeWithOptions and also reports the
+// provider used and the results dropped by filtering.
+func SearchWithReport(query string, options SearchOptions) SearchReport {
+	searchTimer := utils.NewTimer("Web search")
+	defer searchTimer.Stop()
+
+	if options.SearchMode == SearchModeNews {
+		var err error
+		if options, err = newsOptions(options); err != nil {
+			return SearchReport{Provider: "news", Results: []PageInfo{}, Err: err}
+		}
 	}
 
+	var report SearchReport
+	switch len(options.Providers) {
+	case 0:
+		report = searchProvider(query, DefaultProviderName(), options)
+	case 1:
+		report = searchProvider(query, options.Providers[0].Name, options)
+	default:
+		report = searchProviders(query, options.Providers, options)
+	}
+	if report.Err != nil {
+		return report
+	}
+
+	results := report.Results
 	if len(results) > 0 {
 		utils.Info(fmt.Sprintf("Search returned %d results", len(results)))
 		for i, result := range results {
@@ -41,12 +122,101 @@ func ScrapeWithOptions(query string, options SearchOptions) []PageInfo {
 		utils.Warn("Search returned no results")
 	}
 
-	if len(options.SearchDomainFilter) > 0 {
-		results = FilterResults(results, options)
-		utils.Info(fmt.Sprintf("After domain filtering: %d results remain", len(results)))
+	// News results are always held to the recency filter, since not every
+	// provider applies it
+	if len(options.SearchDomainFilter) > 0 || options.SearchMode == SearchModeNews {
+		results, report.Dropped = filterResults(results, options)
+		utils.Info(fmt.Sprintf("After filtering: %d results remain", len(results)))
+	}
+
+	if !options.Fetch.Disabled {
+		// Results come ranked, so a cap keeps the best candidates
+		indexes := report.snippetResults(results)
+		if options.Fetch.MaxPages > 0 && len(indexes) > options.Fetch.MaxPages {
+			indexes = indexes[:options.Fetch.MaxPages]
+		}
+		results, report.Fetch = fetchIndexes(context.Background(), results, indexes, options.Fetch)
+		if report.Fetch.Fetched+report.Fetch.Failed > 0 {
+			utils.Info(fmt.Sprintf("Fetched %d of %d pages (%d from cache) in %s", report.Fetch.Fetched, report.Fetch.Fetched+report.Fetch.Failed, report.Fetch.Cached, report.Fetch.Duration))
+		}
+		// Quote the pages of fetched PDFs, and the sections of other fetched
+		// pages, that match the query
+		for i := range results {
+			results[i] = withPagePassages(results[i], query)
+			results[i] = withChunkPassages(results[i], query)
+		}
+	}
+
+	if results != nil {
+		report.Results = results
+	}
+	return report
+}
+
+// returns the indexes of results that came from snippet-only providers
+func (r SearchReport) snippetResults(results []PageInfo) []int {
+	var indexes []int
+	for i, result := range results {
+		if !needsFetch(result) {
+			continue
+		}
+		providers := r.ResultProviders[result.URL]
+		if len(providers) == 0 {
+			providers = []string{r.Provider}
+		}
+		for _, name := range providers {
+			if snippetProviders[name] {
+				indexes = append(indexes, i)
+				break
+			}
+		}
+	}
+	return indexes
+}
+
+// runs the search against a single named provider, handing it to the
+// failover provider when it is blocked
+func searchProvider(query string, name string, options SearchOptions) SearchReport {
+	report := SearchReport{
+		Provider: name,
+		Results:  []PageInfo{},
+	}
+
+	start := time.Now()
+	results, err := runProvider(query, name, options)
+	if errors.Is(err, ErrBlocked) {
+		report.Blocked = append(report.Blocked, ProviderReport{Name: name, Duration: time.Since(start), Err: err})
+		if failover := FailoverProviderName(); failover != "" && failover != strings.ToLower(name) {
+			utils.Warn(fmt.Sprintf("Search provider %s blocked, failing over to %s", name, failover))
+			searchFailovers.Add(name, 1)
+			report.Provider = failover
+			report.FailedOver = name
+
+			start = time.Now()
+			results, err = runProvider(query, failover, options)
+			if errors.Is(err, ErrBlocked) {
+				report.Blocked = append(report.Blocked, ProviderReport{Name: failover, Duration: time.Since(start), Err: err})
+			}
+		}
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrQuotaExceeded):
+			utils.Warn(fmt.Sprintf("Search quota exceeded: %v", err))
+		case errors.Is(err, ErrBlocked):
+			utils.Warn(fmt.Sprintf("Search blocked: %v", err))
+		default:
+			utils.Error(fmt.Sprintf("Search error: %v", err))
+		}
+		report.Err = err
+		return report
 	}
 
-	return results
+	if results != nil {
+		report.Results = results
+	}
+	return report
 }
 
 func Scrape(query string, maxPages int, maxRetries int) []PageInfo {
@@ -1,9 +1,13 @@
 package webscrape
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,19 +21,82 @@ type SearchProvider interface {
 // GetSearchProviderFunc is a function type for creating search providers.
 type GetSearchProviderFunc func(provider string) (SearchProvider, error)
 
-// DefaultGetSearchProvider is the default implementation.
-var DefaultGetSearchProvider GetSearchProviderFunc = func(provider string) (SearchProvider, error) {
-	switch strings.ToLower(provider) {
-	case "duckduckgo":
-		return NewDuckDuckGoSearchProvider(), nil
-	case "mock":
-		return NewMockSearchProvider(), nil
-	// Add other search providers as needed.
-	default:
-		return NewDuckDuckGoSearchProvider(), nil
+// SearchProviderFunc constructs a search provider from its configuration.
+type SearchProviderFunc func(config ProviderConfig) (SearchProvider, error)
+
+// ProviderConfig holds settings for a search provider constructor, keyed by
+// the environment variable names the provider documents (e.g. "SEARXNG_URL").
+// Keys that are not set fall back to the environment.
+type ProviderConfig map[string]string
+
+// Get returns the configured value for key, or the environment variable.
+func (c ProviderConfig) Get(key string) string {
+	if value, ok := c[key]; ok {
+		return value
+	}
+	return os.Getenv(key)
+}
+
+// ErrUnknownSearchProvider is returned for provider names that are not registered.
+var ErrUnknownSearchProvider = errors.New("unknown search provider")
+
+var (
+	searchRegistry   = map[string]SearchProviderFunc{}
+	searchConfigs    = map[string]ProviderConfig{}
+	searchRegistryMu sync.RWMutex
+)
+
+// RegisterSearchProvider makes a search provider available under name.
+// Registering an existing name replaces it.
+func RegisterSearchProvider(name string, constructor SearchProviderFunc) {
+	searchRegistryMu.Lock()
+	defer searchRegistryMu.Unlock()
+	searchRegistry[strings.ToLower(name)] = constructor
+}
+
+// ConfigureSearchProvider sets the configuration passed to a provider's constructor.
+func ConfigureSearchProvider(name string, config ProviderConfig) {
+	searchRegistryMu.Lock()
+	defer searchRegistryMu.Unlock()
+	searchConfigs[strings.ToLower(name)] = config
+}
+
+// SearchProviderNames returns the registered provider names, sorted.
+func SearchProviderNames() []string {
+	searchRegistryMu.RLock()
+	defer searchRegistryMu.RUnlock()
+	names := make([]string, 0, len(searchRegistry))
+	for name := range searchRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasSearchProvider reports whether a provider is registered under name.
+func HasSearchProvider(name string) bool {
+	searchRegistryMu.RLock()
+	defer searchRegistryMu.RUnlock()
+	_, ok := searchRegistry[strings.ToLower(name)]
+	return ok
+}
+
+// NewSearchProvider constructs the registered provider with its configuration.
+func NewSearchProvider(name string) (SearchProvider, error) {
+	searchRegistryMu.RLock()
+	constructor, ok := searchRegistry[strings.ToLower(name)]
+	config := searchConfigs[strings.ToLower(name)]
+	searchRegistryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w %q (available: %s)", ErrUnknownSearchProvider, name, strings.Join(SearchProviderNames(), ", "))
 	}
+	return constructor(config)
 }
 
+// DefaultGetSearchProvider is the default implementation.
+var DefaultGetSearchProvider GetSearchProviderFunc = NewSearchProvider
+
 // CurrentGetSearchProvider holds the current provider implementation.
 var CurrentGetSearchProvider GetSearchProviderFunc = DefaultGetSearchProvider
 
@@ -71,11 +138,18 @@ func RecencyToTime(recency string) (time.Time, error) {
 
 // FilterResults filters results based on domain filters and recency.
 func FilterResults(results []PageInfo, options SearchOptions) []PageInfo {
+	filtered, _ := filterResults(results, options)
+	return filtered
+}
+
+// filterResults filters results and reports why each removed result was dropped.
+func filterResults(results []PageInfo, options SearchOptions) ([]PageInfo, []DroppedResult) {
 	if len(options.SearchDomainFilter) == 0 && options.SearchRecencyFilter == "" {
-		return results
+		return results, nil
 	}
 
 	var filtered []PageInfo
+	var dropped []DroppedResult
 	var allowFilters []string
 	var blockedFilters []string
 	for _, filter := range options.SearchDomainFilter {
@@ -106,6 +180,7 @@ func FilterResults(results []PageInfo, options SearchOptions) []PageInfo {
 			}
 		}
 		if blocked {
+			dropped = append(dropped, DroppedResult{Result: result, Reason: "blocked by domain filter"})
 			continue
 		}
 
@@ -119,17 +194,19 @@ func FilterResults(results []PageInfo, options SearchOptions) []PageInfo {
 				}
 			}
 			if !allowed {
+				dropped = append(dropped, DroppedResult{Result: result, Reason: "not in allowed domains"})
 				continue
 			}
 		}
 
 		// Check recency.
 		if !minTime.IsZero() && result.Published.Before(minTime) {
+			dropped = append(dropped, DroppedResult{Result: result, Reason: "older than recency filter"})
 			continue
 		}
 		filtered = append(filtered, result)
 	}
-	return filtered
+	return filtered, dropped
 }
 
 // extractDomain extracts the domain from a URL.
@@ -187,6 +264,23 @@ func generateDummyResults(n int) []PageInfo {
 	return results
 }
 
+func init() {
+	RegisterSearchProvider("duckduckgo", func(config ProviderConfig) (SearchProvider, error) {
+		provider := &DuckDuckGoSearchProvider{}
+		if value := config.Get("DUCKDUCKGO_INSTANT_ANSWERS"); value != "" {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid DUCKDUCKGO_INSTANT_ANSWERS %q", value)
+			}
+			provider.InstantAnswers = enabled
+		}
+		return provider, nil
+	})
+	RegisterSearchProvider("mock", func(ProviderConfig) (SearchProvider, error) {
+		return NewMockSearchProvider(), nil
+	})
+}
+
 // NewDuckDuckGoSearchProvider returns a new DuckDuckGoSearchProvider.
 func NewDuckDuckGoSearchProvider() SearchProvider {
 	return &DuckDuckGoSearchProvider{}
@@ -0,0 +1,62 @@
+package webscrape
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSearchProviderRegistry(t *testing.T) {
+	var received ProviderConfig
+	RegisterSearchProvider("Custom", func(config ProviderConfig) (SearchProvider, error) {
+		received = config
+		return &stubProvider{results: pages("https://custom.example")}, nil
+	})
+	defer func() {
+		searchRegistryMu.Lock()
+		delete(searchRegistry, "custom")
+		delete(searchConfigs, "custom")
+		searchRegistryMu.Unlock()
+	}()
+
+	if !HasSearchProvider("custom") {
+		t.Fatal("Expected registered provider to be found case-insensitively")
+	}
+
+	names := SearchProviderNames()
+	for _, expected := range []string{"brave", "custom", "duckduckgo", "elasticsearch", "local", "mock", "opensearch", "searxng"} {
+		found := false
+		for _, name := range names {
+			found = found || name == expected
+		}
+		if !found {
+			t.Errorf("Expected %q in provider list %v", expected, names)
+		}
+	}
+
+	t.Setenv("CUSTOM_TOKEN", "from-env")
+	ConfigureSearchProvider("custom", ProviderConfig{"CUSTOM_INDEX": "docs"})
+	if _, err := NewSearchProvider("CUSTOM"); err != nil {
+		t.Fatalf("Failed to construct provider: %v", err)
+	}
+	if received.Get("CUSTOM_INDEX") != "docs" || received.Get("CUSTOM_TOKEN") != "from-env" {
+		t.Errorf("Expected configured values with environment fallback, got %v", received)
+	}
+}
+
+func TestUnknownSearchProvider(t *testing.T) {
+	_, err := NewSearchProvider("altavista")
+	if !errors.Is(err, ErrUnknownSearchProvider) {
+		t.Fatalf("Expected ErrUnknownSearchProvider, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "duckduckgo") {
+		t.Errorf("Expected error to list available providers, got %q", err)
+	}
+
+	old := SetGetSearchProvider(DefaultGetSearchProvider)
+	defer SetGetSearchProvider(old)
+	report := SearchWithReport("query", SearchOptions{Providers: []ProviderSpec{{Name: "altavista", Weight: 1}}})
+	if !errors.Is(report.Err, ErrUnknownSearchProvider) {
+		t.Errorf("Expected search with unknown provider to fail, got %v", report.Err)
+	}
+}
@@ -0,0 +1,209 @@
+package webscrape
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"open-sonar/internal/proxy"
+	"open-sonar/internal/utils"
+)
+
+// SearXNGSearchProvider queries a SearXNG instance through its JSON API.
+type SearXNGSearchProvider struct {
+	BaseURL    string
+	Engines    []string
+	Categories []string
+	Language   string
+	Client     *http.Client
+}
+
+type searxngResponse struct {
+	Query   string          `json:"query"`
+	Results []searxngResult `json:"results"`
+}
+
+type searxngResult struct {
+	URL           string   `json:"url"`
+	Title         string   `json:"title"`
+	Content       string   `json:"content"`
+	Engine        string   `json:"engine"`
+	Engines       []string `json:"engines"`
+	PublishedDate *string  `json:"publishedDate"`
+}
+
+// layouts SearXNG engines use for publishedDate
+var searxngDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+func init() {
+	RegisterSearchProvider("searxng", func(config ProviderConfig) (SearchProvider, error) {
+		provider, err := NewSearXNGSearchProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	})
+}
+
+// NewSearXNGSearchProvider creates a SearXNG provider from SEARXNG_* settings.
+func NewSearXNGSearchProvider(config ProviderConfig) (*SearXNGSearchProvider, error) {
+	baseURL := config.Get("SEARXNG_URL")
+	if baseURL == "" {
+		return nil, fmt.Errorf("SEARXNG_URL not set")
+	}
+	return &SearXNGSearchProvider{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Engines:    splitList(config.Get("SEARXNG_ENGINES")),
+		Categories: splitList(config.Get("SEARXNG_CATEGORIES")),
+		Language:   config.Get("SEARXNG_LANGUAGE"),
+		Client:     proxy.NewClient(proxy.Search, 30*time.Second),
+	}, nil
+}
+
+// Search fetches up to options.MaxPages pages of results from SearXNG.
+func (p *SearXNGSearchProvider) Search(query string, options SearchOptions) ([]PageInfo, error) {
+	if options.MaxPages <= 0 {
+		options.MaxPages = 1
+	}
+
+	timer := utils.NewTimer("SearXNG search")
+	defer timer.Stop()
+
+	var results []PageInfo
+	seen := make(map[string]bool)
+
+	for page := 1; page <= options.MaxPages; page++ {
+		pageResults, err := p.fetchPage(query, page, options)
+		if err != nil {
+			// Keep what earlier pages returned
+			if page > 1 {
+				utils.Warn(fmt.Sprintf("SearXNG page %d failed: %v", page, err))
+				break
+			}
+			return nil, err
+		}
+		if len(pageResults) == 0 {
+			break
+		}
+
+		for _, result := range pageResults {
+			if result.URL == "" || seen[result.URL] {
+				continue
+			}
+			seen[result.URL] = true
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
+}
+
+// fetches and decodes one page of results
+func (p *SearXNGSearchProvider) fetchPage(query string, page int, options SearchOptions) ([]PageInfo, error) {
+	req, err := http.NewRequestWithContext(options.requestContext(), "GET", p.searchURL(query, page, options), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("SearXNG API error: %s", resp.Status)
+	}
+
+	var decoded searxngResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("error parsing response: %w", err)
+	}
+
+	results := make([]PageInfo, 0, len(decoded.Results))
+	for _, r := range decoded.Results {
+		content := strings.TrimSpace(r.Content)
+		results = append(results, PageInfo{
+			URL:       strings.TrimSpace(r.URL),
+			Title:     strings.TrimSpace(r.Title),
+			Content:   content,
+			Summary:   content,
+			Published: parseSearXNGDate(r.PublishedDate),
+		})
+	}
+	return results, nil
+}
+
+// builds the /search URL for a page
+func (p *SearXNGSearchProvider) searchURL(query string, page int, options SearchOptions) string {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("format", "json")
+	params.Set("pageno", strconv.Itoa(page))
+	if len(p.Engines) > 0 {
+		params.Set("engines", strings.Join(p.Engines, ","))
+	}
+	if len(p.Categories) > 0 {
+		params.Set("categories", strings.Join(p.Categories, ","))
+	}
+	if p.Language != "" {
+		params.Set("language", p.Language)
+	}
+	if timeRange := searxngTimeRange(options.SearchRecencyFilter); timeRange != "" {
+		params.Set("time_range", timeRange)
+	}
+	return p.BaseURL + "/search?" + params.Encode()
+}
+
+// maps a recency filter, as accepted by RecencyToTime, to SearXNG's
+// time_range, which has no hour granularity
+func searxngTimeRange(recency string) string {
+	switch strings.ToLower(recency) {
+	case "hour", "day":
+		return "day"
+	case "week":
+		return "week"
+	case "month":
+		return "month"
+	default:
+		return ""
+	}
+}
+
+// parses publishedDate, returning the zero time when absent or unparseable
+func parseSearXNGDate(value *string) time.Time {
+	if value == nil || *value == "" {
+		return time.Time{}
+	}
+	for _, layout := range searxngDateLayouts {
+		if t, err := time.Parse(layout, *value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
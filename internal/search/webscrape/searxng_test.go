@@ -0,0 +1,145 @@
+package webscrape
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newSearXNGTestServer(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+
+	fixture, err := os.ReadFile("testdata/searxng_search.json")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RawQuery)
+		if r.URL.Path != "/search" || r.URL.Query().Get("format") != "json" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("pageno") {
+		case "1":
+			w.Write(fixture)
+		case "2":
+			// Page 2 repeats a result from page 1 and adds a new one
+			fmt.Fprint(w, `{"results": [
+				{"url": "https://tokio.rs/", "title": "Tokio", "content": "dup"},
+				{"url": "https://smol.rs/", "title": "smol", "content": "A small async runtime."}
+			]}`)
+		default:
+			fmt.Fprint(w, `{"results": []}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestSearXNGSearchProvider(t *testing.T) {
+	server, requests := newSearXNGTestServer(t)
+
+	provider := &SearXNGSearchProvider{
+		BaseURL:    server.URL,
+		Engines:    []string{"duckduckgo", "brave"},
+		Categories: []string{"general", "news"},
+		Language:   "en",
+		Client:     server.Client(),
+	}
+
+	results, err := provider.Search("rust async runtime", SearchOptions{
+		MaxPages:            5,
+		SearchRecencyFilter: "week",
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	// Pages 1 and 2 have results, page 3 is empty and stops pagination
+	if len(*requests) != 3 {
+		t.Errorf("Expected 3 page requests, got %d", len(*requests))
+	}
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 unique results, got %d", len(results))
+	}
+
+	first := (*requests)[0]
+	for _, param := range []string{"engines=duckduckgo%2Cbrave", "categories=general%2Cnews", "language=en", "time_range=week", "pageno=1"} {
+		if !strings.Contains(first, param) {
+			t.Errorf("Expected query to contain %q, got %q", param, first)
+		}
+	}
+
+	if results[0].URL != "https://tokio.rs/" || results[0].Title != "Tokio - An asynchronous Rust runtime" {
+		t.Errorf("Unexpected first result: %+v", results[0])
+	}
+	if results[1].Content != "An update on the state of async Rust." {
+		t.Errorf("Expected trimmed content, got %q", results[1].Content)
+	}
+	expectedDate := time.Date(2024, 1, 15, 9, 30, 0, 0, time.UTC)
+	if !results[1].Published.Equal(expectedDate) {
+		t.Errorf("Expected published %v, got %v", expectedDate, results[1].Published)
+	}
+	if !results[2].Published.IsZero() {
+		t.Errorf("Expected zero published time for null date, got %v", results[2].Published)
+	}
+	if results[3].URL != "https://smol.rs/" {
+		t.Errorf("Expected page 2 result last, got %+v", results[3])
+	}
+}
+
+func TestSearXNGTimeRange(t *testing.T) {
+	// Every filter RecencyToTime accepts maps to a time range, and nothing else
+	for recency, want := range map[string]string{
+		"hour": "day", "day": "day", "week": "week", "month": "month", "": "", "year": "",
+	} {
+		if got := searxngTimeRange(recency); got != want {
+			t.Errorf("searxngTimeRange(%q): expected %q, got %q", recency, want, got)
+		}
+		if _, err := RecencyToTime(recency); (err == nil) != (want != "" || recency == "") {
+			t.Errorf("RecencyToTime(%q) disagrees with searxngTimeRange: %v", recency, err)
+		}
+	}
+}
+
+func TestSearXNGSearchProviderErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	provider := &SearXNGSearchProvider{BaseURL: server.URL, Client: server.Client()}
+	if _, err := provider.Search("query", SearchOptions{}); err == nil {
+		t.Error("Expected error for non-200 response")
+	}
+}
+
+func TestGetSearXNGProvider(t *testing.T) {
+	t.Setenv("SEARXNG_URL", "")
+	if _, err := DefaultGetSearchProvider("searxng"); err == nil {
+		t.Error("Expected error when SEARXNG_URL is not set")
+	}
+
+	t.Setenv("SEARXNG_URL", "http://searx.local/")
+	t.Setenv("SEARXNG_ENGINES", "duckduckgo, wikipedia")
+
+	provider, err := DefaultGetSearchProvider("searxng")
+	if err != nil {
+		t.Fatalf("Failed to create SearXNG provider: %v", err)
+	}
+	searxng, ok := provider.(*SearXNGSearchProvider)
+	if !ok {
+		t.Fatalf("Expected *SearXNGSearchProvider, got %T", provider)
+	}
+	if searxng.BaseURL != "http://searx.local" || len(searxng.Engines) != 2 {
+		t.Errorf("Unexpected provider config: %+v", searxng)
+	}
+}
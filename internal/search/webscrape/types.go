@@ -1,6 +1,9 @@
 package webscrape
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type PageInfo struct {
 	URL       string
@@ -8,6 +11,23 @@ type PageInfo struct {
 	Content   string
 	Summary   string
 	Published time.Time
+	// InstantAnswer marks a search engine's direct answer (e.g. a DuckDuckGo
+	// zero-click answer), which should be ranked above scraped pages
+	InstantAnswer bool
+	// RobotsDisallowed marks a result whose page robots.txt doesn't let us
+	// fetch; its Content is the search snippet
+	RobotsDisallowed bool
+	// Pages holds the text of each page of a paged document such as a PDF
+	Pages []DocumentPage
+	// Markdown marks Content kept as Markdown, whose headings, lists, tables
+	// and code blocks the passage chunker keeps whole
+	Markdown bool
+}
+
+// DocumentPage is the text of one page of a document.
+type DocumentPage struct {
+	Number int // 1-based
+	Text   string
 }
 
 type SearchOptions struct {
@@ -15,4 +35,40 @@ type SearchOptions struct {
 	MaxRetries          int
 	SearchDomainFilter  []string
 	SearchRecencyFilter string
+	// Providers overrides the default provider; with more than one, they are
+	// queried concurrently and their results fused
+	Providers []ProviderSpec
+	// SearchMode is SearchModeWeb (the default) or SearchModeNews
+	SearchMode string
+	// Fetch bounds fetching the pages of snippet-only results
+	Fetch FetchOptions
+	// Context cancels provider requests, e.g. once a fan-out search stops
+	// waiting for the provider; nil means context.Background()
+	Context context.Context
+}
+
+// returns the context provider requests run under
+func (o SearchOptions) requestContext() context.Context {
+	if o.Context == nil {
+		return context.Background()
+	}
+	return o.Context
 }
+
+// waits for d, returning the context's error if it is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Search modes
+const (
+	SearchModeWeb  = "web"
+	SearchModeNews = "news"
+)
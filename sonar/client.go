@@ -45,6 +45,13 @@ func (c *Client) ChatCompletions(request models.ChatCompletionRequest) (*models.
 	return &resp, err
 }
 
+// SearchProviders lists the search providers available on the server
+func (c *Client) SearchProviders() (*models.SearchProviderList, error) {
+	var resp models.SearchProviderList
+	err := c.sendRequest("GET", "/search/providers", nil, &resp)
+	return &resp, err
+}
+
 // sendRequest sends an HTTP request to the API
 func (c *Client) sendRequest(method, path string, body interface{}, result interface{}) error {
 	// Marshal the request body
@@ -7,11 +7,14 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"open-sonar/internal/api"
 	"open-sonar/internal/llm"
+	"open-sonar/internal/prompts"
+	"open-sonar/internal/search/webscrape"
 	"open-sonar/internal/utils"
 
 	"github.com/joho/godotenv"
@@ -61,11 +64,66 @@ func (s *Server) Start() error {
 	// Set environment variables from config
 	setEnvironmentVariables(s.Config)
 
+	// Load and validate prompt templates before accepting requests
+	templateDir := s.Config.PromptTemplateDir
+	if templateDir == "" {
+		templateDir = os.Getenv("PROMPT_TEMPLATE_DIR")
+	}
+	if templateDir != "" || len(s.Config.PromptTemplatesByModel) > 0 || len(s.Config.PromptTemplatesByAPIKey) > 0 {
+		err := prompts.Configure(prompts.Config{
+			Dir:             templateDir,
+			ModelTemplates:  s.Config.PromptTemplatesByModel,
+			APIKeyTemplates: s.Config.PromptTemplatesByAPIKey,
+		})
+		if err != nil {
+			return fmt.Errorf("loading prompt templates: %w", err)
+		}
+	}
+
+	// Pass OpenAI endpoint settings directly, since header values may hold
+	// any character, then register named endpoints, refusing invalid ones
+	llm.ConfigureOpenAI(llm.OpenAIConfig{
+		BaseURL:      s.Config.OpenAIBaseURL,
+		Organization: s.Config.OpenAIOrganization,
+		Project:      s.Config.OpenAIProject,
+		Headers:      s.Config.OpenAIHeaders,
+		Direct:       s.Config.OpenAIDirect,
+	})
+	for name, instance := range s.Config.OpenAIInstances {
+		err := llm.RegisterOpenAIInstance(name, llm.OpenAIConfig{
+			BaseURL:      instance.BaseURL,
+			APIKey:       instance.APIKey,
+			Organization: instance.Organization,
+			Project:      instance.Project,
+			Headers:      instance.Headers,
+			Models:       instance.Models,
+			Direct:       instance.Direct,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Pass per-provider settings to search provider constructors and reject unknown names
+	for name, values := range s.Config.SearchProviderConfigs {
+		webscrape.ConfigureSearchProvider(name, values)
+	}
+	specs, err := validateSearchProviders(s.Config)
+	if err != nil {
+		return err
+	}
+	if err := openLocalCorpus(specs); err != nil {
+		return err
+	}
+
 	// Add the auth token directly to the API key registry
 	// This ensures the token is immediately available without waiting for env var processing
 	if s.Config.AuthToken != "" {
 		api.AddAPIKey(s.Config.AuthToken)
 	}
+	if s.Config.AdminToken != "" {
+		api.AddAdminAPIKey(s.Config.AdminToken)
+	}
 
 	// Set up router
 	router := api.SetupRoutes()
@@ -269,6 +327,184 @@ func setEnvironmentVariables(config *Config) {
 	if config.AnthropicModel != "" {
 		os.Setenv("ANTHROPIC_MODEL", config.AnthropicModel)
 	}
+
+	// Search configuration
+	if config.SearchProvider != "" {
+		os.Setenv("SEARCH_PROVIDER", config.SearchProvider)
+	}
+	if len(config.SearchProviders) > 0 {
+		os.Setenv("SEARCH_PROVIDERS", strings.Join(config.SearchProviders, ","))
+	}
+	for model, specs := range config.SearchProvidersByModel {
+		os.Setenv(webscrape.ModelProvidersEnvVar(model), strings.Join(specs, ","))
+	}
+	if config.SearchFailoverProvider != "" {
+		os.Setenv("SEARCH_FAILOVER_PROVIDER", config.SearchFailoverProvider)
+	}
+	if config.SearchBlockCooldown > 0 {
+		os.Setenv("SEARCH_BLOCK_COOLDOWN", config.SearchBlockCooldown.String())
+	}
+	if config.DuckDuckGoInstantAnswers {
+		os.Setenv("DUCKDUCKGO_INSTANT_ANSWERS", "true")
+	}
+	if len(config.NewsFeeds) > 0 {
+		os.Setenv("NEWS_FEEDS", strings.Join(config.NewsFeeds, ","))
+	}
+	if config.NewsRecencyFilter != "" {
+		os.Setenv("NEWS_RECENCY_FILTER", config.NewsRecencyFilter)
+	}
+	if config.FetchWorkers > 0 {
+		os.Setenv("FETCH_WORKERS", fmt.Sprintf("%d", config.FetchWorkers))
+	}
+	if config.FetchPerHost > 0 {
+		os.Setenv("FETCH_PER_HOST", fmt.Sprintf("%d", config.FetchPerHost))
+	}
+	if config.FetchPageTimeout > 0 {
+		os.Setenv("FETCH_PAGE_TIMEOUT", config.FetchPageTimeout.String())
+	}
+	if config.FetchDeadline > 0 {
+		os.Setenv("FETCH_DEADLINE", config.FetchDeadline.String())
+	}
+	if config.FetchUserAgent != "" {
+		os.Setenv("FETCH_USER_AGENT", config.FetchUserAgent)
+	}
+	if config.FetchHostDelay > 0 {
+		os.Setenv("FETCH_HOST_DELAY", config.FetchHostDelay.String())
+	}
+	if config.FetchMaxBytes > 0 {
+		os.Setenv("FETCH_MAX_BYTES", fmt.Sprintf("%d", config.FetchMaxBytes))
+	}
+	if config.FetchMaxRedirects > 0 {
+		os.Setenv("FETCH_MAX_REDIRECTS", fmt.Sprintf("%d", config.FetchMaxRedirects))
+	} else if config.FetchMaxRedirects < 0 {
+		os.Setenv("FETCH_MAX_REDIRECTS", "0")
+	}
+	if config.FetchAllowPrivate {
+		os.Setenv("FETCH_ALLOW_PRIVATE", "true")
+	}
+	if len(config.SearchProxies) > 0 {
+		os.Setenv("SEARCH_PROXIES", strings.Join(config.SearchProxies, ","))
+	}
+	if len(config.FetchProxies) > 0 {
+		os.Setenv("FETCH_PROXIES", strings.Join(config.FetchProxies, ","))
+	}
+	if len(config.LLMProxies) > 0 {
+		os.Setenv("LLM_PROXIES", strings.Join(config.LLMProxies, ","))
+	}
+	if config.MaxRequestBytes > 0 {
+		os.Setenv("MAX_REQUEST_BYTES", fmt.Sprintf("%d", config.MaxRequestBytes))
+	}
+	if config.FetchCacheDir != "" {
+		os.Setenv("FETCH_CACHE_DIR", config.FetchCacheDir)
+	}
+	if config.FetchCacheMaxMB > 0 {
+		os.Setenv("FETCH_CACHE_MAX_MB", fmt.Sprintf("%d", config.FetchCacheMaxMB))
+	}
+	if config.FetchCacheMaxAge > 0 {
+		os.Setenv("FETCH_CACHE_MAX_AGE", config.FetchCacheMaxAge.String())
+	}
+	if config.ExtractRulesFile != "" {
+		os.Setenv("EXTRACT_RULES_FILE", config.ExtractRulesFile)
+	}
+	if config.SearXNGURL != "" {
+		os.Setenv("SEARXNG_URL", config.SearXNGURL)
+	}
+	if len(config.SearXNGEngines) > 0 {
+		os.Setenv("SEARXNG_ENGINES", strings.Join(config.SearXNGEngines, ","))
+	}
+	if len(config.SearXNGCategories) > 0 {
+		os.Setenv("SEARXNG_CATEGORIES", strings.Join(config.SearXNGCategories, ","))
+	}
+	if config.SearXNGLanguage != "" {
+		os.Setenv("SEARXNG_LANGUAGE", config.SearXNGLanguage)
+	}
+	if config.BraveAPIKey != "" {
+		os.Setenv("BRAVE_API_KEY", config.BraveAPIKey)
+	}
+	if config.BraveCountry != "" {
+		os.Setenv("BRAVE_COUNTRY", config.BraveCountry)
+	}
+	if config.BraveSearchLang != "" {
+		os.Setenv("BRAVE_SEARCH_LANG", config.BraveSearchLang)
+	}
+	if config.MediaWikiURL != "" {
+		os.Setenv("MEDIAWIKI_URL", config.MediaWikiURL)
+	}
+	if config.MediaWikiLanguage != "" {
+		os.Setenv("MEDIAWIKI_LANGUAGE", config.MediaWikiLanguage)
+	}
+	if config.MediaWikiVariant != "" {
+		os.Setenv("MEDIAWIKI_VARIANT", config.MediaWikiVariant)
+	}
+	elasticsearchEnv := map[string]string{
+		"ELASTICSEARCH_URL":            config.ElasticsearchURL,
+		"ELASTICSEARCH_INDEX":          config.ElasticsearchIndex,
+		"ELASTICSEARCH_USERNAME":       config.ElasticsearchUsername,
+		"ELASTICSEARCH_PASSWORD":       config.ElasticsearchPassword,
+		"ELASTICSEARCH_API_KEY":        config.ElasticsearchAPIKey,
+		"ELASTICSEARCH_FIELDS":         strings.Join(config.ElasticsearchFields, ","),
+		"ELASTICSEARCH_TITLE_FIELD":    config.ElasticsearchTitleField,
+		"ELASTICSEARCH_URL_FIELD":      config.ElasticsearchURLField,
+		"ELASTICSEARCH_BODY_FIELD":     config.ElasticsearchBodyField,
+		"ELASTICSEARCH_DATE_FIELD":     config.ElasticsearchDateField,
+		"ELASTICSEARCH_QUERY_TEMPLATE": config.ElasticsearchQueryTemplate,
+	}
+	for name, value := range elasticsearchEnv {
+		if value != "" {
+			os.Setenv(name, value)
+		}
+	}
+	if config.LocalCorpusDir != "" {
+		os.Setenv("LOCAL_CORPUS_DIR", config.LocalCorpusDir)
+	}
+	if config.LocalCorpusBaseURL != "" {
+		os.Setenv("LOCAL_CORPUS_BASE_URL", config.LocalCorpusBaseURL)
+	}
+	if config.LocalCorpusRefresh > 0 {
+		os.Setenv("LOCAL_CORPUS_REFRESH", config.LocalCorpusRefresh.String())
+	} else if config.LocalCorpusRefresh < 0 {
+		os.Setenv("LOCAL_CORPUS_REFRESH", "0")
+	}
+}
+
+// validateSearchProviders checks every configured search provider name is registered and returns them
+func validateSearchProviders(config *Config) ([]webscrape.ProviderSpec, error) {
+	specs := append([]string{webscrape.DefaultProviderName()}, config.SearchProviders...)
+	for _, modelSpecs := range config.SearchProvidersByModel {
+		specs = append(specs, modelSpecs...)
+	}
+	if failover := webscrape.FailoverProviderName(); failover != "" {
+		specs = append(specs, failover)
+	}
+
+	parsed, err := webscrape.ParseProviderSpecs(specs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search provider configuration: %w", err)
+	}
+	for _, spec := range parsed {
+		if !webscrape.HasSearchProvider(spec.Name) {
+			return nil, fmt.Errorf("%w %q (available: %s)", webscrape.ErrUnknownSearchProvider, spec.Name, strings.Join(webscrape.SearchProviderNames(), ", "))
+		}
+	}
+	return parsed, nil
+}
+
+// openLocalCorpus indexes the local corpus before requests are accepted when
+// it is configured, so a missing or unreadable directory fails startup
+func openLocalCorpus(specs []webscrape.ProviderSpec) error {
+	uses := os.Getenv("LOCAL_CORPUS_DIR") != ""
+	for _, spec := range specs {
+		if spec.Name == "local" {
+			uses = true
+		}
+	}
+	if !uses {
+		return nil
+	}
+	if _, err := webscrape.NewSearchProvider("local"); err != nil {
+		return fmt.Errorf("indexing local corpus: %w", err)
+	}
+	return nil
 }
 
 // logLevelToString converts a log level to its string representation
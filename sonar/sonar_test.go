@@ -4,11 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"open-sonar/internal/llm"
 	"open-sonar/internal/models"
 	"open-sonar/internal/utils"
 	"open-sonar/sonar"
@@ -237,3 +240,112 @@ func isOllamaRunning() bool {
 	defer resp.Body.Close()
 	return resp.StatusCode == http.StatusOK
 }
+
+// TestUnknownSearchProvider checks Start rejects search providers that are not registered
+func TestUnknownSearchProvider(t *testing.T) {
+	// Start exports the provider to the environment; restore it afterwards
+	t.Setenv("SEARCH_PROVIDER", "")
+	t.Setenv("SEARCH_PROVIDERS", "")
+
+	server := sonar.NewServer(
+		sonar.WithPort(TEST_PORT+1),
+		sonar.WithSearchProviders("duckduckgo", "altavista:2"),
+		sonar.WithoutEnvFile(),
+	)
+	err := server.Start()
+	if err == nil {
+		server.Stop()
+		t.Fatal("Expected Start to fail for an unknown search provider")
+	}
+	if !strings.Contains(err.Error(), "altavista") {
+		t.Errorf("Expected error to name the unknown provider, got %v", err)
+	}
+}
+
+// TestInvalidOpenAIInstance checks Start rejects OpenAI-compatible instances it cannot call
+func TestInvalidOpenAIInstance(t *testing.T) {
+	server := sonar.NewServer(
+		sonar.WithPort(TEST_PORT+2),
+		sonar.WithOpenAIInstance("lmstudio", sonar.OpenAIInstance{BaseURL: "http://localhost:1234/v1"}),
+		sonar.WithoutEnvFile(),
+	)
+	err := server.Start()
+	if err == nil {
+		server.Stop()
+		t.Fatal("Expected Start to fail for an instance without models")
+	}
+	if !strings.Contains(err.Error(), "lmstudio") {
+		t.Errorf("Expected error to name the instance, got %v", err)
+	}
+}
+
+// TestLocalCorpusIndexedAtStart checks Start indexes the local corpus and reports a missing directory
+func TestLocalCorpusIndexedAtStart(t *testing.T) {
+	// Start exports the corpus settings to the environment; restore them afterwards
+	t.Setenv("SEARCH_PROVIDER", "")
+	t.Setenv("LOCAL_CORPUS_DIR", "")
+	t.Setenv("LOCAL_CORPUS_REFRESH", "")
+
+	server := sonar.NewServer(
+		sonar.WithPort(TEST_PORT+3),
+		sonar.WithLocalCorpus(filepath.Join(t.TempDir(), "missing"), ""),
+		sonar.WithoutEnvFile(),
+	)
+	err := server.Start()
+	if err == nil {
+		server.Stop()
+		t.Fatal("Expected Start to fail for a missing corpus directory")
+	}
+	if !strings.Contains(err.Error(), "local corpus") {
+		t.Errorf("Expected a local corpus error, got %v", err)
+	}
+
+	// A negative refresh interval disables re-indexing
+	server = sonar.NewServer(
+		sonar.WithPort(TEST_PORT+3),
+		sonar.WithLocalCorpus(t.TempDir(), ""),
+		sonar.WithLocalCorpusRefresh(-1),
+		sonar.WithoutEnvFile(),
+	)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer server.Stop()
+	if got := os.Getenv("LOCAL_CORPUS_REFRESH"); got != "0" {
+		t.Errorf("Expected re-indexing disabled, got LOCAL_CORPUS_REFRESH=%q", got)
+	}
+}
+
+// TestOpenAIEndpointHeaders checks configured headers reach an OpenAI-compatible server unchanged
+func TestOpenAIEndpointHeaders(t *testing.T) {
+	var cookie string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie = r.Header.Get("Cookie")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`)
+	}))
+	defer backend.Close()
+	t.Setenv("OPENAI_MODEL", "")
+	defer llm.ConfigureOpenAI(llm.OpenAIConfig{})
+
+	server := sonar.NewServer(
+		sonar.WithPort(TEST_PORT+4),
+		sonar.WithOpenAIEndpoint(backend.URL+"/v1", map[string]string{"Cookie": "session=abc; region=eu"}),
+		sonar.WithoutEnvFile(),
+	)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer server.Stop()
+
+	provider, err := llm.NewLLMProvider("openai")
+	if err != nil {
+		t.Fatalf("NewLLMProvider failed: %v", err)
+	}
+	if _, err := provider.GenerateResponse("hello"); err != nil {
+		t.Fatalf("GenerateResponse failed: %v", err)
+	}
+	if cookie != "session=abc; region=eu" {
+		t.Errorf("Expected the Cookie header unchanged, got %q", cookie)
+	}
+}